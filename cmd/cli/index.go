@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"backend/internal/config"
+	"backend/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const indexUsage = "Usage: cli index rebuild | health"
+
+// runIndex rebuilds the vector index from configuration or reports on its health
+func runIndex(args []string) {
+	if len(args) != 1 || args[0] != "rebuild" && args[0] != "health" {
+		fmt.Println(indexUsage)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := sqlx.Connect("postgres", cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to DB: %v", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresVectorRepo(db)
+	ctx := context.Background()
+
+	if args[0] == "rebuild" {
+		ix := cfg.VectorIndex()
+		fmt.Printf("Rebuilding vector index (%s)...\n", ix.Method)
+		if err := repo.RebuildIndex(ctx, ix); err != nil {
+			log.Fatalf("Rebuild failed: %v", err)
+		}
+		fmt.Println("Done")
+	}
+
+	health, err := repo.IndexHealth(ctx)
+	if err != nil {
+		log.Fatalf("Failed to inspect index: %v", err)
+	}
+	fmt.Printf("Index:    %s\n", health.Name)
+	fmt.Printf("Method:   %s\n", health.Method)
+	if health.Method != "none" {
+		fmt.Printf("Options:  %s\n", health.Options)
+		fmt.Printf("Valid:    %t\n", health.Valid)
+		fmt.Printf("Size:     %.1f MiB\n", float64(health.SizeBytes)/(1<<20))
+		fmt.Printf("Scans:    %d\n", health.Scans)
+	}
+	fmt.Printf("Rows:     %d\n", health.Rows)
+	for _, w := range health.Warnings {
+		fmt.Printf("Warning:  %s\n", w)
+	}
+	if len(health.Warnings) > 0 {
+		os.Exit(2)
+	}
+}
@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	"backend/internal/config"
-	"backend/internal/embedding"
+	"backend/internal/domain"
 	"backend/internal/ingestion"
+	"backend/internal/provider"
 	"backend/internal/repository"
+	"backend/internal/resilience"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -19,15 +24,29 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "index":
+			runIndex(os.Args[2:])
+			return
+		}
+	}
+
 	// Flags
-	filePath := flag.String("file", "", "Path to PDF file")
+	filePath := flag.String("file", "", "Path to the document (PDF, DOCX, EPUB, HTML, Markdown or text)")
 	subject := flag.String("subject", "", "Subject of the book")
-	chapter := flag.Int("chapter", 0, "Chapter number")
+	chapter := flag.Int("chapter", 0, "Chapter number, for a single-chapter file (detected when omitted)")
 	language := flag.String("lang", "", "Language (en or bn)")
+	chunkStrategy := flag.String("chunk-strategy", "", "Chunk strategy (fixed or structured); defaults to CHUNK_STRATEGY")
 	flag.Parse()
 
-	if *filePath == "" || *subject == "" || *chapter == 0 || *language == "" {
-		fmt.Println("Usage: cli -file <path> -subject <subject> -chapter <num> -lang <en|bn>")
+	if *filePath == "" || *subject == "" || *chapter < 0 || *language == "" {
+		fmt.Println("Usage: cli -file <path> -subject <subject> -lang <en|bn> [-chapter <num>] [-chunk-strategy <fixed|structured>]")
+		fmt.Println("       cli migrate up | down [-steps <n>] | status")
+		fmt.Println("       cli index rebuild | health")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
@@ -41,22 +60,44 @@ func main() {
 	// 2. Initialize DB
 	db, err := sqlx.Connect("postgres", cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to DB: %v", err)
+		// Documents and jobs are kept in Postgres whichever vector store is used
+		log.Fatalf("Failed to connect to DB (required with VECTOR_STORE=%s too): %v", cfg.VectorStore, err)
 	}
 	defer db.Close()
 
 	// 3. Initialize Embedder
 	ctx := context.Background()
-	embedder, err := embedding.NewGeminiClient(ctx, cfg.GeminiAPIKey)
+	baseEmbedder, err := provider.NewEmbedder(ctx, cfg.EmbeddingSettings())
 	if err != nil {
 		log.Fatalf("Failed to init embedding client: %v", err)
 	}
+	embedder := resilience.WrapEmbedder(baseEmbedder, resilience.NewPolicy(cfg.RetryPolicy(cfg.EmbedRequestsPerMinute)))
 
 	// 4. Initialize Components
-	vectorRepo := repository.NewPostgresVectorRepo(db)
-	pdfParser := ingestion.NewPDFParser()
-	chunker := ingestion.NewChunker(1000, 200)
-	ingestionService := ingestion.NewIngestionService(pdfParser, chunker, embedder, vectorRepo)
+	vectorRepo, err := repository.NewVectorRepo(cfg.VectorStore, db, cfg.VectorSnapshot, cfg.VectorSearch())
+	if err != nil {
+		log.Fatalf("Failed to init vector store: %v", err)
+	}
+	documentRepo := repository.NewPostgresDocumentRepo(db)
+	jobRepo := repository.NewPostgresJobRepo(db)
+	parsers := ingestion.NewRegistry()
+	normalizer, err := ingestion.NewNormalizer(cfg.NormalizeSteps)
+	if err != nil {
+		log.Fatalf("Failed to init text normalization: %v", err)
+	}
+	chunkers := ingestion.NewChunkers(256, 48) // 256 tokens, 48 overlap
+	ingestionService := ingestion.NewIngestionService(parsers, chunkers[cfg.ChunkStrategy], embedder, vectorRepo, documentRepo, jobRepo)
+	ingestionService.Chunkers = chunkers
+	ingestionService.Normalizer = normalizer
+	ingestionService.EmbedBatchSize = cfg.EmbedBatchSize
+	ingestionService.EmbedConcurrency = cfg.EmbedConcurrency
+
+	// A model whose embeddings do not fit the table would fail on every chunk
+	if err := ingestionService.CheckDimensions(ctx); errors.Is(err, domain.ErrDimensionMismatch) {
+		log.Fatalf("Embedding model does not fit the vector store: %v; set EMBEDDING_DIMENSIONS to the model's size and recreate the embeddings table", err)
+	} else if err != nil {
+		log.Printf("Warning: could not check embedding dimensions: %v", err)
+	}
 
 	// 5. Open File
 	file, err := os.Open(*filePath)
@@ -74,10 +115,23 @@ func main() {
 	fmt.Printf("Ingesting %s (Size: %d bytes)...\n", *filePath, fileInfo.Size())
 	start := time.Now()
 
-	err = ingestionService.Ingest(ctx, file, fileInfo.Size(), *subject, *chapter, *language)
+	doc := &domain.Document{
+		Filename:      filepath.Base(*filePath),
+		Subject:       *subject,
+		Chapter:       *chapter,
+		Language:      *language,
+		ChunkStrategy: domain.ChunkStrategy(*chunkStrategy),
+	}
+	err = ingestionService.Ingest(ctx, file, fileInfo.Size(), doc)
 	if err != nil {
 		log.Fatalf("Ingestion failed: %v", err)
 	}
+	// Write out what the vector store has not saved yet
+	if closer, ok := vectorRepo.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Fatalf("Saving vector store failed: %v", err)
+		}
+	}
 
-	fmt.Printf("Successfully ingested document in %v\n", time.Since(start))
+	fmt.Printf("Successfully ingested document %s (%d pages, %d chunks) in %v\n", doc.ID, doc.PageCount, doc.ChunkCount, time.Since(start))
 }
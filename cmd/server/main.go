@@ -2,19 +2,28 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"backend/internal/api"
 	"backend/internal/api/handlers"
 	"backend/internal/auth"
 	"backend/internal/config"
-	"backend/internal/embedding"
-	"backend/internal/generation"
+	"backend/internal/domain"
 	"backend/internal/ingestion"
+	"backend/internal/jobs"
 	"backend/internal/middleware"
+	"backend/internal/migrations"
+	"backend/internal/provider"
 	"backend/internal/rag"
 	"backend/internal/repository"
+	"backend/internal/resilience"
+	"backend/internal/tokenizer"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
@@ -57,31 +66,93 @@ func main() {
 		db.SetMaxOpenConns(25)
 		db.SetMaxIdleConns(25)
 		db.SetConnMaxLifetime(5 * time.Minute)
+
+		if cfg.AutoMigrate {
+			migrator, err := migrations.New(db)
+			if err != nil {
+				log.Fatalf("Failed to load migrations: %v", err)
+			}
+			migrator.Vars.VectorIndex = cfg.VectorIndex()
+			migrator.Vars.Dimensions = cfg.VectorDimensions()
+			applied, err := migrator.Up(context.Background())
+			if err != nil {
+				log.Fatalf("Failed to migrate DB: %v", err)
+			}
+			for _, m := range applied {
+				log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+			}
+		}
 	}
 
 	// 3. Initialize AI Clients
 	ctx := context.Background()
-	embedder, err := embedding.NewGeminiClient(ctx, cfg.GeminiAPIKey)
+	baseEmbedder, err := provider.NewEmbedder(ctx, cfg.EmbeddingSettings())
 	if err != nil {
 		log.Fatalf("Failed to init embedding client: %v", err)
 	}
 
-	generator, err := generation.NewGeminiClient(ctx, cfg.GeminiAPIKey)
+	baseGenerator, err := provider.NewGenerator(ctx, cfg.ChatSettings())
 	if err != nil {
 		log.Fatalf("Failed to init generation client: %v", err)
 	}
 
+	// Retry, rate limit and circuit-break every model call; each API has its own quota
+	embedder := resilience.WrapEmbedder(baseEmbedder, resilience.NewPolicy(cfg.RetryPolicy(cfg.EmbedRequestsPerMinute)))
+	generator := resilience.WrapGenerationClient(baseGenerator, resilience.NewPolicy(cfg.RetryPolicy(cfg.GenerateRequestsPerMinute)))
+
 	// 4. Initialize Core Components
-	vectorRepo := repository.NewPostgresVectorRepo(db)
+	vectorRepo, err := repository.NewVectorRepo(cfg.VectorStore, db, cfg.VectorSnapshot, cfg.VectorSearch())
+	if err != nil {
+		log.Fatalf("Failed to init vector store: %v", err)
+	}
+	documentRepo := repository.NewPostgresDocumentRepo(db)
+	jobRepo := repository.NewPostgresJobRepo(db)
+	questionRepo := repository.NewPostgresQuestionRepo(db)
 
 	// Ingestion
-	pdfParser := ingestion.NewPDFParser()
-	chunker := ingestion.NewChunker(1000, 200) // 1000 chars, 200 overlap
-	ingestionService := ingestion.NewIngestionService(pdfParser, chunker, embedder, vectorRepo)
+	parsers := ingestion.NewRegistry()
+	normalizer, err := ingestion.NewNormalizer(cfg.NormalizeSteps)
+	if err != nil {
+		log.Fatalf("Failed to init text normalization: %v", err)
+	}
+	chunkers := ingestion.NewChunkers(256, 48) // 256 tokens, 48 overlap
+	ingestionService := ingestion.NewIngestionService(parsers, chunkers[cfg.ChunkStrategy], embedder, vectorRepo, documentRepo, jobRepo)
+	ingestionService.Chunkers = chunkers
+	ingestionService.Normalizer = normalizer
+	ingestionService.EmbedBatchSize = cfg.EmbedBatchSize
+	ingestionService.EmbedConcurrency = cfg.EmbedConcurrency
+
+	// A model whose embeddings do not fit the table would fail on every chunk
+	if db != nil {
+		if err := ingestionService.CheckDimensions(ctx); errors.Is(err, domain.ErrDimensionMismatch) {
+			log.Fatalf("Embedding model does not fit the vector store: %v; set EMBEDDING_DIMENSIONS to the model's size and recreate the embeddings table", err)
+		} else if err != nil {
+			log.Printf("Warning: could not check embedding dimensions: %v", err)
+		}
+	}
+
+	// Background ingestion workers; jobs interrupted by a restart are resumed
+	jobPool := jobs.NewPool(jobRepo, ingestionService, cfg.IngestionWorkers)
+	jobPool.MaxAttempts = cfg.JobMaxAttempts
+	jobCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	jobPool.Start(jobCtx)
 
 	// RAG
 	retriever := rag.NewRetriever(embedder, vectorRepo)
-	generatorService := rag.NewGeneratorService(generator, retriever)
+	retriever.MinScore = cfg.RetrievalMinScore
+	if cfg.RetrievalMMR {
+		mmr := rag.MMROptions{Lambda: cfg.RetrievalMMRLambda, FetchFactor: cfg.RetrievalMMRFetchFactor}
+		if err := mmr.Validate(); err != nil {
+			log.Fatalf("Invalid MMR settings: %v", err)
+		}
+		retriever.MMR = &mmr
+	}
+	generatorService := rag.NewGeneratorService(generator, retriever, questionRepo)
+	generatorService.ContextTokens = cfg.ContextTokens
+	if counter, ok := baseGenerator.(tokenizer.Counter); ok {
+		generatorService.TokenCounter = counter
+	}
 
 	// Auth
 	authService := auth.NewAuthService(cfg.SupabaseURL, cfg.SupabaseAnonKey)
@@ -90,6 +161,7 @@ func main() {
 	docHandler := handlers.NewDocumentHandler(ingestionService)
 	questionHandler := handlers.NewQuestionHandler(generatorService)
 	authHandler := handlers.NewAuthHandler(authService)
+	jobHandler := handlers.NewJobHandler(ingestionService)
 
 	// 6. Middleware
 	authMiddleware := middleware.NewAuthMiddleware(cfg.SupabaseJWTSecret)
@@ -101,12 +173,34 @@ func main() {
 	}
 
 	router := gin.Default()
-	api.SetupRoutes(router, docHandler, questionHandler, authHandler, authMiddleware)
+	api.SetupRoutes(router, docHandler, questionHandler, authHandler, jobHandler, authMiddleware)
 
-	// 8. Run
+	// 8. Run until interrupted
 	port := cfg.Port
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Server failed to allow: %v", err)
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		log.Printf("Server starting on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed to allow: %v", err)
+		}
+	}()
+
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+	log.Printf("Shutting down")
+
+	// 9. Drain requests and workers, then write out what the vector store has not saved yet
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: server shutdown: %v", err)
+	}
+	stopJobs()
+	jobPool.Wait()
+	if closer, ok := vectorRepo.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Warning: failed to close vector store: %v", err)
+		}
 	}
 }
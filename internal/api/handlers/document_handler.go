@@ -2,15 +2,23 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
 
+	"backend/internal/domain"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type IngestionService interface {
-	Ingest(ctx context.Context, reader io.ReaderAt, size int64, subject string, chapter int, language string) error
+	Enqueue(ctx context.Context, reader io.ReaderAt, size int64, doc *domain.Document) (*domain.IngestionJob, error)
+	ListDocuments(ctx context.Context, filter domain.DocumentFilter) ([]*domain.Document, error)
+	GetDocument(ctx context.Context, id uuid.UUID) (*domain.Document, error)
+	DeleteDocument(ctx context.Context, id uuid.UUID) error
+	ReindexDocument(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error)
 }
 
 type DocumentHandler struct {
@@ -21,19 +29,35 @@ func NewDocumentHandler(service IngestionService) *DocumentHandler {
 	return &DocumentHandler{service: service}
 }
 
+type ListDocumentsRequest struct {
+	Subject  string `form:"subject"`
+	Chapter  int    `form:"chapter" binding:"omitempty,gt=0"`
+	Language string `form:"language" binding:"omitempty,oneof=en bn"`
+	Limit    int    `form:"limit" binding:"omitempty,gt=0,lte=100"`
+	Offset   int    `form:"offset" binding:"omitempty,gte=0"`
+}
+
 // Upload godoc
-// @Summary      Upload a PDF document
-// @Description  Uploads a PDF textbook chapter for ingestion.
+// @Summary      Upload a document
+// @Description  Uploads a textbook, or a chapter of one, and queues it for ingestion. Poll the returned job for progress.
+// @Description  PDF, DOCX, EPUB, HTML, Markdown and plain text files are accepted; the format is detected from the content.
+// @Description  Without a chapter, chapters and sections are detected from the file's bookmarks or headings and recorded on each chunk.
+// @Description  Re-uploading an identical file for the same subject, chapter and language is a no-op and returns 200 with the existing document.
+// @Description  A new version of a file whose previous version is still queued or being ingested is rejected with 409.
 // @Tags         documents
 // @Accept       multipart/form-data
 // @Produce      json
-// @Param        file      formData  file    true  "PDF File"
-// @Param        chapter   formData  int     true  "Chapter Number"
-// @Param        subject   formData  string  true  "Subject Name"
-// @Param        language  formData  string  true  "Language (en/bn)"
+// @Param        file            formData  file    true   "Document file"
+// @Param        chapter         formData  int     false  "Chapter Number, for a single-chapter file; detected when omitted"
+// @Param        subject         formData  string  true   "Subject Name"
+// @Param        language        formData  string  true   "Language (en/bn)"
+// @Param        chunk_strategy  formData  string  false  "Chunk strategy (fixed/structured); defaults to the server's"
 // @Security     BearerAuth
-// @Success      200  {object}  map[string]string
+// @Success      200  {object}  map[string]interface{}
+// @Success      202  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      415  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /documents/upload [post]
 func (h *DocumentHandler) Upload(c *gin.Context) {
@@ -52,9 +76,19 @@ func (h *DocumentHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	chapter, err := strconv.Atoi(chapterStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "chapter must be an integer"})
+	// 0 leaves the chapters to be detected from the document
+	chapter := 0
+	if chapterStr != "" {
+		chapter, err = strconv.Atoi(chapterStr)
+		if err != nil || chapter <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "chapter must be a positive integer"})
+			return
+		}
+	}
+
+	strategy := domain.ChunkStrategy(c.PostForm("chunk_strategy"))
+	if !strategy.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "chunk_strategy must be fixed or structured"})
 		return
 	}
 
@@ -65,10 +99,196 @@ func (h *DocumentHandler) Upload(c *gin.Context) {
 	}
 	defer file.Close()
 
-	if err := h.service.Ingest(c.Request.Context(), file, fileHeader.Size, subject, chapter, language); err != nil {
+	doc := &domain.Document{
+		Filename:      fileHeader.Filename,
+		Subject:       subject,
+		Chapter:       chapter,
+		Language:      language,
+		UserID:        c.GetString("userID"),
+		ChunkStrategy: strategy,
+	}
+
+	job, err := h.service.Enqueue(c.Request.Context(), file, fileHeader.Size, doc)
+	if errors.Is(err, domain.ErrUnsupportedFormat) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, domain.ErrDocumentBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ingestion failed: " + err.Error()})
 		return
 	}
+	if job == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Document already ingested",
+			"data": gin.H{
+				"document": doc,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Document queued for ingestion",
+		"data": gin.H{
+			"job_id":   job.ID,
+			"document": doc,
+		},
+	})
+}
+
+// List godoc
+// @Summary      List documents
+// @Description  Lists uploaded documents, newest first, optionally filtered by subject, chapter and language.
+// @Tags         documents
+// @Produce      json
+// @Param        subject   query  string  false  "Subject Name"
+// @Param        chapter   query  int     false  "Chapter Number"
+// @Param        language  query  string  false  "Language (en/bn)"
+// @Param        limit     query  int     false  "Page size (max 100)"
+// @Param        offset    query  int     false  "Page offset"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /documents [get]
+func (h *DocumentHandler) List(c *gin.Context) {
+	var req ListDocumentsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Document uploaded successfully"})
+	docs, err := h.service.ListDocuments(c.Request.Context(), domain.DocumentFilter{
+		Subject:  req.Subject,
+		Chapter:  req.Chapter,
+		Language: req.Language,
+		Limit:    req.Limit,
+		Offset:   req.Offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    docs,
+	})
+}
+
+// Get godoc
+// @Summary      Get a document
+// @Description  Returns a single document and its ingestion status.
+// @Tags         documents
+// @Produce      json
+// @Param        id   path  string  true  "Document ID"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /documents/{id} [get]
+func (h *DocumentHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	doc, err := h.service.GetDocument(c.Request.Context(), id)
+	if err != nil {
+		respondRepoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    doc,
+	})
+}
+
+// Delete godoc
+// @Summary      Delete a document
+// @Description  Deletes a document together with its stored file and all of its chunks.
+// @Tags         documents
+// @Produce      json
+// @Param        id   path  string  true  "Document ID"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /documents/{id} [delete]
+func (h *DocumentHandler) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteDocument(c.Request.Context(), id); err != nil {
+		respondRepoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted successfully"})
+}
+
+// Reindex godoc
+// @Summary      Re-index a document
+// @Description  Queues a job that rebuilds a document's chunks and embeddings from its stored file. The current chunks stay searchable until the new ones replace them. Returns 409 while a job for the document is queued or running.
+// @Tags         documents
+// @Produce      json
+// @Param        id   path  string  true  "Document ID"
+// @Security     BearerAuth
+// @Success      202  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /documents/{id}/reindex [post]
+func (h *DocumentHandler) Reindex(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.service.ReindexDocument(c.Request.Context(), id)
+	if err != nil {
+		respondRepoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Document queued for re-indexing",
+		"data":    job,
+	})
+}
+
+// parseIDParam reads the :id path parameter, writing a 400 response if it is not a UUID
+func parseIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid UUID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// respondRepoError maps domain.ErrNotFound to 404 and anything else to 500
+func respondRepoError(c *gin.Context, err error) {
+	if errors.Is(err, domain.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, domain.ErrDocumentBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
@@ -3,13 +3,17 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"backend/internal/domain"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,11 +23,43 @@ type MockIngestionService struct {
 	mock.Mock
 }
 
-func (m *MockIngestionService) Ingest(ctx context.Context, reader io.ReaderAt, size int64, subject string, chapter int, language string) error {
-	args := m.Called(ctx, reader, size, subject, chapter, language)
+func (m *MockIngestionService) Enqueue(ctx context.Context, reader io.ReaderAt, size int64, doc *domain.Document) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, reader, size, doc)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
+func (m *MockIngestionService) ListDocuments(ctx context.Context, filter domain.DocumentFilter) ([]*domain.Document, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Document), args.Error(1)
+}
+
+func (m *MockIngestionService) GetDocument(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Document), args.Error(1)
+}
+
+func (m *MockIngestionService) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockIngestionService) ReindexDocument(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
 // IngestionService interface is defined in handler package if we want to decoupling
 // But for test we mocked it.
 // We need to make sure NewDocumentHandler accepts the mock.
@@ -54,10 +90,286 @@ func TestUploadDocument(t *testing.T) {
 	c.Request = req
 
 	// Mock Expectation
-	mockService.On("Ingest", mock.Anything, mock.Anything, mock.Anything, "Physics", 1, "en").Return(nil)
+	mockService.On("Enqueue", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.Filename == "test.pdf" && d.Subject == "Physics" && d.Chapter == 1 && d.Language == "en"
+	})).Return(&domain.IngestionJob{ID: uuid.New(), Status: domain.JobStatusQueued}, nil)
+
+	handler.Upload(c)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestUploadDocument_AlreadyIngested(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.pdf")
+	part.Write([]byte("fake pdf content"))
+	writer.WriteField("chapter", "1")
+	writer.WriteField("subject", "Physics")
+	writer.WriteField("language", "en")
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
+
+	// A nil job means an identical file was already ingested
+	mockService.On("Enqueue", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+
+	handler.Upload(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Document already ingested")
+	mockService.AssertExpectations(t)
+}
+
+func TestUploadDocument_WhileIngesting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.pdf")
+	part.Write([]byte("fake pdf, second edition"))
+	writer.WriteField("chapter", "1")
+	writer.WriteField("subject", "Physics")
+	writer.WriteField("language", "en")
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
+
+	mockService.On("Enqueue", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, domain.ErrDocumentBusy)
+
+	handler.Upload(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestUploadDocument_WithoutChapter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "book.pdf")
+	part.Write([]byte("fake pdf content"))
+	writer.WriteField("subject", "Physics")
+	writer.WriteField("language", "en")
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
+
+	// Chapter 0 leaves chapters to be detected during ingestion
+	mockService.On("Enqueue", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.Filename == "book.pdf" && d.Chapter == 0
+	})).Return(&domain.IngestionJob{ID: uuid.New(), Status: domain.JobStatusQueued}, nil)
+
+	handler.Upload(c)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestUploadDocument_InvalidChapter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.pdf")
+	part.Write([]byte("fake pdf content"))
+	writer.WriteField("chapter", "0")
+	writer.WriteField("subject", "Physics")
+	writer.WriteField("language", "en")
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
 
 	handler.Upload(c)
 
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadDocument_UnsupportedFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "scan.png")
+	part.Write([]byte("fake image content"))
+	writer.WriteField("subject", "Physics")
+	writer.WriteField("language", "en")
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
+
+	mockService.On("Enqueue", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("%w: image/png", domain.ErrUnsupportedFormat))
+
+	handler.Upload(c)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Body.String(), "unsupported document format")
+}
+
+func TestUploadDocument_InvalidChunkStrategy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.pdf")
+	part.Write([]byte("fake pdf content"))
+	writer.WriteField("chapter", "1")
+	writer.WriteField("subject", "Physics")
+	writer.WriteField("language", "en")
+	writer.WriteField("chunk_strategy", "semantic")
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Request = req
+
+	handler.Upload(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "Enqueue", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListDocuments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/documents?subject=Physics&chapter=2", nil)
+
+	mockService.On("ListDocuments", mock.Anything, domain.DocumentFilter{Subject: "Physics", Chapter: 2, Limit: 50}).
+		Return([]*domain.Document{{ID: uuid.New(), Subject: "Physics", Chapter: 2}}, nil)
+
+	handler.List(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("invalid id", func(t *testing.T) {
+		handler := NewDocumentHandler(new(MockIngestionService))
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/documents/nope", nil)
+		c.Params = gin.Params{{Key: "id", Value: "nope"}}
+
+		handler.Get(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockService := new(MockIngestionService)
+		handler := NewDocumentHandler(mockService)
+		id := uuid.New()
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/documents/"+id.String(), nil)
+		c.Params = gin.Params{{Key: "id", Value: id.String()}}
+
+		mockService.On("GetDocument", mock.Anything, id).Return(nil, domain.ErrNotFound)
+
+		handler.Get(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestDeleteDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+	id := uuid.New()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("DELETE", "/documents/"+id.String(), nil)
+	c.Params = gin.Params{{Key: "id", Value: id.String()}}
+
+	mockService.On("DeleteDocument", mock.Anything, id).Return(nil)
+
+	handler.Delete(c)
+
 	assert.Equal(t, http.StatusOK, w.Code)
 	mockService.AssertExpectations(t)
 }
+
+func TestReindexDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockIngestionService)
+	handler := NewDocumentHandler(mockService)
+	id := uuid.New()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", "/documents/"+id.String()+"/reindex", nil)
+	c.Params = gin.Params{{Key: "id", Value: id.String()}}
+
+	mockService.On("ReindexDocument", mock.Anything, id).Return(&domain.IngestionJob{ID: uuid.New(), DocumentID: id, Status: domain.JobStatusQueued}, nil)
+
+	handler.Reindex(c)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"backend/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type JobService interface {
+	GetJob(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error)
+}
+
+type JobHandler struct {
+	service JobService
+}
+
+func NewJobHandler(service JobService) *JobHandler {
+	return &JobHandler{service: service}
+}
+
+// Get godoc
+// @Summary      Get an ingestion job
+// @Description  Reports the status and progress of a document ingestion job.
+// @Tags         jobs
+// @Produce      json
+// @Param        id   path  string  true  "Job ID"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /jobs/{id} [get]
+func (h *JobHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.service.GetJob(c.Request.Context(), id)
+	if err != nil {
+		respondRepoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
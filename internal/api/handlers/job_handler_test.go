@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockJobService struct {
+	mock.Mock
+}
+
+func (m *MockJobService) GetJob(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
+func TestGetJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockJobService)
+	handler := NewJobHandler(mockService)
+	id := uuid.New()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/jobs/"+id.String(), nil)
+	c.Params = gin.Params{{Key: "id", Value: id.String()}}
+
+	mockService.On("GetJob", mock.Anything, id).Return(&domain.IngestionJob{
+		ID:              id,
+		Status:          domain.JobStatusFailed,
+		ChunksProcessed: 249,
+		ChunksTotal:     300,
+		Error:           "embedding failed for chunk 249",
+	}, nil)
+
+	handler.Get(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data domain.IngestionJob `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, domain.JobStatusFailed, body.Data.Status)
+	assert.Equal(t, 249, body.Data.ChunksProcessed)
+	assert.Equal(t, 300, body.Data.ChunksTotal)
+	assert.Equal(t, "embedding failed for chunk 249", body.Data.Error)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetJob_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockJobService)
+	handler := NewJobHandler(mockService)
+	id := uuid.New()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/jobs/"+id.String(), nil)
+	c.Params = gin.Params{{Key: "id", Value: id.String()}}
+
+	mockService.On("GetJob", mock.Anything, id).Return(nil, domain.ErrNotFound)
+
+	handler.Get(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
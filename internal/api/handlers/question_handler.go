@@ -2,13 +2,23 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
+
+	"backend/internal/domain"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type GeneratorService interface {
-	GenerateQuestions(ctx context.Context, topic string, chapter, count int, language string) ([]string, error)
+	GenerateQuestions(ctx context.Context, req domain.GenerationRequest) ([]*domain.Question, error)
+	GenerateQuestionsStream(ctx context.Context, req domain.GenerationRequest, onQuestion func(*domain.Question, domain.GenerationProgress) error) (domain.GenerationProgress, error)
+	ListQuestions(ctx context.Context, filter domain.QuestionFilter) ([]*domain.Question, error)
+	GetQuestion(ctx context.Context, id uuid.UUID) (*domain.Question, error)
+	UpdateQuestion(ctx context.Context, id uuid.UUID, edit domain.Question) (*domain.Question, error)
+	DeleteQuestion(ctx context.Context, id uuid.UUID) error
 }
 
 type QuestionHandler struct {
@@ -21,21 +31,90 @@ func NewQuestionHandler(service GeneratorService) *QuestionHandler {
 
 type GenerateRequest struct {
 	Topic    string `json:"topic" binding:"required"`
-	Chapter  int    `json:"chapter" binding:"required,gt=0"`
-	Count    int    `json:"count" binding:"required,gt=0"`
+	Chapter  int    `json:"chapter" binding:"omitempty,gt=0"`
+	Count    int    `json:"count" binding:"omitempty,gt=0"`
 	Language string `json:"language" binding:"required,oneof=en bn"`
+	// Types is how many questions of each type to generate; if empty, count short-answer questions are generated
+	Types map[domain.QuestionType]int `json:"types,omitempty"`
+
+	Subject      string      `json:"subject,omitempty"`
+	Chapters     []int       `json:"chapters,omitempty"`
+	DocumentIDs  []uuid.UUID `json:"document_ids,omitempty"`
+	PageFrom     int         `json:"page_from,omitempty"`
+	PageTo       int         `json:"page_to,omitempty"`
+	MinScore     float64     `json:"min_score,omitempty"`
+	ContextLimit int         `json:"context_limit,omitempty"`
+	// SearchMode is how context is retrieved: vector (the default), keyword or hybrid
+	SearchMode domain.SearchMode `json:"search_mode,omitempty" binding:"omitempty,oneof=vector keyword hybrid"`
+}
+
+type ListQuestionsRequest struct {
+	Subject    string `form:"subject"`
+	Chapter    int    `form:"chapter" binding:"omitempty,gt=0"`
+	Topic      string `form:"topic"`
+	Type       string `form:"type" binding:"omitempty,oneof=mcq short_answer numerical true_false"`
+	Difficulty string `form:"difficulty" binding:"omitempty,oneof=easy medium hard"`
+	Language   string `form:"language" binding:"omitempty,oneof=en bn"`
+	Query      string `form:"q"`
+	Mine       bool   `form:"mine"`
+	Limit      int    `form:"limit" binding:"omitempty,gt=0,lte=100"`
+	Offset     int    `form:"offset" binding:"omitempty,gte=0"`
+}
+
+type UpdateQuestionRequest struct {
+	Type        domain.QuestionType `json:"type" binding:"required,oneof=mcq short_answer numerical true_false"`
+	Stem        string              `json:"stem" binding:"required"`
+	Options     []string            `json:"options,omitempty"`
+	Answer      string              `json:"answer" binding:"required"`
+	Explanation string              `json:"explanation,omitempty"`
+	Difficulty  domain.Difficulty   `json:"difficulty" binding:"required,oneof=easy medium hard"`
+	Marks       int                 `json:"marks" binding:"required,gt=0"`
+}
+
+// Mix returns the requested number of questions per type
+func (r GenerateRequest) Mix() domain.QuestionMix {
+	if len(r.Types) == 0 {
+		return domain.QuestionMix{domain.QuestionTypeShortAnswer: r.Count}
+	}
+	return domain.QuestionMix(r.Types)
+}
+
+// SearchQuery converts the request's retrieval constraints into a domain query
+func (r GenerateRequest) SearchQuery() domain.SearchQuery {
+	chapters := r.Chapters
+	if r.Chapter > 0 {
+		chapters = append([]int{r.Chapter}, chapters...)
+	}
+
+	return domain.SearchQuery{
+		Subject:     r.Subject,
+		Chapters:    chapters,
+		Languages:   []string{r.Language},
+		DocumentIDs: r.DocumentIDs,
+		PageFrom:    r.PageFrom,
+		PageTo:      r.PageTo,
+		MinScore:    r.MinScore,
+		Limit:       r.ContextLimit,
+		Mode:        r.SearchMode,
+	}
 }
 
 // Generate godoc
 // @Summary      Generate Questions
-// @Description  Generates exam-style questions based on a topic and chapter context.
+// @Description  Generates exam-style questions with answer keys based on a topic and chapter context, and saves them to the question bank.
+// @Description  Set types to request a mix of mcq, short_answer, numerical and true_false questions; otherwise count short-answer questions are generated.
+// @Description  With stream=true, or an Accept header of text/event-stream, the response is a stream of Server-Sent Events instead:
+// @Description  a "question" event for each question as soon as it is generated and saved, each followed by a "progress" event,
+// @Description  then a "done" event with the final counts, or an "error" event if generation fails part way.
 // @Tags         questions
 // @Accept       json
-// @Produce      json
-// @Param        request  body      GenerateRequest  true  "Generation Request"
+// @Produce      json,text/event-stream
+// @Param        request  body      GenerateRequest  true   "Generation Request"
+// @Param        stream   query     bool             false  "Stream questions as Server-Sent Events"
 // @Security     BearerAuth
 // @Success      200  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]string
+// @Failure      422  {object}  map[string]string  "No context relevant enough to generate from"
 // @Failure      500  {object}  map[string]string
 // @Router       /questions/generate [post]
 func (h *QuestionHandler) Generate(c *gin.Context) {
@@ -45,9 +124,31 @@ func (h *QuestionHandler) Generate(c *gin.Context) {
 		return
 	}
 
-	questions, err := h.service.GenerateQuestions(c.Request.Context(), req.Topic, req.Chapter, req.Count, req.Language)
+	mix := req.Mix()
+	if err := mix.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Types) > 0 && req.Count > 0 && req.Count != mix.Total() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must match the total of types"})
+		return
+	}
+
+	genReq := domain.GenerationRequest{
+		Topic:    req.Topic,
+		Language: req.Language,
+		Mix:      mix,
+		Query:    req.SearchQuery(),
+		UserID:   c.GetString("userID"),
+	}
+	if wantsStream(c) {
+		h.generateStream(c, genReq)
+		return
+	}
+
+	questions, err := h.service.GenerateQuestions(c.Request.Context(), genReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(generationErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -58,3 +159,226 @@ func (h *QuestionHandler) Generate(c *gin.Context) {
 		},
 	})
 }
+
+// wantsStream reports whether the client asked for Server-Sent Events
+func wantsStream(c *gin.Context) bool {
+	return c.Query("stream") == "true" || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+func generationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrInsufficientContext):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// generateStream sends each question as an SSE event as soon as it is ready.
+// The event stream only starts with the first question, so a request that
+// fails before then gets an ordinary JSON error response.
+func (h *QuestionHandler) generateStream(c *gin.Context, req domain.GenerationRequest) {
+	ctx := c.Request.Context()
+	started := false
+	start := func() {
+		if !started {
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Header("X-Accel-Buffering", "no")
+			started = true
+		}
+	}
+
+	progress, err := h.service.GenerateQuestionsStream(ctx, req, func(question *domain.Question, progress domain.GenerationProgress) error {
+		// Stop generating once the client has gone away
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start()
+		c.SSEvent("question", question)
+		c.SSEvent("progress", progress)
+		c.Writer.Flush()
+		return nil
+	})
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		if !started {
+			c.JSON(generationErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.SSEvent("error", gin.H{"error": err.Error()})
+		c.Writer.Flush()
+		return
+	}
+
+	start()
+	c.SSEvent("done", progress)
+	c.Writer.Flush()
+}
+
+// List godoc
+// @Summary      List questions
+// @Description  Lists questions in the question bank, newest first, optionally filtered. With q, runs a full-text search over stems, options and explanations and orders by relevance.
+// @Tags         questions
+// @Produce      json
+// @Param        subject     query  string  false  "Subject Name"
+// @Param        chapter     query  int     false  "Chapter Number"
+// @Param        topic       query  string  false  "Topic (substring match)"
+// @Param        type        query  string  false  "Question type (mcq/short_answer/numerical/true_false)"
+// @Param        difficulty  query  string  false  "Difficulty (easy/medium/hard)"
+// @Param        language    query  string  false  "Language (en/bn)"
+// @Param        q           query  string  false  "Full-text search"
+// @Param        mine        query  bool    false  "Only questions generated by the caller"
+// @Param        limit       query  int     false  "Page size (max 100)"
+// @Param        offset      query  int     false  "Page offset"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /questions [get]
+func (h *QuestionHandler) List(c *gin.Context) {
+	var req ListQuestionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+
+	filter := domain.QuestionFilter{
+		Subject:    req.Subject,
+		Chapter:    req.Chapter,
+		Topic:      req.Topic,
+		Type:       domain.QuestionType(req.Type),
+		Difficulty: domain.Difficulty(req.Difficulty),
+		Language:   req.Language,
+		Search:     req.Query,
+		Limit:      req.Limit,
+		Offset:     req.Offset,
+	}
+	if req.Mine {
+		filter.UserID = c.GetString("userID")
+	}
+
+	questions, err := h.service.ListQuestions(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    questions,
+	})
+}
+
+// Get godoc
+// @Summary      Get a question
+// @Description  Returns a single question from the question bank.
+// @Tags         questions
+// @Produce      json
+// @Param        id   path  string  true  "Question ID"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /questions/{id} [get]
+func (h *QuestionHandler) Get(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	question, err := h.service.GetQuestion(c.Request.Context(), id)
+	if err != nil {
+		respondRepoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    question,
+	})
+}
+
+// Update godoc
+// @Summary      Edit a question
+// @Description  Replaces a question's content. The edited question is validated with the same rules as generated ones.
+// @Tags         questions
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string                 true  "Question ID"
+// @Param        request  body  UpdateQuestionRequest  true  "Edited question"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /questions/{id} [put]
+func (h *QuestionHandler) Update(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	question, err := h.service.UpdateQuestion(c.Request.Context(), id, domain.Question{
+		Type:        req.Type,
+		Stem:        req.Stem,
+		Options:     req.Options,
+		Answer:      req.Answer,
+		Explanation: req.Explanation,
+		Difficulty:  req.Difficulty,
+		Marks:       req.Marks,
+	})
+	if errors.Is(err, domain.ErrInvalidQuestion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondRepoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    question,
+	})
+}
+
+// Delete godoc
+// @Summary      Delete a question
+// @Description  Removes a question from the question bank.
+// @Tags         questions
+// @Produce      json
+// @Param        id   path  string  true  "Question ID"
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /questions/{id} [delete]
+func (h *QuestionHandler) Delete(c *gin.Context) {
+	id, ok := parseIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteQuestion(c.Request.Context(), id); err != nil {
+		respondRepoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Question deleted successfully"})
+}
@@ -3,11 +3,16 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"backend/internal/domain"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -17,12 +22,54 @@ type MockGeneratorService struct {
 	mock.Mock
 }
 
-func (m *MockGeneratorService) GenerateQuestions(ctx context.Context, topic string, chapter, count int, language string) ([]string, error) {
-	args := m.Called(ctx, topic, chapter, count, language)
+func (m *MockGeneratorService) GenerateQuestions(ctx context.Context, req domain.GenerationRequest) ([]*domain.Question, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Question), args.Error(1)
+}
+
+// GenerateQuestionsStream passes the questions given to Return to onQuestion
+// one by one, then returns the given progress and error
+func (m *MockGeneratorService) GenerateQuestionsStream(ctx context.Context, req domain.GenerationRequest, onQuestion func(*domain.Question, domain.GenerationProgress) error) (domain.GenerationProgress, error) {
+	args := m.Called(ctx, req)
+	questions, _ := args.Get(0).([]*domain.Question)
+	for i, q := range questions {
+		if err := onQuestion(q, domain.GenerationProgress{Requested: req.Mix.Total(), Generated: i + 1}); err != nil {
+			return domain.GenerationProgress{}, err
+		}
+	}
+	return args.Get(1).(domain.GenerationProgress), args.Error(2)
+}
+
+func (m *MockGeneratorService) ListQuestions(ctx context.Context, filter domain.QuestionFilter) ([]*domain.Question, error) {
+	args := m.Called(ctx, filter)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]string), args.Error(1)
+	return args.Get(0).([]*domain.Question), args.Error(1)
+}
+
+func (m *MockGeneratorService) GetQuestion(ctx context.Context, id uuid.UUID) (*domain.Question, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Question), args.Error(1)
+}
+
+func (m *MockGeneratorService) UpdateQuestion(ctx context.Context, id uuid.UUID, edit domain.Question) (*domain.Question, error) {
+	args := m.Called(ctx, id, edit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Question), args.Error(1)
+}
+
+func (m *MockGeneratorService) DeleteQuestion(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
 }
 
 func TestGenerateQuestions(t *testing.T) {
@@ -41,10 +88,305 @@ func TestGenerateQuestions(t *testing.T) {
 	c.Request = req
 
 	// Mock Expectation
-	mockService.On("GenerateQuestions", mock.Anything, "Physics", 1, 5, "en").Return([]string{"Q1", "Q2"}, nil)
+	c.Set("userID", "user-1")
+
+	mockService.On("GenerateQuestions", mock.Anything, mock.MatchedBy(func(r domain.GenerationRequest) bool {
+		q := r.Query
+		return r.Topic == "Physics" && r.Language == "en" && r.UserID == "user-1" &&
+			r.Mix[domain.QuestionTypeShortAnswer] == 5 &&
+			len(q.Chapters) == 1 && q.Chapters[0] == 1 && q.Languages[0] == "en"
+	})).Return([]*domain.Question{{Type: domain.QuestionTypeShortAnswer, Stem: "Q1"}}, nil)
+
+	handler.Generate(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGenerateQuestions_InvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockGeneratorService)
+	handler := NewQuestionHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	input := `{"topic": "Physics", "chapter": 1, "count": 5, "language": "en", "page_from": 10, "page_to": 2}`
+	req, _ := http.NewRequest("POST", "/questions/generate", bytes.NewBufferString(input))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+
+	mockService.On("GenerateQuestions", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("retrieval failed: %w", domain.ErrInvalidQuery))
 
 	handler.Generate(c)
 
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGenerateQuestions_InsufficientContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockGeneratorService)
+	handler := NewQuestionHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	input := `{"topic": "Quantum gravity", "count": 5, "language": "en", "min_score": 0.8}`
+	req, _ := http.NewRequest("POST", "/questions/generate", bytes.NewBufferString(input))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+
+	mockService.On("GenerateQuestions", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("%w: no context relevant to topic Quantum gravity found in chapters []", domain.ErrInsufficientContext))
+
+	handler.Generate(c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "insufficient context")
+	mockService.AssertExpectations(t)
+}
+
+func TestGenerateQuestions_Stream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockGeneratorService)
+	handler := NewQuestionHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	input := `{"topic": "Physics", "count": 2, "language": "en"}`
+	req, _ := http.NewRequest("POST", "/questions/generate?stream=true", bytes.NewBufferString(input))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+
+	questions := []*domain.Question{
+		{Type: domain.QuestionTypeShortAnswer, Stem: "Q1"},
+		{Type: domain.QuestionTypeShortAnswer, Stem: "Q2"},
+	}
+	mockService.On("GenerateQuestionsStream", mock.Anything, mock.Anything).
+		Return(questions, domain.GenerationProgress{Requested: 2, Generated: 2}, nil)
+
+	handler.Generate(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+	body := w.Body.String()
+	assert.Equal(t, 2, strings.Count(body, "event:question\n"))
+	assert.Equal(t, 2, strings.Count(body, "event:progress\n"))
+	assert.Contains(t, body, `"stem":"Q2"`)
+	assert.Contains(t, body, "event:done\ndata:{\"requested\":2,\"generated\":2,\"rejected\":0}")
+	mockService.AssertExpectations(t)
+}
+
+func TestGenerateQuestions_StreamFailsBeforeFirstQuestion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockGeneratorService)
+	handler := NewQuestionHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	input := `{"topic": "Physics", "count": 2, "language": "en"}`
+	req, _ := http.NewRequest("POST", "/questions/generate", bytes.NewBufferString(input))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	c.Request = req
+
+	mockService.On("GenerateQuestionsStream", mock.Anything, mock.Anything).
+		Return(nil, domain.GenerationProgress{Requested: 2}, fmt.Errorf("retrieval failed: %w", domain.ErrInvalidQuery))
+
+	handler.Generate(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NotContains(t, w.Body.String(), "event:")
+	mockService.AssertExpectations(t)
+}
+
+func TestGenerateQuestions_StreamStopsWhenClientDisconnects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockGeneratorService)
+	handler := NewQuestionHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	input := `{"topic": "Physics", "count": 2, "language": "en"}`
+	req, _ := http.NewRequestWithContext(ctx, "POST", "/questions/generate?stream=true", bytes.NewBufferString(input))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+
+	mockService.On("GenerateQuestionsStream", mock.Anything, mock.Anything).
+		Return([]*domain.Question{{Stem: "Q1"}}, domain.GenerationProgress{}, nil)
+
+	handler.Generate(c)
+
+	assert.Empty(t, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestGenerateQuestions_TypeMix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockGeneratorService)
+	handler := NewQuestionHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	input := `{"topic": "Physics", "language": "bn", "types": {"mcq": 3, "numerical": 2}}`
+	req, _ := http.NewRequest("POST", "/questions/generate", bytes.NewBufferString(input))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+
+	mix := domain.QuestionMix{domain.QuestionTypeMCQ: 3, domain.QuestionTypeNumerical: 2}
+	mockService.On("GenerateQuestions", mock.Anything, mock.MatchedBy(func(r domain.GenerationRequest) bool {
+		return r.Language == "bn" && assert.ObjectsAreEqual(mix, r.Mix)
+	})).Return([]*domain.Question{{Type: domain.QuestionTypeMCQ, Stem: "Q1"}}, nil)
+
+	handler.Generate(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGenerateQuestions_InvalidMix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"nothing requested", `{"topic": "Physics", "language": "en"}`},
+		{"unknown type", `{"topic": "Physics", "language": "en", "types": {"essay": 1}}`},
+		{"count disagrees with types", `{"topic": "Physics", "language": "en", "count": 4, "types": {"mcq": 3}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockGeneratorService)
+			handler := NewQuestionHandler(mockService)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req, _ := http.NewRequest("POST", "/questions/generate", bytes.NewBufferString(tt.input))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+
+			handler.Generate(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockService.AssertNotCalled(t, "GenerateQuestions", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestListQuestions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockGeneratorService)
+	handler := NewQuestionHandler(mockService)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set("userID", "user-1")
+	c.Request, _ = http.NewRequest("GET", "/questions?subject=Physics&type=mcq&difficulty=hard&q=inertia&mine=true", nil)
+
+	mockService.On("ListQuestions", mock.Anything, domain.QuestionFilter{
+		Subject:    "Physics",
+		Type:       domain.QuestionTypeMCQ,
+		Difficulty: domain.DifficultyHard,
+		Search:     "inertia",
+		UserID:     "user-1",
+		Limit:      50,
+	}).Return([]*domain.Question{}, nil)
+
+	handler.List(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestListQuestions_InvalidType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewQuestionHandler(new(MockGeneratorService))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/questions?type=essay", nil)
+
+	handler.List(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateQuestion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"updated", nil, http.StatusOK},
+		{"invalid", fmt.Errorf("%w: mcq answer is not one of the options", domain.ErrInvalidQuestion), http.StatusBadRequest},
+		{"not found", domain.ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockGeneratorService)
+			handler := NewQuestionHandler(mockService)
+
+			id := uuid.New()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = gin.Params{{Key: "id", Value: id.String()}}
+			input := `{"type": "mcq", "stem": "Which law?", "options": ["First", "Second", "Third"], "answer": "First", "difficulty": "easy", "marks": 1}`
+			c.Request, _ = http.NewRequest("PUT", "/questions/"+id.String(), bytes.NewBufferString(input))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			var result interface{}
+			if tt.err == nil {
+				result = &domain.Question{ID: id}
+			}
+			mockService.On("UpdateQuestion", mock.Anything, id, mock.MatchedBy(func(q domain.Question) bool {
+				return q.Type == domain.QuestionTypeMCQ && len(q.Options) == 3
+			})).Return(result, tt.err)
+
+			handler.Update(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteQuestion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockGeneratorService)
+	handler := NewQuestionHandler(mockService)
+
+	id := uuid.New()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: id.String()}}
+	c.Request, _ = http.NewRequest("DELETE", "/questions/"+id.String(), nil)
+
+	mockService.On("DeleteQuestion", mock.Anything, id).Return(nil)
+
+	handler.Delete(c)
+
 	assert.Equal(t, http.StatusOK, w.Code)
 	mockService.AssertExpectations(t)
 }
@@ -15,6 +15,7 @@ func SetupRoutes(router *gin.Engine,
 	docHandler *handlers.DocumentHandler,
 	questionHandler *handlers.QuestionHandler,
 	authHandler *handlers.AuthHandler,
+	jobHandler *handlers.JobHandler,
 	authMiddleware *middleware.AuthMiddleware,
 ) {
 	// Public Routes
@@ -44,5 +45,14 @@ func SetupRoutes(router *gin.Engine,
 	}
 
 	api.POST("/questions/generate", questionHandler.Generate)
+	api.GET("/questions", questionHandler.List)
+	api.GET("/questions/:id", questionHandler.Get)
+	api.PUT("/questions/:id", questionHandler.Update)
+	api.DELETE("/questions/:id", questionHandler.Delete)
 	api.POST("/documents/upload", docHandler.Upload)
+	api.GET("/documents", docHandler.List)
+	api.GET("/documents/:id", docHandler.Get)
+	api.DELETE("/documents/:id", docHandler.Delete)
+	api.POST("/documents/:id/reindex", docHandler.Reindex)
+	api.GET("/jobs/:id", jobHandler.Get)
 }
@@ -3,6 +3,16 @@ package config
 import (
 	"errors"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/domain"
+	"backend/internal/ingestion"
+	"backend/internal/migrations"
+	"backend/internal/provider"
+	"backend/internal/repository"
+	"backend/internal/resilience"
 
 	"github.com/joho/godotenv"
 )
@@ -16,7 +26,61 @@ type Config struct {
 	SupabaseServiceRoleKey string
 	SupabaseJWTSecret      string
 	DatabaseURL            string
+	AutoMigrate            bool
 	GeminiAPIKey           string
+	IngestionWorkers       int
+	JobMaxAttempts         int
+	EmbedBatchSize         int
+	EmbedConcurrency       int
+	// ChunkStrategy is how uploads that don't choose one are chunked
+	ChunkStrategy domain.ChunkStrategy
+	// NormalizeSteps clean up parsed text before chunking, in order
+	NormalizeSteps []string
+	// VectorStore is "postgres" or "memory"; VectorSnapshot persists the memory store
+	VectorStore    string
+	VectorSnapshot string
+	// The pgvector index ("hnsw", "ivfflat" or "none"), its build parameters,
+	// and the search settings every query uses unless it sets its own
+	VectorIndexMethod  string
+	HNSWM              int
+	HNSWEfConstruction int
+	HNSWEfSearch       int
+	IVFFlatLists       int
+	IVFFlatProbes      int
+	// RetrievalMinScore is the cosine similarity context chunks need to be
+	// used for generation, unless a request sets its own
+	RetrievalMinScore float64
+	// RetrievalMMR re-ranks context for diversity; lambda 1 is relevance only
+	RetrievalMMR            bool
+	RetrievalMMRLambda      float64
+	RetrievalMMRFetchFactor int
+	// ContextTokens is the token budget for the context in generation prompts
+	ContextTokens int
+
+	// Model providers (gemini, openai, ollama or fake); embeddings use the chat
+	// provider, URL and key unless EMBEDDING_PROVIDER is set
+	LLMProvider         string
+	LLMBaseURL          string
+	LLMAPIKey           string
+	ChatModel           string
+	Temperature         *float32
+	TopP                *float32
+	MaxOutputTokens     int
+	LLMFixture          string
+	EmbeddingProvider   string
+	EmbeddingBaseURL    string
+	EmbeddingAPIKey     string
+	EmbeddingModel      string
+	EmbeddingDimensions int
+
+	// Retries, rate limits and circuit breaking for model API calls
+	RetryMaxAttempts          int
+	RetryBaseDelay            time.Duration
+	RetryMaxDelay             time.Duration
+	EmbedRequestsPerMinute    int
+	GenerateRequestsPerMinute int
+	BreakerThreshold          int
+	BreakerCooldown           time.Duration
 }
 
 // Load reads configuration from environment variables
@@ -28,22 +92,85 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		Environment:            getEnv("ENV", "development"),
-		Port:                   getEnv("PORT", "8080"),
-		SupabaseURL:            os.Getenv("SUPABASE_URL"),
-		SupabaseAnonKey:        os.Getenv("SUPABASE_ANON_KEY"),
-		SupabaseServiceRoleKey: os.Getenv("SUPABASE_SERVICE_ROLE_KEY"),
-		SupabaseJWTSecret:      os.Getenv("SUPABASE_JWT_SECRET"),
-		DatabaseURL:            os.Getenv("DATABASE_URL"),
-		GeminiAPIKey:           os.Getenv("GEMINI_API_KEY"),
+		Environment:             getEnv("ENV", "development"),
+		Port:                    getEnv("PORT", "8080"),
+		SupabaseURL:             os.Getenv("SUPABASE_URL"),
+		SupabaseAnonKey:         os.Getenv("SUPABASE_ANON_KEY"),
+		SupabaseServiceRoleKey:  os.Getenv("SUPABASE_SERVICE_ROLE_KEY"),
+		SupabaseJWTSecret:       os.Getenv("SUPABASE_JWT_SECRET"),
+		DatabaseURL:             os.Getenv("DATABASE_URL"),
+		AutoMigrate:             getEnvBool("AUTO_MIGRATE", false),
+		GeminiAPIKey:            os.Getenv("GEMINI_API_KEY"),
+		IngestionWorkers:        getEnvInt("INGESTION_WORKERS", 2),
+		JobMaxAttempts:          getEnvInt("JOB_MAX_ATTEMPTS", 3),
+		EmbedBatchSize:          getEnvInt("EMBED_BATCH_SIZE", 16),
+		EmbedConcurrency:        getEnvInt("EMBED_CONCURRENCY", 4),
+		ChunkStrategy:           domain.ChunkStrategy(getEnv("CHUNK_STRATEGY", string(domain.ChunkStrategyStructured))),
+		NormalizeSteps:          getEnvList("NORMALIZE_STEPS", ingestion.DefaultNormalizeSteps),
+		VectorStore:             getEnv("VECTOR_STORE", "postgres"),
+		VectorSnapshot:          os.Getenv("VECTOR_SNAPSHOT"),
+		VectorIndexMethod:       getEnv("VECTOR_INDEX", "hnsw"),
+		HNSWM:                   getEnvInt("HNSW_M", 16),
+		HNSWEfConstruction:      getEnvInt("HNSW_EF_CONSTRUCTION", 64),
+		HNSWEfSearch:            getEnvInt("HNSW_EF_SEARCH", 0),
+		IVFFlatLists:            getEnvInt("IVFFLAT_LISTS", 0),
+		IVFFlatProbes:           getEnvInt("IVFFLAT_PROBES", 0),
+		RetrievalMinScore:       getEnvFloat("RETRIEVAL_MIN_SCORE", 0),
+		RetrievalMMR:            getEnvBool("RETRIEVAL_MMR", false),
+		RetrievalMMRLambda:      getEnvFloat("RETRIEVAL_MMR_LAMBDA", 0.7),
+		RetrievalMMRFetchFactor: getEnvInt("RETRIEVAL_MMR_FETCH_FACTOR", 4),
+		ContextTokens:           getEnvInt("CONTEXT_TOKENS", 6000),
+
+		LLMProvider:         getEnv("LLM_PROVIDER", "gemini"),
+		LLMBaseURL:          os.Getenv("LLM_BASE_URL"),
+		LLMAPIKey:           os.Getenv("LLM_API_KEY"),
+		ChatModel:           os.Getenv("CHAT_MODEL"),
+		Temperature:         getEnvFloat32("LLM_TEMPERATURE"),
+		TopP:                getEnvFloat32("LLM_TOP_P"),
+		MaxOutputTokens:     getEnvInt("LLM_MAX_OUTPUT_TOKENS", 0),
+		LLMFixture:          os.Getenv("LLM_FIXTURE"),
+		EmbeddingModel:      os.Getenv("EMBEDDING_MODEL"),
+		EmbeddingDimensions: getEnvInt("EMBEDDING_DIMENSIONS", 0),
+
+		RetryMaxAttempts:          getEnvInt("RETRY_MAX_ATTEMPTS", 5),
+		RetryBaseDelay:            getEnvDuration("RETRY_BASE_DELAY", 500*time.Millisecond),
+		RetryMaxDelay:             getEnvDuration("RETRY_MAX_DELAY", 30*time.Second),
+		EmbedRequestsPerMinute:    getEnvInt("EMBED_REQUESTS_PER_MINUTE", 0),
+		GenerateRequestsPerMinute: getEnvInt("GENERATE_REQUESTS_PER_MINUTE", 0),
+		BreakerThreshold:          getEnvInt("BREAKER_THRESHOLD", 10),
+		BreakerCooldown:           getEnvDuration("BREAKER_COOLDOWN", 30*time.Second),
+	}
+
+	cfg.EmbeddingProvider = cfg.LLMProvider
+	cfg.EmbeddingBaseURL, cfg.EmbeddingAPIKey = cfg.LLMBaseURL, cfg.LLMAPIKey
+	if name := os.Getenv("EMBEDDING_PROVIDER"); name != "" {
+		cfg.EmbeddingProvider = name
+		cfg.EmbeddingBaseURL, cfg.EmbeddingAPIKey = os.Getenv("EMBEDDING_BASE_URL"), os.Getenv("EMBEDDING_API_KEY")
 	}
 
 	if cfg.DatabaseURL == "" {
 		return nil, errors.New("DATABASE_URL is required")
 	}
-	if cfg.GeminiAPIKey == "" {
+	usesGemini := cfg.LLMProvider == "gemini" && cfg.LLMAPIKey == "" ||
+		cfg.EmbeddingProvider == "gemini" && cfg.EmbeddingAPIKey == ""
+	if usesGemini && cfg.GeminiAPIKey == "" {
 		return nil, errors.New("GEMINI_API_KEY is required")
 	}
+	if cfg.ChunkStrategy == "" || !cfg.ChunkStrategy.IsValid() {
+		return nil, errors.New("CHUNK_STRATEGY must be fixed or structured")
+	}
+	if _, err := ingestion.NewNormalizer(cfg.NormalizeSteps); err != nil {
+		return nil, errors.New("NORMALIZE_STEPS must list steps among running-heads, legacy-fonts, unicode and hyphenation")
+	}
+	if cfg.RetrievalMinScore < 0 || cfg.RetrievalMinScore > 1 {
+		return nil, errors.New("RETRIEVAL_MIN_SCORE must be between 0 and 1")
+	}
+	if cfg.ContextTokens < 0 {
+		return nil, errors.New("CONTEXT_TOKENS must not be negative")
+	}
+	if err := cfg.VectorIndex().Validate(); err != nil {
+		return nil, err
+	}
 	if cfg.SupabaseURL == "" {
 		return nil, errors.New("SUPABASE_URL is required")
 	}
@@ -60,9 +187,142 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// RetryPolicy returns the resilience settings for one model API, rate limited to requestsPerMinute
+func (c *Config) RetryPolicy(requestsPerMinute int) resilience.Config {
+	policy := resilience.DefaultConfig()
+	policy.MaxAttempts = c.RetryMaxAttempts
+	policy.BaseDelay = c.RetryBaseDelay
+	policy.MaxDelay = c.RetryMaxDelay
+	policy.RequestsPerMinute = requestsPerMinute
+	policy.BreakerThreshold = c.BreakerThreshold
+	policy.BreakerCooldown = c.BreakerCooldown
+	return policy
+}
+
+// ChatSettings configures the generation client
+func (c *Config) ChatSettings() provider.Settings {
+	return provider.Settings{
+		Name:            c.LLMProvider,
+		BaseURL:         c.LLMBaseURL,
+		APIKey:          c.apiKey(c.LLMProvider, c.LLMAPIKey),
+		Model:           c.ChatModel,
+		Temperature:     c.Temperature,
+		TopP:            c.TopP,
+		MaxOutputTokens: int32(c.MaxOutputTokens),
+		Fixture:         c.LLMFixture,
+	}
+}
+
+// EmbeddingSettings configures the embedding client
+// VectorDimensions is the size of the embeddings the database is migrated to
+// hold: EMBEDDING_DIMENSIONS, or the default when that is left to the model
+func (c *Config) VectorDimensions() int {
+	if c.EmbeddingDimensions > 0 {
+		return c.EmbeddingDimensions
+	}
+	return migrations.DefaultDimensions
+}
+
+func (c *Config) EmbeddingSettings() provider.Settings {
+	return provider.Settings{
+		Name:       c.EmbeddingProvider,
+		BaseURL:    c.EmbeddingBaseURL,
+		APIKey:     c.apiKey(c.EmbeddingProvider, c.EmbeddingAPIKey),
+		Model:      c.EmbeddingModel,
+		Dimensions: c.EmbeddingDimensions,
+	}
+}
+
+// VectorIndex describes the vector index migrations and "cli index rebuild" create
+func (c *Config) VectorIndex() repository.VectorIndex {
+	return repository.VectorIndex{
+		Method:         c.VectorIndexMethod,
+		M:              c.HNSWM,
+		EfConstruction: c.HNSWEfConstruction,
+		Lists:          c.IVFFlatLists,
+	}
+}
+
+// VectorSearch tunes searches on the vector index
+func (c *Config) VectorSearch() repository.SearchParams {
+	return repository.SearchParams{EfSearch: c.HNSWEfSearch, Probes: c.IVFFlatProbes}
+}
+
+// apiKey falls back to GEMINI_API_KEY for the Gemini provider
+func (c *Config) apiKey(name, key string) string {
+	if key == "" && name == "gemini" {
+		return c.GeminiAPIKey
+	}
+	return key
+}
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+// getEnvList reads a comma-separated list; set but empty, it is an empty list
+func getEnvList(key string, fallback []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+// getEnvFloat returns fallback when key is unset or not a number
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+// getEnvFloat32 returns nil when key is unset or not a number, leaving the
+// setting to whoever consumes it
+func getEnvFloat32(key string) *float32 {
+	if value, exists := os.LookupEnv(key); exists {
+		if f, err := strconv.ParseFloat(value, 32); err == nil {
+			f32 := float32(f)
+			return &f32
+		}
+	}
+	return nil
+}
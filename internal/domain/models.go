@@ -1,24 +1,125 @@
 package domain
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-// DocumentChunk represents a text chunk with its embedding
-type DocumentChunk struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Subject   string    `json:"subject" db:"subject"`
-	Chapter   int       `json:"chapter" db:"chapter"`
-	Content   string    `json:"content" db:"content"`
-	Embedding []float32 `json:"embedding" db:"embedding"` // pgvector
-	Language  string    `json:"language" db:"language"`   // 'bn' or 'en'
-	Page      int       `json:"page" db:"page"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+// DocumentStatus tracks where a document is in the ingestion lifecycle
+type DocumentStatus string
+
+const (
+	DocumentStatusQueued     DocumentStatus = "queued"
+	DocumentStatusProcessing DocumentStatus = "processing"
+	DocumentStatusReady      DocumentStatus = "ready"
+	DocumentStatusFailed     DocumentStatus = "failed"
+)
+
+// ChunkStrategy names how a document's text is split into chunks
+type ChunkStrategy string
+
+const (
+	// ChunkStrategyFixed cuts fixed-size, overlapping windows of characters
+	ChunkStrategyFixed ChunkStrategy = "fixed"
+	// ChunkStrategyStructured splits on headings, paragraphs and sentences
+	ChunkStrategyStructured ChunkStrategy = "structured"
+)
+
+// IsValid reports whether s is a known strategy or empty, which means the server's default
+func (s ChunkStrategy) IsValid() bool {
+	switch s {
+	case "", ChunkStrategyFixed, ChunkStrategyStructured:
+		return true
+	}
+	return false
+}
+
+// Document represents an uploaded source file whose chunks are stored in embeddings
+type Document struct {
+	ID          uuid.UUID      `json:"id" db:"id"`
+	Filename    string         `json:"filename" db:"filename"`
+	ContentHash string         `json:"content_hash" db:"content_hash"` // hex SHA-256 of the file
+	Subject     string         `json:"subject" db:"subject"`
+	Chapter     int            `json:"chapter" db:"chapter"`
+	Language    string         `json:"language" db:"language"`
+	PageCount   int            `json:"page_count" db:"page_count"`
+	ChunkCount  int            `json:"chunk_count" db:"chunk_count"`
+	Status      DocumentStatus `json:"status" db:"status"`
+	UserID      string         `json:"user_id" db:"user_id"` // uploader, from the JWT subject
+	// ChunkStrategy is how the document is chunked; empty means the server's default
+	ChunkStrategy ChunkStrategy `json:"chunk_strategy,omitempty" db:"chunk_strategy"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// ErrUnsupportedFormat is returned for an upload whose content is not in a
+// format any parser reads
+var ErrUnsupportedFormat = errors.New("unsupported document format")
+
+// ErrDimensionMismatch is returned when the embedding model's vectors do not
+// fit the vector store's
+var ErrDimensionMismatch = errors.New("embedding dimensions do not match the vector store")
+
+// ErrDocumentBusy is returned when a document is changed while a job for it
+// is still queued or running
+var ErrDocumentBusy = errors.New("document is being ingested")
+
+// JobStatus tracks the state of an ingestion job
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// IngestionJob is a persisted unit of background work that indexes one document
+type IngestionJob struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	DocumentID      uuid.UUID  `json:"document_id" db:"document_id"`
+	Status          JobStatus  `json:"status" db:"status"`
+	ChunksProcessed int        `json:"chunks_processed" db:"chunks_processed"`
+	ChunksTotal     int        `json:"chunks_total" db:"chunks_total"`
+	Error           string     `json:"error,omitempty" db:"error"`
+	Attempts        int        `json:"attempts" db:"attempts"`
+	Rebuild         bool       `json:"rebuild" db:"rebuild"` // embed every chunk again, e.g. on reindex
+	LockedUntil     *time.Time `json:"-" db:"locked_until"`  // lease held by the worker running the job
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// DocumentFilter narrows a document listing. Zero values mean "any".
+type DocumentFilter struct {
+	Subject     string
+	Chapter     int
+	Language    string
+	UserID      string
+	Filename    string
+	ContentHash string
+	Limit       int
+	Offset      int
 }
 
-// Question represents a generated question
-type Question struct {
-	Text string `json:"text"`
+// DocumentChunk represents a text chunk with its embedding
+type DocumentChunk struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	DocumentID  uuid.UUID `json:"document_id" db:"document_id"`
+	ChunkIndex  int       `json:"chunk_index" db:"chunk_index"`   // position within the document
+	ContentHash string    `json:"content_hash" db:"content_hash"` // hex SHA-256 of the normalized content
+	Subject     string    `json:"subject" db:"subject"`
+	Chapter     int       `json:"chapter" db:"chapter"`
+	Section     string    `json:"section,omitempty" db:"section"` // title of the section the chunk is from, if known
+	Content     string    `json:"content" db:"content"`
+	Embedding   []float32 `json:"embedding" db:"embedding"` // pgvector
+	Language    string    `json:"language" db:"language"`   // 'bn' or 'en'
+	Page        int       `json:"page" db:"page"`           // first page the chunk draws from
+	PageEnd     int       `json:"page_end" db:"page_end"`   // last page the chunk draws from
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	// Score is set on search results: the cosine similarity to the query for
+	// vector searches, the text rank for keyword searches and the fused rank
+	// score for hybrid ones. Higher is better.
+	Score float64 `json:"score,omitempty" db:"score"`
 }
@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuestionType is the format of a generated question
+type QuestionType string
+
+const (
+	QuestionTypeMCQ         QuestionType = "mcq"
+	QuestionTypeShortAnswer QuestionType = "short_answer"
+	QuestionTypeNumerical   QuestionType = "numerical"
+	QuestionTypeTrueFalse   QuestionType = "true_false"
+)
+
+// QuestionTypes lists every supported type in the order they are presented to the model
+var QuestionTypes = []QuestionType{QuestionTypeMCQ, QuestionTypeShortAnswer, QuestionTypeNumerical, QuestionTypeTrueFalse}
+
+// Difficulty grades how hard a question is
+type Difficulty string
+
+const (
+	DifficultyEasy   Difficulty = "easy"
+	DifficultyMedium Difficulty = "medium"
+	DifficultyHard   Difficulty = "hard"
+)
+
+const (
+	// MaxQuestionsPerRequest caps how many questions one generation request may ask for
+	MaxQuestionsPerRequest = 50
+
+	minMCQOptions = 3
+	maxMCQOptions = 6
+)
+
+// ErrInvalidQuestion is wrapped by every Question validation error
+var ErrInvalidQuestion = errors.New("invalid question")
+
+// Question is a generated exam question together with its answer key, as
+// stored in the question bank.
+//
+// Answer holds the correct option's text for MCQs, "true" or "false" for
+// true/false questions, a plain decimal number for numerical questions and
+// the model answer for short-answer questions.
+type Question struct {
+	ID             uuid.UUID    `json:"id" db:"id"`
+	Type           QuestionType `json:"type" db:"type"`
+	Stem           string       `json:"stem" db:"stem"`
+	Options        []string     `json:"options,omitempty" db:"options"`
+	Answer         string       `json:"answer" db:"answer"`
+	Explanation    string       `json:"explanation,omitempty" db:"explanation"`
+	Difficulty     Difficulty   `json:"difficulty" db:"difficulty"`
+	Marks          int          `json:"marks" db:"marks"`
+	SourceChunkIDs []uuid.UUID  `json:"source_chunk_ids" db:"source_chunk_ids"`
+	// Citations describe the chunks in SourceChunkIDs. They are filled in when
+	// a question is generated and are not stored in the question bank.
+	Citations []Citation `json:"citations,omitempty" db:"-"`
+
+	// What the question was generated for, and by whom
+	Subject   string    `json:"subject" db:"subject"`
+	Chapter   int       `json:"chapter,omitempty" db:"chapter"` // 0 when the context spanned several chapters
+	Topic     string    `json:"topic" db:"topic"`
+	Language  string    `json:"language" db:"language"`
+	UserID    string    `json:"user_id,omitempty" db:"user_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Citation points at a chunk of a source document that a question is grounded in
+type Citation struct {
+	ChunkID    uuid.UUID `json:"chunk_id"`
+	DocumentID uuid.UUID `json:"document_id"`
+	Subject    string    `json:"subject"`
+	Chapter    int       `json:"chapter"`
+	Section    string    `json:"section,omitempty"`
+	Page       int       `json:"page"`
+	PageEnd    int       `json:"page_end"`
+	Snippet    string    `json:"snippet"`
+}
+
+// QuestionFilter selects questions from the question bank. Zero values mean "no constraint".
+type QuestionFilter struct {
+	Subject    string
+	Chapter    int
+	Topic      string
+	Type       QuestionType
+	Difficulty Difficulty
+	Language   string
+	UserID     string
+	// Search is a full-text query over the stem, options and explanation
+	Search string
+	Limit  int
+	Offset int
+}
+
+// GenerationRequest describes a batch of questions to generate
+type GenerationRequest struct {
+	Topic    string
+	Language string
+	Mix      QuestionMix
+	// Query selects the chunks the questions are grounded in
+	Query  SearchQuery
+	UserID string
+}
+
+// GenerationProgress counts the questions a generation has produced so far
+type GenerationProgress struct {
+	Requested int `json:"requested"`
+	Generated int `json:"generated"`
+	// Rejected counts questions the model wrote that failed validation
+	Rejected int `json:"rejected"`
+}
+
+// Validate checks the fields every question needs, then the rules for its type
+func (q Question) Validate() error {
+	if strings.TrimSpace(q.Stem) == "" {
+		return fmt.Errorf("%w: stem must not be empty", ErrInvalidQuestion)
+	}
+	if strings.TrimSpace(q.Answer) == "" {
+		return fmt.Errorf("%w: answer must not be empty", ErrInvalidQuestion)
+	}
+	switch q.Difficulty {
+	case DifficultyEasy, DifficultyMedium, DifficultyHard:
+	default:
+		return fmt.Errorf("%w: unknown difficulty %q", ErrInvalidQuestion, q.Difficulty)
+	}
+	if q.Marks < 1 {
+		return fmt.Errorf("%w: marks must be positive", ErrInvalidQuestion)
+	}
+
+	switch q.Type {
+	case QuestionTypeMCQ:
+		return q.validateMCQ()
+	case QuestionTypeTrueFalse:
+		if len(q.Options) > 0 {
+			return fmt.Errorf("%w: true/false questions take no options", ErrInvalidQuestion)
+		}
+		if q.Answer != "true" && q.Answer != "false" {
+			return fmt.Errorf("%w: true/false answer must be \"true\" or \"false\", got %q", ErrInvalidQuestion, q.Answer)
+		}
+	case QuestionTypeNumerical:
+		if len(q.Options) > 0 {
+			return fmt.Errorf("%w: numerical questions take no options", ErrInvalidQuestion)
+		}
+		if _, err := strconv.ParseFloat(q.Answer, 64); err != nil {
+			return fmt.Errorf("%w: numerical answer %q is not a number", ErrInvalidQuestion, q.Answer)
+		}
+	case QuestionTypeShortAnswer:
+		if len(q.Options) > 0 {
+			return fmt.Errorf("%w: short-answer questions take no options", ErrInvalidQuestion)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidQuestion, q.Type)
+	}
+	return nil
+}
+
+func (q Question) validateMCQ() error {
+	if len(q.Options) < minMCQOptions || len(q.Options) > maxMCQOptions {
+		return fmt.Errorf("%w: mcq needs between %d and %d options, got %d", ErrInvalidQuestion, minMCQOptions, maxMCQOptions, len(q.Options))
+	}
+
+	seen := make(map[string]bool, len(q.Options))
+	for _, o := range q.Options {
+		if strings.TrimSpace(o) == "" {
+			return fmt.Errorf("%w: mcq options must not be empty", ErrInvalidQuestion)
+		}
+		if seen[o] {
+			return fmt.Errorf("%w: duplicate mcq option %q", ErrInvalidQuestion, o)
+		}
+		seen[o] = true
+	}
+	if !seen[q.Answer] {
+		return fmt.Errorf("%w: mcq answer %q is not one of the options", ErrInvalidQuestion, q.Answer)
+	}
+	return nil
+}
+
+// QuestionMix is how many questions of each type to generate
+type QuestionMix map[QuestionType]int
+
+// Total is the number of questions the mix asks for
+func (m QuestionMix) Total() int {
+	total := 0
+	for _, n := range m {
+		total += n
+	}
+	return total
+}
+
+// Validate checks that the mix names only known types and asks for a sensible number of questions
+func (m QuestionMix) Validate() error {
+	for t, n := range m {
+		switch t {
+		case QuestionTypeMCQ, QuestionTypeShortAnswer, QuestionTypeNumerical, QuestionTypeTrueFalse:
+		default:
+			return fmt.Errorf("%w: unknown question type %q", ErrInvalidQuery, t)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: count for %s must not be negative", ErrInvalidQuery, t)
+		}
+	}
+	if total := m.Total(); total < 1 || total > MaxQuestionsPerRequest {
+		return fmt.Errorf("%w: between 1 and %d questions must be requested", ErrInvalidQuery, MaxQuestionsPerRequest)
+	}
+	return nil
+}
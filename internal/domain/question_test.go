@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuestionValidate(t *testing.T) {
+	mcq := Question{
+		Type:       QuestionTypeMCQ,
+		Stem:       "Which law describes inertia?",
+		Options:    []string{"First law", "Second law", "Third law"},
+		Answer:     "First law",
+		Difficulty: DifficultyEasy,
+		Marks:      1,
+	}
+	with := func(q Question, edit func(*Question)) Question {
+		edit(&q)
+		return q
+	}
+
+	tests := []struct {
+		name     string
+		question Question
+		errMsg   string
+	}{
+		{"valid mcq", mcq, ""},
+		{"valid true/false", Question{Type: QuestionTypeTrueFalse, Stem: "Mass is a vector.", Answer: "false", Difficulty: DifficultyEasy, Marks: 1}, ""},
+		{"valid numerical", Question{Type: QuestionTypeNumerical, Stem: "Force on 2 kg at 3 m/s²?", Answer: "6", Difficulty: DifficultyMedium, Marks: 2}, ""},
+		{"valid short answer", Question{Type: QuestionTypeShortAnswer, Stem: "State Newton's first law.", Answer: "A body stays at rest...", Difficulty: DifficultyHard, Marks: 3}, ""},
+		{"empty stem", with(mcq, func(q *Question) { q.Stem = " " }), "stem must not be empty"},
+		{"empty answer", with(mcq, func(q *Question) { q.Answer = "" }), "answer must not be empty"},
+		{"unknown difficulty", with(mcq, func(q *Question) { q.Difficulty = "extreme" }), `unknown difficulty "extreme"`},
+		{"zero marks", with(mcq, func(q *Question) { q.Marks = 0 }), "marks must be positive"},
+		{"unknown type", with(mcq, func(q *Question) { q.Type = "essay" }), `unknown type "essay"`},
+		{"mcq too few options", with(mcq, func(q *Question) { q.Options = q.Options[:2] }), "mcq needs between 3 and 6 options, got 2"},
+		{"mcq duplicate option", with(mcq, func(q *Question) { q.Options = []string{"A", "B", "A"}; q.Answer = "A" }), `duplicate mcq option "A"`},
+		{"mcq answer not an option", with(mcq, func(q *Question) { q.Answer = "B" }), `mcq answer "B" is not one of the options`},
+		{"true/false bad answer", Question{Type: QuestionTypeTrueFalse, Stem: "s", Answer: "yes", Difficulty: DifficultyEasy, Marks: 1}, `answer must be "true" or "false"`},
+		{"true/false with options", Question{Type: QuestionTypeTrueFalse, Stem: "s", Options: []string{"true", "false"}, Answer: "true", Difficulty: DifficultyEasy, Marks: 1}, "take no options"},
+		{"numerical not a number", Question{Type: QuestionTypeNumerical, Stem: "s", Answer: "6 N", Difficulty: DifficultyEasy, Marks: 1}, `numerical answer "6 N" is not a number`},
+		{"short answer with options", with(mcq, func(q *Question) { q.Type = QuestionTypeShortAnswer }), "take no options"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.question.Validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrInvalidQuestion))
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestQuestionMixValidate(t *testing.T) {
+	assert.NoError(t, QuestionMix{QuestionTypeMCQ: 3, QuestionTypeTrueFalse: 2}.Validate())
+	assert.Equal(t, 5, QuestionMix{QuestionTypeMCQ: 3, QuestionTypeTrueFalse: 2}.Total())
+
+	assert.ErrorIs(t, QuestionMix{}.Validate(), ErrInvalidQuery)
+	assert.ErrorContains(t, QuestionMix{"essay": 1}.Validate(), `unknown question type "essay"`)
+	assert.ErrorContains(t, QuestionMix{QuestionTypeMCQ: -1, QuestionTypeNumerical: 2}.Validate(), "must not be negative")
+	assert.ErrorContains(t, QuestionMix{QuestionTypeMCQ: MaxQuestionsPerRequest + 1}.Validate(), "between 1 and 50 questions")
+}
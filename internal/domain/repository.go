@@ -2,10 +2,70 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// ErrNotFound is returned by repositories when the requested record does not exist
+var ErrNotFound = errors.New("not found")
+
 // VectorRepository defines the interface for vector operations
 type VectorRepository interface {
 	SaveChunk(ctx context.Context, chunk *DocumentChunk) error
-	SearchSimilar(ctx context.Context, embedding []float32, limit int, filter map[string]interface{}) ([]*DocumentChunk, error)
+	SaveChunks(ctx context.Context, chunks []*DocumentChunk) error
+	SearchSimilar(ctx context.Context, embedding []float32, query SearchQuery) ([]*DocumentChunk, error)
+	// SearchKeyword ranks chunks by full-text match on text; query.MinScore does not apply
+	SearchKeyword(ctx context.Context, text string, query SearchQuery) ([]*DocumentChunk, error)
+	DeleteChunksByDocument(ctx context.Context, documentID uuid.UUID) error
+	// ChunkHashes lists the content hashes of a document's stored chunks
+	ChunkHashes(ctx context.Context, documentID uuid.UUID) ([]string, error)
+	// ReplaceChunks atomically inserts add, moves the stored chunks with a hash
+	// in keep to the position, pages and section given there, and removes the
+	// document's chunks whose hash is not in keep
+	ReplaceChunks(ctx context.Context, documentID uuid.UUID, add []*DocumentChunk, keep []*DocumentChunk) error
+}
+
+// DocumentRepository stores uploaded documents and their original file contents
+type DocumentRepository interface {
+	CreateDocument(ctx context.Context, doc *Document, content []byte) error
+	GetDocument(ctx context.Context, id uuid.UUID) (*Document, error)
+	GetDocumentContent(ctx context.Context, id uuid.UUID) ([]byte, error)
+	ListDocuments(ctx context.Context, filter DocumentFilter) ([]*Document, error)
+	UpdateDocument(ctx context.Context, doc *Document) error
+	// ReplaceDocumentFile updates the document row and swaps in a new version of its file
+	ReplaceDocumentFile(ctx context.Context, doc *Document, content []byte) error
+	// DeleteDocument removes the document, its file and all of its chunks
+	DeleteDocument(ctx context.Context, id uuid.UUID) error
+}
+
+// JobRepository is a persistent queue of ingestion jobs
+type JobRepository interface {
+	CreateJob(ctx context.Context, job *IngestionJob) error
+	GetJob(ctx context.Context, id uuid.UUID) (*IngestionJob, error)
+	// ActiveJob returns the document's queued or running job. Returns ErrNotFound
+	// if it has none.
+	ActiveJob(ctx context.Context, documentID uuid.UUID) (*IngestionJob, error)
+	// ClaimJob marks the oldest runnable job as running and leases it to the caller
+	// for the given duration. Jobs whose lease expired (e.g. because the server
+	// restarted mid-run) are runnable again. Returns ErrNotFound if the queue is empty.
+	ClaimJob(ctx context.Context, lease time.Duration) (*IngestionJob, error)
+	// UpdateJobProgress records progress and renews the job's lease
+	UpdateJobProgress(ctx context.Context, id uuid.UUID, processed, total int, lease time.Duration) error
+	// RenewJobLease extends the lease of a running job without recording progress
+	RenewJobLease(ctx context.Context, id uuid.UUID, lease time.Duration) error
+	// FinishJob stores the job's final status, counters and error and releases its lease
+	FinishJob(ctx context.Context, job *IngestionJob) error
+}
+
+// QuestionRepository is the question bank
+type QuestionRepository interface {
+	// SaveQuestions inserts a batch of generated questions in one transaction
+	SaveQuestions(ctx context.Context, questions []*Question) error
+	GetQuestion(ctx context.Context, id uuid.UUID) (*Question, error)
+	// ListQuestions returns matching questions, newest first, or best match first when filter.Search is set
+	ListQuestions(ctx context.Context, filter QuestionFilter) ([]*Question, error)
+	UpdateQuestion(ctx context.Context, question *Question) error
+	DeleteQuestion(ctx context.Context, id uuid.UUID) error
 }
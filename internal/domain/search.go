@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// MaxSearchLimit caps how many chunks a single search may return
+const MaxSearchLimit = 100
+
+// ErrInvalidQuery is wrapped by every SearchQuery and QuestionMix validation error
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// ErrInsufficientContext means retrieval found nothing relevant enough to
+// ground generation in
+var ErrInsufficientContext = errors.New("insufficient context")
+
+// SearchMode picks how chunks are matched against the query text
+type SearchMode string
+
+const (
+	// SearchModeVector ranks chunks by embedding similarity; it is the default
+	SearchModeVector SearchMode = "vector"
+	// SearchModeKeyword ranks chunks by full-text match on the query's words,
+	// which finds exact terms like "Bernoulli" that embeddings can miss
+	SearchModeKeyword SearchMode = "keyword"
+	// SearchModeHybrid runs both searches and fuses their rankings
+	SearchModeHybrid SearchMode = "hybrid"
+)
+
+// SearchQuery describes which chunks a similarity search may return.
+// Zero values mean "no constraint", except Limit which must be set.
+type SearchQuery struct {
+	Subject     string      `json:"subject,omitempty"`
+	Chapters    []int       `json:"chapters,omitempty"`
+	Languages   []string    `json:"languages,omitempty"`
+	DocumentIDs []uuid.UUID `json:"document_ids,omitempty"`
+	PageFrom    int         `json:"page_from,omitempty"` // inclusive
+	PageTo      int         `json:"page_to,omitempty"`   // inclusive
+	MinScore    float64     `json:"min_score,omitempty"` // cosine similarity, 0..1; not applied to keyword search
+	Limit       int         `json:"limit"`
+	Offset      int         `json:"offset,omitempty"`
+	Mode        SearchMode  `json:"mode,omitempty"`
+	// IncludeEmbeddings asks for the results' embeddings, which are left out by default
+	IncludeEmbeddings bool `json:"-"`
+	// EfSearch and Probes override the repository's approximate search
+	// settings for this query: more candidates find more true neighbours,
+	// more slowly. Zero keeps the repository's setting.
+	EfSearch int `json:"ef_search,omitempty"` // HNSW, 1..1000
+	Probes   int `json:"probes,omitempty"`    // IVFFlat lists to scan
+}
+
+// Validate checks the query for values the repository cannot honour
+func (q SearchQuery) Validate() error {
+	if q.Limit < 1 || q.Limit > MaxSearchLimit {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxSearchLimit)
+	}
+	if q.Offset < 0 {
+		return fmt.Errorf("%w: offset must not be negative", ErrInvalidQuery)
+	}
+	for _, c := range q.Chapters {
+		if c < 1 {
+			return fmt.Errorf("%w: chapter %d must be positive", ErrInvalidQuery, c)
+		}
+	}
+	for _, l := range q.Languages {
+		if l != "en" && l != "bn" {
+			return fmt.Errorf("%w: unsupported language %q", ErrInvalidQuery, l)
+		}
+	}
+	for _, id := range q.DocumentIDs {
+		if id == uuid.Nil {
+			return fmt.Errorf("%w: document id must not be empty", ErrInvalidQuery)
+		}
+	}
+	if q.PageFrom < 0 || q.PageTo < 0 {
+		return fmt.Errorf("%w: pages must not be negative", ErrInvalidQuery)
+	}
+	if q.PageFrom > 0 && q.PageTo > 0 && q.PageTo < q.PageFrom {
+		return fmt.Errorf("%w: page_to (%d) is before page_from (%d)", ErrInvalidQuery, q.PageTo, q.PageFrom)
+	}
+	if q.MinScore < 0 || q.MinScore > 1 {
+		return fmt.Errorf("%w: min_score must be between 0 and 1", ErrInvalidQuery)
+	}
+	switch q.Mode {
+	case "", SearchModeVector, SearchModeKeyword, SearchModeHybrid:
+	default:
+		return fmt.Errorf("%w: unknown search mode %q", ErrInvalidQuery, q.Mode)
+	}
+	if q.EfSearch < 0 || q.EfSearch > 1000 {
+		return fmt.Errorf("%w: ef_search must be between 1 and 1000", ErrInvalidQuery)
+	}
+	if q.Probes < 0 {
+		return fmt.Errorf("%w: probes must not be negative", ErrInvalidQuery)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQueryValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  SearchQuery
+		errMsg string
+	}{
+		{"valid minimal", SearchQuery{Limit: 10}, ""},
+		{"valid full", SearchQuery{
+			Subject:     "Physics",
+			Chapters:    []int{1, 2},
+			Languages:   []string{"bn"},
+			DocumentIDs: []uuid.UUID{uuid.New()},
+			PageFrom:    3,
+			PageTo:      9,
+			MinScore:    0.5,
+			Limit:       20,
+			Offset:      20,
+		}, ""},
+		{"missing limit", SearchQuery{}, "limit must be between 1 and 100"},
+		{"limit too large", SearchQuery{Limit: 101}, "limit must be between 1 and 100"},
+		{"negative offset", SearchQuery{Limit: 1, Offset: -1}, "offset must not be negative"},
+		{"zero chapter", SearchQuery{Limit: 1, Chapters: []int{0}}, "chapter 0 must be positive"},
+		{"unknown language", SearchQuery{Limit: 1, Languages: []string{"fr"}}, `unsupported language "fr"`},
+		{"nil document id", SearchQuery{Limit: 1, DocumentIDs: []uuid.UUID{uuid.Nil}}, "document id must not be empty"},
+		{"inverted pages", SearchQuery{Limit: 1, PageFrom: 10, PageTo: 5}, "page_to (5) is before page_from (10)"},
+		{"score out of range", SearchQuery{Limit: 1, MinScore: 1.5}, "min_score must be between 0 and 1"},
+		{"ef_search too large", SearchQuery{Limit: 1, EfSearch: 1001}, "ef_search must be between 1 and 1000"},
+		{"unknown mode", SearchQuery{Limit: 1, Mode: "semantic"}, `unknown search mode "semantic"`},
+		{"negative probes", SearchQuery{Limit: 1, Probes: -1}, "probes must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidQuery))
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
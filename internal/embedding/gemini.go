@@ -3,28 +3,36 @@ package embedding
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
+// DefaultGeminiModel is used when no model is given
+const DefaultGeminiModel = "text-embedding-004"
+
 // GeminiClient handles interaction with Google Gemini API
 type GeminiClient struct {
 	client *genai.Client
 	model  *genai.EmbeddingModel
 }
 
-// NewGeminiClient creates a new client instance
-func NewGeminiClient(ctx context.Context, apiKey string) (*GeminiClient, error) {
+// NewGeminiClient creates a new client instance embedding with the named
+// model, or DefaultGeminiModel if model is empty
+func NewGeminiClient(ctx context.Context, apiKey, model string) (*GeminiClient, error) {
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
 	if err != nil {
 		return nil, err
 	}
 
-	model := client.EmbeddingModel("text-embedding-004")
+	if model == "" {
+		model = DefaultGeminiModel
+	}
+	embeddingModel := client.EmbeddingModel(model)
 	return &GeminiClient{
 		client: client,
-		model:  model,
+		model:  embeddingModel,
 	}, nil
 }
 
@@ -45,3 +53,41 @@ func (c *GeminiClient) EmbedContent(ctx context.Context, text string) ([]float32
 
 	return res.Embedding.Values, nil
 }
+
+// MaxBatchSize is the most texts the Gemini API embeds in a single request
+const MaxBatchSize = 100
+
+// EmbedBatch generates embeddings for several texts using the batch embed API,
+// splitting the input into requests of at most MaxBatchSize texts. The
+// embeddings are returned in the same order as texts.
+func (c *GeminiClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(texts))
+
+		batch := c.model.NewBatch()
+		for _, text := range texts[start:end] {
+			if text == "" {
+				return nil, errors.New("text cannot be empty")
+			}
+			batch.AddContent(genai.Text(text))
+		}
+
+		res, err := c.model.BatchEmbedContents(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(res.Embeddings) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(res.Embeddings))
+		}
+		for _, e := range res.Embeddings {
+			if e == nil || len(e.Values) == 0 {
+				return nil, errors.New("no embedding returned")
+			}
+			embeddings = append(embeddings, e.Values)
+		}
+	}
+
+	return embeddings, nil
+}
@@ -0,0 +1,89 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashingDimensions matches the size of DefaultGeminiModel's embeddings,
+// so hashed vectors fit the same vector column
+const DefaultHashingDimensions = 768
+
+// HashingEmbedder embeds text offline by feature hashing its words into a
+// fixed number of dimensions. The vectors are deterministic and texts that
+// share words point in similar directions, which is enough lexical
+// similarity for tests and local development; they carry no meaning beyond that.
+type HashingEmbedder struct {
+	dimensions int
+}
+
+// NewHashingEmbedder creates an embedder with the given number of dimensions,
+// or DefaultHashingDimensions if dimensions is not positive
+func NewHashingEmbedder(dimensions int) *HashingEmbedder {
+	if dimensions <= 0 {
+		dimensions = DefaultHashingDimensions
+	}
+	return &HashingEmbedder{dimensions: dimensions}
+}
+
+// EmbedContent generates embeddings for the given text
+func (e *HashingEmbedder) EmbedContent(ctx context.Context, text string) ([]float32, error) {
+	if text == "" {
+		return nil, errors.New("text cannot be empty")
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	if len(words) == 0 {
+		// Punctuation only; hash it whole so the vector is not zero
+		words = []string{text}
+	}
+
+	vector := make([]float32, e.dimensions)
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+
+		// The low bits pick the dimension, the top bit the sign, so that
+		// unrelated words colliding on a dimension tend to cancel out
+		sign := float32(1)
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		vector[sum%uint64(e.dimensions)] += sign
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		// Every word cancelled out; fall back to a fixed direction
+		vector[0] = 1
+		return vector, nil
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector, nil
+}
+
+// EmbedBatch generates embeddings for several texts, in the same order as texts
+func (e *HashingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := e.EmbedContent(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,64 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+func TestHashingEmbedder_Deterministic(t *testing.T) {
+	e := NewHashingEmbedder(0)
+	ctx := context.Background()
+
+	a, err := e.EmbedContent(ctx, "Newton's second law")
+	assert.NoError(t, err)
+	b, err := NewHashingEmbedder(0).EmbedContent(ctx, "newton's SECOND law!")
+	assert.NoError(t, err)
+
+	assert.Len(t, a, DefaultHashingDimensions)
+	assert.Equal(t, a, b)
+	assert.InDelta(t, 1, cosine(a, a), 1e-5)
+}
+
+func TestHashingEmbedder_LexicalSimilarity(t *testing.T) {
+	e := NewHashingEmbedder(256)
+	ctx := context.Background()
+
+	embeddings, err := e.EmbedBatch(ctx, []string{
+		"force equals mass times acceleration",
+		"the force on a mass causes acceleration",
+		"photosynthesis happens in chloroplasts",
+		"বল হলো ভর এবং ত্বরণের গুণফল",
+		"ভর এবং ত্বরণ",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, embeddings[0], 256)
+
+	assert.Greater(t, cosine(embeddings[0], embeddings[1]), cosine(embeddings[0], embeddings[2]))
+	assert.Greater(t, cosine(embeddings[3], embeddings[4]), cosine(embeddings[3], embeddings[2]))
+}
+
+func TestHashingEmbedder_NeverZero(t *testing.T) {
+	e := NewHashingEmbedder(8)
+
+	embedding, err := e.EmbedContent(context.Background(), "?!")
+	assert.NoError(t, err)
+	var norm float64
+	for _, v := range embedding {
+		norm += float64(v) * float64(v)
+	}
+	assert.InDelta(t, 1, math.Sqrt(norm), 1e-5)
+
+	_, err = e.EmbedContent(context.Background(), "")
+	assert.Error(t, err)
+}
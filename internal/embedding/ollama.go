@@ -0,0 +1,66 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"backend/internal/llmhttp"
+)
+
+// OllamaClient embeds text with a model served by Ollama's native embed API
+type OllamaClient struct {
+	api   *llmhttp.Client
+	model string
+}
+
+// NewOllamaClient creates a client for the Ollama server at baseURL, e.g. "http://localhost:11434"
+func NewOllamaClient(baseURL, model string) (*OllamaClient, error) {
+	if model == "" {
+		return nil, errors.New("model is required")
+	}
+	return &OllamaClient{api: llmhttp.New(baseURL, ""), model: model}, nil
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// EmbedContent generates embeddings for the given text
+func (c *OllamaClient) EmbedContent(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for several texts in one request. The
+// embeddings are returned in the same order as texts.
+func (c *OllamaClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	for _, text := range texts {
+		if text == "" {
+			return nil, errors.New("text cannot be empty")
+		}
+	}
+
+	var res ollamaEmbedResponse
+	if err := c.api.PostJSON(ctx, "/api/embed", ollamaEmbedRequest{Model: c.model, Input: texts}, &res); err != nil {
+		return nil, err
+	}
+
+	if len(res.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(res.Embeddings))
+	}
+	for _, e := range res.Embeddings {
+		if len(e) == 0 {
+			return nil, errors.New("no embedding returned")
+		}
+	}
+	return res.Embeddings, nil
+}
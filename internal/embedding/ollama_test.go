@@ -0,0 +1,36 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOllamaClient_EmbedContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/embed", r.URL.Path)
+
+		var req ollamaEmbedRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "nomic-embed-text", req.Model)
+		assert.Equal(t, []string{"force"}, req.Input)
+
+		fmt.Fprint(w, `{"embeddings": [[0.1, 0.2, 0.3]]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOllamaClient(server.URL, "nomic-embed-text")
+	assert.NoError(t, err)
+
+	embedding, err := client.EmbedContent(context.Background(), "force")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{0.1, 0.2, 0.3}, embedding)
+
+	_, err = client.EmbedContent(context.Background(), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"backend/internal/llmhttp"
+)
+
+// OpenAIClient embeds text with any API that is compatible with OpenAI's
+// embeddings endpoint
+type OpenAIClient struct {
+	api   *llmhttp.Client
+	model string
+	// dimensions asks models that support it for shorter embeddings; zero keeps the model's size
+	dimensions int
+}
+
+// NewOpenAIClient creates a client for the API at baseURL, e.g. "https://api.openai.com/v1"
+func NewOpenAIClient(baseURL, apiKey, model string, dimensions int) (*OpenAIClient, error) {
+	if model == "" {
+		return nil, errors.New("model is required")
+	}
+	return &OpenAIClient{api: llmhttp.New(baseURL, apiKey), model: model, dimensions: dimensions}, nil
+}
+
+type openAIEmbeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// EmbedContent generates embeddings for the given text
+func (c *OpenAIClient) EmbedContent(ctx context.Context, text string) ([]float32, error) {
+	embeddings, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for several texts in one request. The
+// embeddings are returned in the same order as texts.
+func (c *OpenAIClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	for _, text := range texts {
+		if text == "" {
+			return nil, errors.New("text cannot be empty")
+		}
+	}
+
+	var res openAIEmbeddingResponse
+	req := openAIEmbeddingRequest{Model: c.model, Input: texts, Dimensions: c.dimensions}
+	if err := c.api.PostJSON(ctx, "/embeddings", req, &res); err != nil {
+		return nil, err
+	}
+
+	if len(res.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(res.Data))
+	}
+	embeddings := make([][]float32, len(texts))
+	for _, d := range res.Data {
+		if d.Index < 0 || d.Index >= len(texts) || len(d.Embedding) == 0 {
+			return nil, errors.New("no embedding returned")
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	for _, e := range embeddings {
+		if e == nil {
+			return nil, errors.New("no embedding returned")
+		}
+	}
+	return embeddings, nil
+}
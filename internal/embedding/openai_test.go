@@ -0,0 +1,48 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIClient_EmbedBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+
+		var req openAIEmbeddingRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "embed-model", req.Model)
+		assert.Equal(t, []string{"force", "mass"}, req.Input)
+		assert.Equal(t, 768, req.Dimensions)
+
+		// Out of order on purpose; index decides the position
+		fmt.Fprint(w, `{"data": [{"index": 1, "embedding": [0.3, 0.4]}, {"index": 0, "embedding": [0.1, 0.2]}]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOpenAIClient(server.URL+"/v1", "", "embed-model", 768)
+	assert.NoError(t, err)
+
+	embeddings, err := client.EmbedBatch(context.Background(), []string{"force", "mass"})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, embeddings)
+}
+
+func TestOpenAIClient_EmbedBatchCountMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": [{"index": 0, "embedding": [0.1]}]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOpenAIClient(server.URL, "", "embed-model", 0)
+	assert.NoError(t, err)
+
+	_, err = client.EmbedBatch(context.Background(), []string{"force", "mass"})
+	assert.ErrorContains(t, err, "expected 2 embeddings, got 1")
+}
@@ -5,21 +5,33 @@ import (
 	"errors"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+// DefaultGeminiModel is used when Options.Model is empty
+const DefaultGeminiModel = "gemini-2.0-flash-exp"
+
 type GeminiClient struct {
 	client *genai.Client
 	model  *genai.GenerativeModel
 }
 
-func NewGeminiClient(ctx context.Context, apiKey string) (*GeminiClient, error) {
+func NewGeminiClient(ctx context.Context, apiKey string, opts Options) (*GeminiClient, error) {
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
 	if err != nil {
 		return nil, err
 	}
 
-	model := client.GenerativeModel("gemini-2.0-flash-exp")
+	if opts.Model == "" {
+		opts.Model = DefaultGeminiModel
+	}
+	model := client.GenerativeModel(opts.Model)
+	model.Temperature = opts.Temperature
+	model.TopP = opts.TopP
+	if opts.MaxOutputTokens > 0 {
+		model.SetMaxOutputTokens(opts.MaxOutputTokens)
+	}
 	return &GeminiClient{
 		client: client,
 		model:  model,
@@ -47,3 +59,48 @@ func (c *GeminiClient) GenerateContent(ctx context.Context, prompt string) (stri
 
 	return "", errors.New("unexpected response format")
 }
+
+// CountTokens asks Gemini how many tokens text is to the model
+func (c *GeminiClient) CountTokens(ctx context.Context, text string) (int, error) {
+	res, err := c.model.CountTokens(ctx, genai.Text(text))
+	if err != nil {
+		return 0, err
+	}
+	return int(res.TotalTokens), nil
+}
+
+// GenerateStream generates a response to prompt with Gemini's streaming API,
+// passing each piece of text to onChunk as it arrives. An error from onChunk
+// stops the stream and is returned.
+func (c *GeminiClient) GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error {
+	if prompt == "" {
+		return errors.New("prompt cannot be empty")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	iter := c.model.GenerateContentStream(ctx, genai.Text(prompt))
+	for {
+		res, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(res.Candidates) == 0 || res.Candidates[0].Content == nil {
+			continue
+		}
+
+		for _, part := range res.Candidates[0].Content.Parts {
+			txt, ok := part.(genai.Text)
+			if !ok || txt == "" {
+				continue
+			}
+			if err := onChunk(string(txt)); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package generation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"backend/internal/llmhttp"
+)
+
+// OllamaClient generates text with a model served by Ollama's native chat API
+type OllamaClient struct {
+	api  *llmhttp.Client
+	opts Options
+}
+
+// NewOllamaClient creates a client for the Ollama server at baseURL, e.g. "http://localhost:11434"
+func NewOllamaClient(baseURL string, opts Options) (*OllamaClient, error) {
+	if opts.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	return &OllamaClient{api: llmhttp.New(baseURL, ""), opts: opts}, nil
+}
+
+type ollamaChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	// Stream must always be sent: Ollama streams unless told not to
+	Stream  bool          `json:"stream"`
+	Options ollamaOptions `json:"options"`
+}
+
+type ollamaOptions struct {
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	NumPredict  int32    `json:"num_predict,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message chatMessage `json:"message"`
+	Done    bool        `json:"done"`
+	Error   string      `json:"error"`
+}
+
+func (c *OllamaClient) request(prompt string, stream bool) ollamaChatRequest {
+	return ollamaChatRequest{
+		Model:    c.opts.Model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+		Stream:   stream,
+		Options: ollamaOptions{
+			Temperature: c.opts.Temperature,
+			TopP:        c.opts.TopP,
+			NumPredict:  c.opts.MaxOutputTokens,
+		},
+	}
+}
+
+func (c *OllamaClient) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	if prompt == "" {
+		return "", errors.New("prompt cannot be empty")
+	}
+
+	var res ollamaChatResponse
+	if err := c.api.PostJSON(ctx, "/api/chat", c.request(prompt, false), &res); err != nil {
+		return "", err
+	}
+	if res.Error != "" {
+		return "", errors.New(res.Error)
+	}
+	return res.Message.Content, nil
+}
+
+// GenerateStream generates a response to prompt, reading Ollama's stream of
+// JSON lines and passing each piece of text to onChunk as it arrives. An
+// error from onChunk stops the stream and is returned.
+func (c *OllamaClient) GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error {
+	if prompt == "" {
+		return errors.New("prompt cannot be empty")
+	}
+
+	resp, err := c.api.Post(ctx, "/api/chat", c.request(prompt, true))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return fmt.Errorf("decoding stream failed: %w", err)
+		}
+		if chunk.Error != "" {
+			return errors.New(chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			if err := onChunk(chunk.Message.Content); err != nil {
+				return err
+			}
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("stream ended before the response was done")
+}
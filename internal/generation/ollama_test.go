@@ -0,0 +1,73 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOllamaClient_GenerateContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var req ollamaChatRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "llama3.1", req.Model)
+		assert.False(t, req.Stream)
+		assert.Equal(t, int32(256), req.Options.NumPredict)
+
+		fmt.Fprint(w, `{"message": {"role": "assistant", "content": "Gravity pulls."}, "done": true}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOllamaClient(server.URL, Options{Model: "llama3.1", MaxOutputTokens: 256})
+	assert.NoError(t, err)
+
+	text, err := client.GenerateContent(context.Background(), "Explain gravity")
+	assert.NoError(t, err)
+	assert.Equal(t, "Gravity pulls.", text)
+}
+
+func TestOllamaClient_GenerateStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "content": "Gravity "}, "done": false}`)
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "content": "pulls."}, "done": false}`)
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "content": ""}, "done": true}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOllamaClient(server.URL, Options{Model: "llama3.1"})
+	assert.NoError(t, err)
+
+	var chunks []string
+	err = client.GenerateStream(context.Background(), "Explain gravity", func(text string) error {
+		chunks = append(chunks, text)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Gravity ", "pulls."}, chunks)
+}
+
+func TestOllamaClient_GenerateStreamStopsOnCallbackError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message": {"content": "Gravity "}, "done": false}`)
+		fmt.Fprintln(w, `{"error": "model crashed"}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOllamaClient(server.URL, Options{Model: "llama3.1"})
+	assert.NoError(t, err)
+
+	stop := errors.New("stop")
+	err = client.GenerateStream(context.Background(), "Explain gravity", func(string) error { return stop })
+	assert.ErrorIs(t, err, stop)
+
+	err = client.GenerateStream(context.Background(), "Explain gravity", func(string) error { return nil })
+	assert.EqualError(t, err, "model crashed")
+}
@@ -0,0 +1,114 @@
+package generation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"backend/internal/llmhttp"
+)
+
+// OpenAIClient generates text with any API that is compatible with OpenAI's
+// chat completions endpoint, such as vLLM, LM Studio or OpenAI itself
+type OpenAIClient struct {
+	api  *llmhttp.Client
+	opts Options
+}
+
+// NewOpenAIClient creates a client for the API at baseURL, e.g. "https://api.openai.com/v1"
+func NewOpenAIClient(baseURL, apiKey string, opts Options) (*OpenAIClient, error) {
+	if opts.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	return &OpenAIClient{api: llmhttp.New(baseURL, apiKey), opts: opts}, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature *float32      `json:"temperature,omitempty"`
+	TopP        *float32      `json:"top_p,omitempty"`
+	MaxTokens   int32         `json:"max_tokens,omitempty"`
+	Stream      bool          `json:"stream,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+		Delta   chatMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+func (c *OpenAIClient) request(prompt string, stream bool) openAIChatRequest {
+	return openAIChatRequest{
+		Model:       c.opts.Model,
+		Messages:    []chatMessage{{Role: "user", Content: prompt}},
+		Temperature: c.opts.Temperature,
+		TopP:        c.opts.TopP,
+		MaxTokens:   c.opts.MaxOutputTokens,
+		Stream:      stream,
+	}
+}
+
+func (c *OpenAIClient) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	if prompt == "" {
+		return "", errors.New("prompt cannot be empty")
+	}
+
+	var res openAIChatResponse
+	if err := c.api.PostJSON(ctx, "/chat/completions", c.request(prompt, false), &res); err != nil {
+		return "", err
+	}
+	if len(res.Choices) == 0 {
+		return "", errors.New("no response candidate")
+	}
+	return res.Choices[0].Message.Content, nil
+}
+
+// GenerateStream generates a response to prompt as a stream of server-sent
+// events, passing each piece of text to onChunk as it arrives. An error from
+// onChunk stops the stream and is returned.
+func (c *OpenAIClient) GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error {
+	if prompt == "" {
+		return errors.New("prompt cannot be empty")
+	}
+
+	resp, err := c.api.Post(ctx, "/chat/completions", c.request(prompt, true))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("decoding stream failed: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		if err := onChunk(chunk.Choices[0].Delta.Content); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
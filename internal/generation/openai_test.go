@@ -0,0 +1,89 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/llmhttp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIClient_GenerateContent(t *testing.T) {
+	temperature := float32(0.2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "local-model", req["model"])
+		assert.InDelta(t, 0.2, req["temperature"], 1e-6)
+		assert.NotContains(t, req, "top_p")
+		assert.NotContains(t, req, "stream")
+
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "Gravity pulls."}}]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewOpenAIClient(server.URL+"/v1/", "secret", Options{Model: "local-model", Temperature: &temperature})
+	assert.NoError(t, err)
+
+	text, err := client.GenerateContent(context.Background(), "Explain gravity")
+	assert.NoError(t, err)
+	assert.Equal(t, "Gravity pulls.", text)
+}
+
+func TestOpenAIClient_GenerateStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, true, req["stream"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"role\": \"assistant\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"Gravity \"}}]}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"choices\": [{\"delta\": {\"content\": \"pulls.\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	client, err := NewOpenAIClient(server.URL, "", Options{Model: "local-model"})
+	assert.NoError(t, err)
+
+	var chunks []string
+	err = client.GenerateStream(context.Background(), "Explain gravity", func(text string) error {
+		chunks = append(chunks, text)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Gravity ", "pulls."}, chunks)
+}
+
+func TestOpenAIClient_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		http.Error(w, `{"error": "slow down"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client, err := NewOpenAIClient(server.URL, "", Options{Model: "local-model"})
+	assert.NoError(t, err)
+
+	_, err = client.GenerateContent(context.Background(), "Explain gravity")
+	var statusErr *llmhttp.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.Code)
+	assert.Equal(t, "2", statusErr.Header.Get("Retry-After"))
+	assert.Contains(t, statusErr.Message, "slow down")
+}
+
+func TestNewOpenAIClient_RequiresModel(t *testing.T) {
+	_, err := NewOpenAIClient("http://localhost", "", Options{})
+	assert.Error(t, err)
+}
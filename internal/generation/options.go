@@ -0,0 +1,10 @@
+package generation
+
+// Options choose the model a client generates with and how it samples.
+// Parameters left unset use the provider's defaults.
+type Options struct {
+	Model           string
+	Temperature     *float32
+	TopP            *float32
+	MaxOutputTokens int32
+}
@@ -0,0 +1,140 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ScriptedClient generates text offline, for tests and local development.
+// With a fixture it answers every prompt with the fixture. Without one it
+// answers the question generator's prompt itself: it writes as many questions
+// of each type as the prompt asks for, each citing one of the context chunks
+// in the prompt and built from that chunk's first sentence.
+type ScriptedClient struct {
+	fixture string
+	// ChunkSize is how many bytes GenerateStream sends at a time
+	ChunkSize int
+}
+
+// NewScriptedClient creates a client that answers with fixture, or writes
+// its own questions if fixture is empty
+func NewScriptedClient(fixture string) *ScriptedClient {
+	return &ScriptedClient{fixture: fixture, ChunkSize: 64}
+}
+
+var (
+	// These match the mix and context sections of the question generator's prompt
+	scriptedMixLine = regexp.MustCompile(`(?m)^- (\d+) (\w+) question\(s\)$`)
+	scriptedChunk   = regexp.MustCompile(`(?s)\[(C\d+)\]\n(.*?)\n---\n`)
+	sentenceEnd     = regexp.MustCompile(`[.!?।]\s`)
+)
+
+func (c *ScriptedClient) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	if prompt == "" {
+		return "", errors.New("prompt cannot be empty")
+	}
+	if c.fixture != "" {
+		return c.fixture, nil
+	}
+	return scriptQuestions(prompt)
+}
+
+// GenerateStream sends the response GenerateContent would give in pieces of
+// ChunkSize bytes, never splitting a character
+func (c *ScriptedClient) GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error {
+	resp, err := c.GenerateContent(ctx, prompt)
+	if err != nil {
+		return err
+	}
+
+	for len(resp) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := min(max(c.ChunkSize, utf8.UTFMax), len(resp))
+		for n < len(resp) && !utf8.RuneStart(resp[n]) {
+			n--
+		}
+		if err := onChunk(resp[:n]); err != nil {
+			return err
+		}
+		resp = resp[n:]
+	}
+	return nil
+}
+
+type scriptedQuestion struct {
+	Type        string   `json:"type"`
+	Stem        string   `json:"stem"`
+	Options     []string `json:"options,omitempty"`
+	Answer      string   `json:"answer"`
+	Explanation string   `json:"explanation"`
+	Difficulty  string   `json:"difficulty"`
+	Marks       int      `json:"marks"`
+	Sources     []string `json:"sources"`
+}
+
+func scriptQuestions(prompt string) (string, error) {
+	chunks := scriptedChunk.FindAllStringSubmatch(prompt, -1)
+	if len(chunks) == 0 {
+		return "", errors.New("no fixture, and the prompt has no context chunks to write questions from")
+	}
+
+	var questions []scriptedQuestion
+	for _, line := range scriptedMixLine.FindAllStringSubmatch(prompt, -1) {
+		count, _ := strconv.Atoi(line[1])
+		for range count {
+			chunk := chunks[len(questions)%len(chunks)]
+			q := scriptQuestion(line[2], len(questions)+1, firstSentence(chunk[2]))
+			q.Sources = []string{chunk[1]}
+			questions = append(questions, q)
+		}
+	}
+
+	out, err := json.MarshalIndent(map[string]any{"questions": questions}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func scriptQuestion(questionType string, n int, sentence string) scriptedQuestion {
+	q := scriptedQuestion{
+		Type:        questionType,
+		Explanation: "Taken from the cited context.",
+		Difficulty:  "easy",
+		Marks:       1,
+	}
+	switch questionType {
+	case "mcq":
+		q.Stem = fmt.Sprintf("Question %d: which of these does the context state?", n)
+		q.Options = []string{sentence, "None of the other options", "The context does not say", "All of the other options"}
+		q.Answer = sentence
+	case "true_false":
+		q.Stem = fmt.Sprintf("Question %d: true or false? %s", n, sentence)
+		q.Answer = "true"
+	case "numerical":
+		q.Stem = fmt.Sprintf("Question %d: how many words are in \"%s\"?", n, sentence)
+		q.Answer = strconv.Itoa(len(strings.Fields(sentence)))
+	default:
+		q.Stem = fmt.Sprintf("Question %d: what does the context say?", n)
+		q.Answer = sentence
+	}
+	return q
+}
+
+// firstSentence returns the first sentence of text with whitespace collapsed
+func firstSentence(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if loc := sentenceEnd.FindStringIndex(text + " "); loc != nil {
+		text = text[:loc[1]-1]
+	}
+	return text
+}
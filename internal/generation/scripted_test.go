@@ -0,0 +1,77 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const scriptedPrompt = `
+Generate exactly:
+- 1 mcq question(s)
+- 2 numerical question(s)
+
+Context (each chunk starts with its ID in square brackets):
+[C1]
+Force equals mass times
+acceleration. It is measured in newtons.
+---
+[C2]
+বল একটি ভেক্টর রাশি। এর মান ও দিক আছে।
+---
+`
+
+func TestScriptedClient_WritesQuestionsFromPrompt(t *testing.T) {
+	resp, err := NewScriptedClient("").GenerateContent(context.Background(), scriptedPrompt)
+	assert.NoError(t, err)
+
+	var result struct {
+		Questions []scriptedQuestion `json:"questions"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(resp), &result))
+	assert.Len(t, result.Questions, 3)
+
+	mcq := result.Questions[0]
+	assert.Equal(t, "mcq", mcq.Type)
+	assert.Equal(t, "Force equals mass times acceleration.", mcq.Answer)
+	assert.Contains(t, mcq.Options, mcq.Answer)
+	assert.Equal(t, []string{"C1"}, mcq.Sources)
+
+	assert.Equal(t, "numerical", result.Questions[1].Type)
+	assert.Equal(t, "4", result.Questions[1].Answer)
+	assert.Equal(t, []string{"C2"}, result.Questions[1].Sources)
+	assert.Contains(t, result.Questions[1].Stem, "বল একটি ভেক্টর রাশি।")
+}
+
+func TestScriptedClient_Fixture(t *testing.T) {
+	client := NewScriptedClient(`{"questions": []}`)
+
+	resp, err := client.GenerateContent(context.Background(), "anything")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"questions": []}`, resp)
+
+	_, err = NewScriptedClient("").GenerateContent(context.Background(), "no context here")
+	assert.Error(t, err)
+}
+
+func TestScriptedClient_GenerateStream(t *testing.T) {
+	client := NewScriptedClient("")
+	client.ChunkSize = 5
+	want, err := client.GenerateContent(context.Background(), scriptedPrompt)
+	assert.NoError(t, err)
+
+	var chunks []string
+	err = client.GenerateStream(context.Background(), scriptedPrompt, func(text string) error {
+		chunks = append(chunks, text)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		assert.True(t, len(chunk) > 0 && strings.ToValidUTF8(chunk, "") == chunk, "chunk %q splits a character", chunk)
+	}
+	assert.Equal(t, want, strings.Join(chunks, ""))
+}
@@ -0,0 +1,132 @@
+package ingestion
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bijoyGlyphs maps the characters of SutonnyMJ and the other Bijoy ANSI
+// fonts to the Bengali they are drawn as. Keys are in the order the glyphs
+// are typed, which is visual order: the output still has to be reordered.
+// Reph is mapped to bijoyReph until then.
+var bijoyGlyphs = map[string]string{
+	// Vowels
+	"A": "অ", "Av": "আ", "B": "ই", "C": "ঈ", "D": "উ", "E": "ঊ", "F": "ঋ",
+	"G": "এ", "H": "ঐ", "I": "ও", "J": "ঔ",
+
+	// Consonants
+	"K": "ক", "L": "খ", "M": "গ", "N": "ঘ", "O": "ঙ", "P": "চ", "Q": "ছ",
+	"R": "জ", "S": "ঝ", "T": "ঞ", "U": "ট", "V": "ঠ", "W": "ড", "X": "ঢ",
+	"Y": "ণ", "Z": "ত", "_": "থ", "`": "দ", "a": "ধ", "b": "ন", "c": "প",
+	"d": "ফ", "e": "ব", "f": "ভ", "g": "ম", "h": "য", "i": "র", "j": "ল",
+	"k": "শ", "l": "ষ", "m": "স", "n": "হ", "o": "\u09a1\u09bc", "p": "\u09a2\u09bc",
+	"q": "\u09af\u09bc", "r": "ৎ",
+
+	// Signs
+	"s": "ং", "t": "ঃ", "u": "ঁ", "v": "া", "w": "ি", "x": "ী", "y": "ু",
+	"z": "ু", "~": "ূ", "…": "ৃ", "„": "ৃ", "†": "ে", "‡": "ে", "ˆ": "ৈ",
+	"‰": "ৈ", "Š": "ৗ", "&": "্", "|": "।",
+
+	// Digits
+	"0": "০", "1": "১", "2": "২", "3": "৩", "4": "৪",
+	"5": "৫", "6": "৬", "7": "৭", "8": "৮", "9": "৯",
+
+	// Reph, and the forms consonants take as the second part of a conjunct
+	"©": bijoyReph, "ª": "্র", "«": "্র", "Ö": "্র", "¨": "্য", "^": "্ব",
+	"¡": "্ব", "¦": "্ব", "Ÿ": "্ব", "¥": "্ম", "§": "্ম", "œ": "্ন",
+	"¬": "্ল", "ø": "্ল", "—": "্ত", "’": "্থ", "‹": "্ক", "Œ": "্ক্র",
+	"ú": "্প",
+
+	// The forms consonants take as the first part of a conjunct
+	"¯": "স্", "®": "ষ্", "¤": "ম্", "š": "ন্", "›": "ন্", "”": "চ্", "•": "ঙ্",
+
+	// Conjuncts and consonants with a vowel sign that have glyphs of their own
+	"°": "ক্ক", "±": "ক্ট", "³": "ক্ত", "µ": "ক্র", "¶": "ক্ষ", "ÿ": "ক্ষ",
+	"·": "ক্স", "¸": "গু", "»": "গ্ধ", "¼": "ঙ্ক", "½": "ঙ্গ", "¾": "জ্জ",
+	"À": "জ্ঝ", "Á": "জ্ঞ", "Â": "ঞ্চ", "Ã": "ঞ্ছ", "Ä": "ঞ্জ", "Å": "ঞ্ঝ",
+	"Æ": "ট্ট", "Ç": "ড্ড", "È": "ণ্ট", "É": "ণ্ঠ", "Ê": "ণ্ড", "Ë": "ত্ত",
+	"Ì": "ত্থ", "Ï": "দ্দ", "Ù": "দ্ধ", "Ú": "ন্ড", "Ü": "ন্ধ", "Ý": "ন্স",
+	"Þ": "প্ট", "ß": "প্ত", "à": "প্প", "á": "প্স", "â": "ব্জ", "ã": "ব্দ",
+	"ä": "ব্ধ", "å": "ভ্র", "ç": "ম্ফ", "é": "ল্ক", "ê": "ল্গ", "ë": "ল্ট",
+	"ì": "ল্ড", "í": "ল্প", "î": "ল্ফ", "ï": "শু", "ð": "শ্চ", "ñ": "শ্ছ",
+	"ò": "ষ্ণ", "ó": "ষ্ট", "ô": "ষ্ঠ", "õ": "ষ্ফ", "ö": "স্খ", "÷": "স্ট",
+	"ù": "স্ফ", "û": "হু", "ü": "হৃ", "ý": "হ্ন", "þ": "হ্ম",
+}
+
+// bijoyReph stands in for reph, which Bijoy types after the consonant it sits
+// on but Unicode spells as "র্" before it
+const bijoyReph = "\ue000"
+
+// bijoyKeys are the keys of bijoyGlyphs by their first character, longest first
+var bijoyKeys = func() map[rune][]string {
+	keys := make(map[rune][]string)
+	for k := range bijoyGlyphs {
+		r, _ := utf8.DecodeRuneInString(k)
+		keys[r] = append(keys[r], k)
+	}
+	for _, ks := range keys {
+		sort.Slice(ks, func(i, j int) bool { return len(ks[i]) > len(ks[j]) })
+	}
+	return keys
+}()
+
+// bijoyMarkers are glyphs common in Bijoy text but rare in any other: the
+// e-kar, reph, ya-phala and the first parts of the commonest conjuncts
+const bijoyMarkers = "†‡©¨ªÖ¯š"
+
+// bengaliCluster is a consonant with any consonants joined to it by hasanta
+const bengaliCluster = `[\x{0995}-\x{09B9}\x{09CE}]\x{09BC}?(?:\x{09CD}[\x{0995}-\x{09B9}]\x{09BC}?)*`
+
+var (
+	// A pre-base vowel sign typed before the cluster it belongs to
+	bijoyPreBase = regexp.MustCompile(`([িেৈ])(` + bengaliCluster + `)`)
+	// Reph typed after the cluster it sits on and its vowel signs
+	bijoyRephAfter = regexp.MustCompile(`(` + bengaliCluster + `)([\x{09BE}-\x{09CC}\x{09D7}]*)` + bijoyReph)
+)
+
+// isBijoy guesses whether text is Bengali typed in a Bijoy font: it has no
+// Bengali script of its own, and Bijoy's markers make up enough of it
+func isBijoy(text string) bool {
+	markers, letters := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Bengali, r):
+			return false
+		case strings.ContainsRune(bijoyMarkers, r):
+			markers++
+		case unicode.IsSpace(r):
+			continue
+		}
+		letters++
+	}
+	// About one in ten characters of Bijoy text is a marker; any in English
+	// text are stray symbols
+	return letters > 0 && markers*50 >= letters
+}
+
+// bijoyToUnicode converts Bijoy text to Unicode Bengali. Characters without
+// a Bengali glyph, such as punctuation, are kept.
+func bijoyToUnicode(text string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		glyph, n := string(r), size
+		for _, k := range bijoyKeys[r] {
+			if strings.HasPrefix(text[i:], k) {
+				glyph, n = bijoyGlyphs[k], len(k)
+				break
+			}
+		}
+		sb.WriteString(glyph)
+		i += n
+	}
+
+	out := bijoyPreBase.ReplaceAllString(sb.String(), "$2$1")
+	out = bijoyRephAfter.ReplaceAllString(out, "র্$1$2")
+	// Vowel signs split around the consonant come together again
+	out = strings.NewReplacer("\u09c7\u09be", "\u09cb", "\u09c7\u09d7", "\u09cc", bijoyReph, "র্").Replace(out)
+	return out
+}
@@ -1,40 +1,132 @@
 package ingestion
 
-// Chunker splits text into smaller chunks
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"backend/internal/domain"
+	"backend/internal/tokenizer"
+)
+
+// Chunker splits text into overlapping windows of a fixed number of tokens
 type Chunker struct {
+	// MaxChunkSize and Overlap are measured in tokens
 	MaxChunkSize int
 	Overlap      int
+	Tokenizer    tokenizer.Tokenizer
+}
+
+// Chunk is a piece of document text together with the pages it spans and,
+// when known, the chapter and section it is from
+type Chunk struct {
+	Content   string
+	PageStart int
+	PageEnd   int
+	Chapter   int
+	Section   string
 }
 
 func NewChunker(maxChunkSize, overlap int) *Chunker {
 	return &Chunker{
 		MaxChunkSize: maxChunkSize,
 		Overlap:      overlap,
+		Tokenizer:    tokenizer.Approximate{},
+	}
+}
+
+// NewChunkers returns a chunker for every strategy, all with the same size
+// budget so their chunks can be compared
+func NewChunkers(maxChunkSize, overlap int) map[domain.ChunkStrategy]SegmentChunker {
+	return map[domain.ChunkStrategy]SegmentChunker{
+		domain.ChunkStrategyFixed:      NewChunker(maxChunkSize, overlap),
+		domain.ChunkStrategyStructured: NewStructuredChunker(maxChunkSize, overlap),
 	}
 }
 
 func (c *Chunker) Chunk(text string) []string {
-	if len(text) <= c.MaxChunkSize {
+	tokens := c.Tokenizer.Split(text)
+	if len(tokens) <= c.MaxChunkSize {
 		return []string{text}
 	}
 
 	var chunks []string
-	runes := []rune(text)
+	for _, w := range c.windows(len(tokens)) {
+		chunks = append(chunks, strings.Join(tokens[w[0]:w[1]], ""))
+	}
+
+	return chunks
+}
+
+// ChunkSegments chunks the text of consecutive pages as one stream, so chunks
+// may cross page boundaries, and records the first and last page each chunk
+// draws from.
+func (c *Chunker) ChunkSegments(segments []Segment) []Chunk {
+	var sb strings.Builder
+	// starts[i] is the byte offset at which segments[i] begins
+	starts := make([]int, 0, len(segments))
+	for _, seg := range segments {
+		starts = append(starts, sb.Len())
+		sb.WriteString(seg.Text)
+		sb.WriteByte('\n')
+	}
+	text := sb.String()
 
-	for i := 0; i < len(runes); {
+	tokens := c.Tokenizer.Split(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+	if n := len(tokens); n > 1 && strings.TrimSpace(tokens[n-1]) == "" {
+		// Trailing whitespace is not worth a chunk of its own
+		tokens[n-2] += tokens[n-1]
+		tokens = tokens[:n-1]
+	}
+	// offsets[i] is the byte offset at which tokens[i] begins
+	offsets := make([]int, len(tokens)+1)
+	for i, t := range tokens {
+		offsets[i+1] = offsets[i] + len(t)
+	}
+
+	pageAt := func(offset int) int {
+		// Last segment starting at or before offset
+		i := sort.SearchInts(starts, offset+1) - 1
+		return segments[i].Page
+	}
+
+	var chunks []Chunk
+	for _, w := range c.windows(len(tokens)) {
+		start, end := offsets[w[0]], offsets[w[1]]
+		content := text[start:end]
+		// The whitespace a window starts with may end the previous page
+		first := end - len(strings.TrimLeftFunc(content, unicode.IsSpace))
+		chunks = append(chunks, Chunk{
+			Content:   content,
+			PageStart: pageAt(min(first, end-1)),
+			PageEnd:   pageAt(end - 1),
+		})
+	}
+
+	return chunks
+}
+
+// windows returns the [start, end) token ranges of a sliding window over n tokens
+func (c *Chunker) windows(n int) [][2]int {
+	var windows [][2]int
+
+	for i := 0; i < n; {
 		end := i + c.MaxChunkSize
-		if end > len(runes) {
-			end = len(runes)
+		if end > n {
+			end = n
 		}
 
-		chunks = append(chunks, string(runes[i:end]))
+		windows = append(windows, [2]int{i, end})
 
-		if end == len(runes) {
+		if end == n {
 			break
 		}
 
 		// Advance by chunkSize - overlap
-		// Ensure we move forward at least 1 character to avoid infinite loop
+		// Ensure we move forward at least 1 token to avoid infinite loop
 		step := c.MaxChunkSize - c.Overlap
 		if step < 1 {
 			step = 1
@@ -42,5 +134,5 @@ func (c *Chunker) Chunk(text string) []string {
 		i += step
 	}
 
-	return chunks
+	return windows
 }
@@ -7,22 +7,47 @@ import (
 )
 
 func TestChunkText(t *testing.T) {
-	chunker := NewChunker(10, 2) // MaxChunkSize 10, Overlap 2
+	chunker := NewChunker(3, 1) // MaxChunkSize 3 tokens, Overlap 1
 	text := "Hello world this is a test"
-	// "Hello worl" (10)
-	// "rld this i" (overlap 2 "rl", then take 10?)
-	// Let's implement recursive or simple sliding window.
-	// For RAG, usually recursive character text splitter.
-	// Let's test basic splitting.
-
-	// If implementation is simple:
-	// "Hello worl"
-	// "ld this is"
-	// "is a test"
 
+	// "Hell" "o" " worl" "d" " this" " is" " a" " test"
 	chunks := chunker.Chunk(text)
-	assert.NotEmpty(t, chunks)
+	assert.Equal(t, []string{"Hello worl", " world this", " this is a", " a test"}, chunks)
 	for _, c := range chunks {
-		assert.LessOrEqual(t, len(c), 10)
+		assert.LessOrEqual(t, chunker.Tokenizer.Count(c), 3)
+	}
+}
+
+func TestChunkSegments_TracksPages(t *testing.T) {
+	chunker := NewChunker(4, 0)
+	segments := []Segment{
+		{Page: 1, Text: "aaaa bbbb cccc"}, // one token per word
+		{Page: 2, Text: "dddd eeee"},
+		{Page: 4, Text: "ffff"}, // page 3 was empty
 	}
+
+	chunks := chunker.ChunkSegments(segments)
+	assert.Len(t, chunks, 2)
+
+	assert.Equal(t, "aaaa bbbb cccc\ndddd", chunks[0].Content)
+	assert.Equal(t, 1, chunks[0].PageStart)
+	assert.Equal(t, 2, chunks[0].PageEnd)
+
+	assert.Equal(t, " eeee\nffff\n", chunks[1].Content)
+	assert.Equal(t, 2, chunks[1].PageStart)
+	assert.Equal(t, 4, chunks[1].PageEnd)
+}
+
+func TestChunkSegments_PageStartSkipsLeadingNewline(t *testing.T) {
+	chunker := NewChunker(2, 0)
+	segments := []Segment{{Page: 1, Text: "aaaa bbbb"}, {Page: 2, Text: "cccc"}}
+
+	chunks := chunker.ChunkSegments(segments)
+	assert.Equal(t, "\ncccc\n", chunks[1].Content)
+	assert.Equal(t, 2, chunks[1].PageStart)
+}
+
+func TestChunkSegments_Empty(t *testing.T) {
+	chunker := NewChunker(10, 2)
+	assert.Empty(t, chunker.ChunkSegments(nil))
 }
@@ -0,0 +1,219 @@
+package ingestion
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize bounds how much of a single part of a zip-based document is
+// read, against archives that decompress to far more than they look
+const maxPartSize = 64 << 20
+
+// DOCXParser reads Word documents. Paragraphs in heading styles, or with an
+// outline level, place the text in chapters and sections. Pages are where
+// Word last laid them out or where the document breaks the page; without
+// either the document is a single page.
+type DOCXParser struct{}
+
+func NewDOCXParser() *DOCXParser {
+	return &DOCXParser{}
+}
+
+func (p *DOCXParser) Parse(r io.ReaderAt, size int64) ([]Segment, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open docx: %w", err)
+	}
+
+	// Without styles, only the built-in heading style IDs are known
+	levels := map[string]int{}
+	if styles := findZipFile(archive, "word/styles.xml"); styles != nil {
+		if levels, err = readDOCXStyles(styles); err != nil {
+			return nil, err
+		}
+	}
+
+	body := findZipFile(archive, "word/document.xml")
+	if body == nil {
+		return nil, fmt.Errorf("failed to open docx: word/document.xml is missing")
+	}
+	return readDOCXBody(body, levels)
+}
+
+func findZipFile(archive *zip.Reader, name string) *zip.File {
+	for _, f := range archive.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func openZipFile(f *zip.File) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, maxPartSize), rc}, nil
+}
+
+// "Heading1" or, in the style's name, "heading 1"
+var headingStyle = regexp.MustCompile(`(?i)^heading\s*([1-9])$`)
+
+// readDOCXStyles returns the outline level (0 for the top) of every paragraph
+// style that is a heading, by style ID. A style is a heading if it has an
+// outline level, is named "heading N", or is based on such a style.
+func readDOCXStyles(f *zip.File) (map[string]int, error) {
+	rc, err := openZipFile(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var styles struct {
+		Styles []struct {
+			Type    string `xml:"type,attr"`
+			ID      string `xml:"styleId,attr"`
+			Name    val    `xml:"name"`
+			BasedOn val    `xml:"basedOn"`
+			Outline *val   `xml:"pPr>outlineLvl"`
+		} `xml:"style"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&styles); err != nil {
+		return nil, fmt.Errorf("failed to parse docx styles: %w", err)
+	}
+
+	own := make(map[string]int)
+	basedOn := make(map[string]string)
+	for _, s := range styles.Styles {
+		if s.Type != "paragraph" {
+			continue
+		}
+		basedOn[s.ID] = s.BasedOn.Val
+		if s.Outline != nil {
+			// Level 9 is body text
+			if n, err := strconv.Atoi(s.Outline.Val); err == nil && n < 9 {
+				own[s.ID] = n
+			}
+		} else if m := headingStyle.FindStringSubmatch(s.Name.Val); m != nil {
+			own[s.ID] = int(m[1][0] - '1')
+		}
+	}
+
+	levels := make(map[string]int)
+	for id := range basedOn {
+		// Follow basedOn to the first style with a level, a few steps at most
+		for s, steps := id, 0; s != "" && steps < 10; s, steps = basedOn[s], steps+1 {
+			if n, ok := own[s]; ok {
+				levels[id] = n
+				break
+			}
+		}
+	}
+	return levels, nil
+}
+
+// val is an element whose value is in its w:val attribute
+type val struct {
+	Val string `xml:"val,attr"`
+}
+
+// readDOCXBody reads the paragraphs of document.xml in order
+func readDOCXBody(f *zip.File, levels map[string]int) ([]Segment, error) {
+	rc, err := openZipFile(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	b := newSegmentBuilder()
+	var text strings.Builder
+	level := -1 // outline level of the current paragraph; -1 for body text
+	inText := false
+
+	decoder := xml.NewDecoder(rc)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse docx: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Fallback":
+				// Markup-compatibility fallbacks repeat content for older readers
+				if err := decoder.Skip(); err != nil {
+					return nil, fmt.Errorf("failed to parse docx: %w", err)
+				}
+			case "p":
+				text.Reset()
+				level = -1
+			case "pStyle":
+				if n, ok := levels[attr(t, "val")]; ok {
+					level = n
+				} else if m := headingStyle.FindStringSubmatch(attr(t, "val")); m != nil {
+					level = int(m[1][0] - '1')
+				}
+			case "outlineLvl":
+				if n, err := strconv.Atoi(attr(t, "val")); err == nil && n < 9 {
+					level = n
+				}
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteByte('\t')
+			case "br", "cr":
+				if attr(t, "type") != "page" {
+					text.WriteByte('\n')
+					break
+				}
+				fallthrough
+			case "lastRenderedPageBreak":
+				// Text before the break stays on the earlier page
+				b.paragraph(text.String())
+				text.Reset()
+				b.pageBreak()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if level >= 0 {
+					b.heading(text.String(), level)
+				} else {
+					b.paragraph(text.String())
+				}
+				text.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+
+	return b.finish(), nil
+}
+
+// attr returns the value of the attribute of e with the given local name
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
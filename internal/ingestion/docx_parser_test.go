@@ -0,0 +1,59 @@
+package ingestion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const docxStyles = `<?xml version="1.0" encoding="UTF-8"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:style w:type="paragraph" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:pPr><w:outlineLvl w:val="0"/></w:pPr></w:style>
+  <w:style w:type="paragraph" w:styleId="1"><w:name w:val="শিরোনাম ২"/><w:pPr><w:outlineLvl w:val="1"/></w:pPr></w:style>
+  <w:style w:type="paragraph" w:styleId="MyChapter"><w:name w:val="My Chapter"/><w:basedOn w:val="Heading1"/></w:style>
+</w:styles>`
+
+func docxBody(paragraphs string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` + paragraphs + `</w:body></w:document>`
+}
+
+func TestDOCXParser_HeadingStylesAndPageBreaks(t *testing.T) {
+	data := buildZip(
+		"word/styles.xml", docxStyles,
+		"word/document.xml", docxBody(`
+<w:p><w:r><w:t>Preface.</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="MyChapter"/></w:pPr><w:r><w:t>অধ্যায় ৫: </w:t></w:r><w:r><w:t>বল</w:t></w:r></w:p>
+<w:p><w:r><w:t>বল হলো ধাক্কা</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve"> বা টান।</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="1"/></w:pPr><w:r><w:t>৫.১ ঘর্ষণ</w:t></w:r></w:p>
+<w:p><w:r><w:t>Before the break.</w:t><w:br w:type="page"/><w:lastRenderedPageBreak/><w:t>After it.</w:t></w:r></w:p>
+<w:p><w:r><w:t>Line one</w:t><w:br/><w:t>line two</w:t><w:delText>deleted</w:delText></w:r></w:p>
+`))
+
+	segments, err := NewDOCXParser().Parse(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{
+		{Page: 1, Text: "Preface."},
+		{Page: 1, Chapter: 5, Text: "অধ্যায় ৫: বল\nবল হলো ধাক্কা\t বা টান।"},
+		{Page: 1, Chapter: 5, Section: "৫.১ ঘর্ষণ", Text: "৫.১ ঘর্ষণ\nBefore the break."},
+		{Page: 2, Chapter: 5, Section: "৫.১ ঘর্ষণ", Text: "After it.\n\nLine one\nline two"},
+	}, segments)
+}
+
+func TestDOCXParser_BuiltInHeadingsWithoutStyles(t *testing.T) {
+	data := buildZip("word/document.xml", docxBody(`
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Chapter 2</w:t></w:r></w:p>
+<w:p><w:r><w:t>Motion.</w:t></w:r></w:p>`))
+
+	segments, err := NewDOCXParser().Parse(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{{Page: 1, Chapter: 2, Text: "Chapter 2\nMotion."}}, segments)
+}
+
+func TestDOCXParser_NotADocument(t *testing.T) {
+	data := buildZip("word/other.xml", "<x/>")
+	_, err := NewDOCXParser().Parse(bytes.NewReader(data), int64(len(data)))
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package ingestion
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// EPUBParser reads EPUB books. Every content document in the book's reading
+// order starts a new page, as do the page-break markers within them, and
+// their headings place the text in chapters and sections.
+type EPUBParser struct{}
+
+func NewEPUBParser() *EPUBParser {
+	return &EPUBParser{}
+}
+
+func (p *EPUBParser) Parse(r io.ReaderAt, size int64) ([]Segment, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open epub: %w", err)
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			Path string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := decodeZipXML(archive, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("failed to open epub: no package document")
+	}
+	opfPath := container.Rootfiles[0].Path
+
+	var pkg struct {
+		Items []struct {
+			ID         string `xml:"id,attr"`
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := decodeZipXML(archive, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	// Manifest hrefs are URLs relative to the package document
+	hrefs := make(map[string]string)
+	for _, item := range pkg.Items {
+		if !strings.Contains(item.MediaType, "html") || strings.Contains(item.Properties, "nav") {
+			continue
+		}
+		href, err := url.PathUnescape(item.Href)
+		if err != nil {
+			href = item.Href
+		}
+		hrefs[item.ID] = path.Join(path.Dir(opfPath), href)
+	}
+
+	b := newSegmentBuilder()
+	for _, ref := range pkg.Spine {
+		name, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		f := findZipFile(archive, name)
+		if f == nil {
+			return nil, fmt.Errorf("failed to open epub: %s is missing", name)
+		}
+
+		b.pageBreak()
+		rc, err := openZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		err = readHTML(rc, b)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+
+	return b.finish(), nil
+}
+
+// decodeZipXML decodes the XML file name in archive into v
+func decodeZipXML(archive *zip.Reader, name string, v any) error {
+	f := findZipFile(archive, name)
+	if f == nil {
+		return fmt.Errorf("failed to open epub: %s is missing", name)
+	}
+	rc, err := openZipFile(f)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package ingestion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEPUBParser_ReadsSpineInOrder(t *testing.T) {
+	xhtml := func(body string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>t</title></head><body>` + body + `</body></html>`
+	}
+	data := buildZip(
+		"mimetype", "application/epub+zip",
+		"META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`,
+		"OEBPS/content.opf", `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="c2" href="text/chapter%202.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1" href="text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="css" href="style.css" media-type="text/css"/>
+  </manifest>
+  <spine><itemref idref="nav"/><itemref idref="c1"/><itemref idref="c2"/></spine>
+</package>`,
+		"OEBPS/nav.xhtml", xhtml(`<p>Table of contents</p>`),
+		"OEBPS/text/chapter1.xhtml", xhtml(`<h1>Chapter 1: Motion</h1><p>Things move.</p><span epub:type="pagebreak" title="2"/><p>They keep moving.</p>`),
+		"OEBPS/text/chapter 2.xhtml", xhtml(`<h1>Chapter 2: Force</h1><h2>2.1 Friction</h2><p>Friction opposes motion.</p>`),
+		"OEBPS/style.css", "p { margin: 0 }",
+	)
+
+	segments, err := NewEPUBParser().Parse(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{
+		{Page: 1, Chapter: 1, Text: "Chapter 1: Motion\nThings move."},
+		{Page: 2, Chapter: 1, Text: "They keep moving."},
+		{Page: 3, Chapter: 2, Text: "Chapter 2: Force"},
+		{Page: 3, Chapter: 2, Section: "2.1 Friction", Text: "2.1 Friction\nFriction opposes motion."},
+	}, segments)
+}
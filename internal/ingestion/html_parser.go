@@ -0,0 +1,203 @@
+package ingestion
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLParser reads HTML and XHTML. Headings h1 to h6 place the text in
+// chapters and sections, and CSS page breaks or EPUB page-break markers
+// separate pages; without them the file is a single page.
+type HTMLParser struct{}
+
+func NewHTMLParser() *HTMLParser {
+	return &HTMLParser{}
+}
+
+func (p *HTMLParser) Parse(r io.ReaderAt, size int64) ([]Segment, error) {
+	b := newSegmentBuilder()
+	if err := readHTML(io.NewSectionReader(r, 0, size), b); err != nil {
+		return nil, err
+	}
+	return b.finish(), nil
+}
+
+// readHTML parses an HTML document and adds its text to b
+func readHTML(r io.Reader, b *segmentBuilder) error {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return fmt.Errorf("failed to parse html: %w", err)
+	}
+	w := &htmlWalker{b: b}
+	w.walk(doc)
+	w.endBlock()
+	return nil
+}
+
+// htmlSkipped are elements whose text is not part of the document's content
+var htmlSkipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Nav: true, atom.Svg: true, atom.Math: true,
+}
+
+// htmlBlocks are elements that start and end a paragraph
+var htmlBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Li: true, atom.Blockquote: true,
+	atom.Tr: true, atom.Dt: true, atom.Dd: true, atom.Figcaption: true, atom.Caption: true,
+	atom.Section: true, atom.Article: true, atom.Aside: true, atom.Header: true,
+	atom.Footer: true, atom.Main: true, atom.Table: true, atom.Ul: true, atom.Ol: true,
+	atom.Dl: true, atom.Hr: true, atom.Body: true,
+}
+
+var htmlHeadingDepth = map[atom.Atom]int{
+	atom.H1: 0, atom.H2: 1, atom.H3: 2, atom.H4: 3, atom.H5: 4, atom.H6: 5,
+}
+
+// htmlWalker collects the text of the current block until the next block
+// boundary, where it is added to the builder as a paragraph
+type htmlWalker struct {
+	b     *segmentBuilder
+	text  strings.Builder
+	inPre int
+}
+
+func (w *htmlWalker) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.inPre > 0 {
+			w.text.WriteString(n.Data)
+		} else {
+			// Line breaks in the source are only whitespace
+			w.text.WriteString(collapseSpace(n.Data))
+		}
+		return
+	case html.ElementNode:
+	default:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			w.walk(c)
+		}
+		return
+	}
+
+	if htmlSkipped[n.DataAtom] {
+		return
+	}
+	if isPageBreak(n) {
+		w.endBlock()
+		w.b.pageBreak()
+	}
+
+	if depth, ok := htmlHeadingDepth[n.DataAtom]; ok {
+		w.endBlock()
+		w.b.heading(nodeText(n), depth)
+		return
+	}
+
+	switch {
+	case n.DataAtom == atom.Br:
+		w.text.WriteByte('\n')
+		return
+	case n.DataAtom == atom.Pre:
+		// Preformatted text keeps its line breaks and spacing
+		w.endBlock()
+		w.inPre++
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			w.walk(c)
+		}
+		w.endBlock()
+		w.inPre--
+		return
+	case n.DataAtom == atom.Td || n.DataAtom == atom.Th:
+		w.text.WriteByte(' ')
+	case htmlBlocks[n.DataAtom]:
+		w.endBlock()
+		defer w.endBlock()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+}
+
+// endBlock adds the text collected so far as a paragraph
+func (w *htmlWalker) endBlock() {
+	var lines []string
+	for _, line := range strings.Split(w.text.String(), "\n") {
+		if w.inPre == 0 {
+			line = strings.Join(strings.Fields(line), " ")
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	w.b.paragraph(strings.Join(lines, "\n"))
+	w.text.Reset()
+}
+
+// isPageBreak reports whether an element starts a new page: an EPUB
+// page-break marker, or an element styled to break the page before it
+func isPageBreak(n *html.Node) bool {
+	for _, a := range n.Attr {
+		switch {
+		case a.Key == "epub:type":
+			if strings.Contains(a.Val, "pagebreak") {
+				return true
+			}
+		case a.Key == "role":
+			if a.Val == "doc-pagebreak" {
+				return true
+			}
+		case a.Key == "style":
+			style := strings.ReplaceAll(strings.ToLower(a.Val), " ", "")
+			if strings.Contains(style, "page-break-before:always") || strings.Contains(style, "break-before:page") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeText returns the text inside n, with whitespace collapsed
+func nodeText(n *html.Node) string {
+	var buf bytes.Buffer
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && htmlSkipped[n.DataAtom] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// collapseSpace turns each run of whitespace in s into a single space
+func collapseSpace(s string) string {
+	var buf strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			space = true
+			continue
+		}
+		if space {
+			buf.WriteByte(' ')
+			space = false
+		}
+		buf.WriteRune(r)
+	}
+	if space {
+		buf.WriteByte(' ')
+	}
+	return buf.String()
+}
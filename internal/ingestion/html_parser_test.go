@@ -0,0 +1,34 @@
+package ingestion
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLParser_HeadingsAndPages(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head><title>Physics</title><style>p { color: red }</style></head>
+<body>
+  <nav><a href="#c5">Chapter 5</a></nav>
+  <h1 id="c5">Chapter 5:
+    Force</h1>
+  <p>A <b>force</b> is a
+     push or a pull.</p>
+  <script>var x = 1;</script>
+  <h2>5.1 Friction</h2>
+  <ul><li>Static</li><li>Kinetic &amp; rolling</li></ul>
+  <p style="page-break-before: always">First line<br>second line</p>
+  <pre>F = μ N
+  exactly</pre>
+</body></html>`
+
+	segments, err := NewHTMLParser().Parse(strings.NewReader(page), int64(len(page)))
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{
+		{Page: 1, Chapter: 5, Text: "Chapter 5: Force\nA force is a push or a pull."},
+		{Page: 1, Chapter: 5, Section: "5.1 Friction", Text: "5.1 Friction\nStatic\n\nKinetic & rolling"},
+		{Page: 2, Chapter: 5, Section: "5.1 Friction", Text: "First line\nsecond line\n\nF = μ N\n  exactly"},
+	}, segments)
+}
@@ -0,0 +1,326 @@
+package ingestion
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer cleans up the text extracted from a document before it is chunked
+type Normalizer interface {
+	Normalize(segments []Segment) []Segment
+}
+
+// NormalizerFunc lets an ordinary function be a Normalizer
+type NormalizerFunc func(segments []Segment) []Segment
+
+func (f NormalizerFunc) Normalize(segments []Segment) []Segment {
+	return f(segments)
+}
+
+// Pipeline runs normalizers one after the other
+type Pipeline []Normalizer
+
+func (p Pipeline) Normalize(segments []Segment) []Segment {
+	for _, n := range p {
+		segments = n.Normalize(segments)
+	}
+	return segments
+}
+
+// Normalization steps, by the names configuration uses
+const (
+	// NormalizeLegacyFonts converts pages typed in Bijoy fonts such as
+	// SutonnyMJ to Unicode Bengali
+	NormalizeLegacyFonts = "legacy-fonts"
+	// NormalizeUnicode composes text to NFC, repairs Bengali vowel signs
+	// extracted in visual order, expands ligatures and drops soft hyphens
+	NormalizeUnicode = "unicode"
+	// NormalizeRunningHeads strips headers, footers and page numbers repeated
+	// at the top or bottom of consecutive pages
+	NormalizeRunningHeads = "running-heads"
+	// NormalizeHyphenation rejoins words hyphenated across lines and pages
+	NormalizeHyphenation = "hyphenation"
+)
+
+// DefaultNormalizeSteps are all the steps, in the order they work best in.
+// Running heads go first, while an English page number on a Bijoy page is
+// still English rather than converted along with the page, and must be gone
+// before words broken across pages can be rejoined.
+var DefaultNormalizeSteps = []string{NormalizeRunningHeads, NormalizeLegacyFonts, NormalizeUnicode, NormalizeHyphenation}
+
+var normalizeSteps = map[string]NormalizerFunc{
+	NormalizeLegacyFonts:  convertLegacyFonts,
+	NormalizeUnicode:      normalizeUnicode,
+	NormalizeRunningHeads: removeRunningHeads,
+	NormalizeHyphenation:  rejoinHyphenation,
+}
+
+// NewNormalizer returns a pipeline of the named steps, in the order given
+func NewNormalizer(steps []string) (Pipeline, error) {
+	var p Pipeline
+	for _, name := range steps {
+		step, ok := normalizeSteps[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown normalization step %q", name)
+		}
+		p = append(p, step)
+	}
+	return p, nil
+}
+
+// DefaultNormalizer returns a pipeline of DefaultNormalizeSteps
+func DefaultNormalizer() Pipeline {
+	var p Pipeline
+	for _, name := range DefaultNormalizeSteps {
+		p = append(p, normalizeSteps[name])
+	}
+	return p
+}
+
+// mapText applies f to the text of every segment
+func mapText(segments []Segment, f func(string) string) []Segment {
+	out := make([]Segment, len(segments))
+	for i, seg := range segments {
+		seg.Text = f(seg.Text)
+		out[i] = seg
+	}
+	return out
+}
+
+// convertLegacyFonts converts the segments that look typed in a Bijoy font.
+// The whole segment is converted, as without the font names the text alone
+// cannot tell which words were set in a Latin font.
+func convertLegacyFonts(segments []Segment) []Segment {
+	return mapText(segments, func(text string) string {
+		if !isBijoy(text) {
+			return text
+		}
+		return bijoyToUnicode(text)
+	})
+}
+
+var (
+	// A pre-base vowel sign at the start of a word, which PDF text extraction
+	// leaves in front of the consonant cluster it is drawn before
+	visualPreBase = regexp.MustCompile(`(^|[^\x{0980}-\x{09FF}])([িেৈ])(` + bengaliCluster + `)`)
+
+	unicodeReplacer = strings.NewReplacer(
+		// A soft hyphen at a line break marks where a word was split
+		"\u00ad\n", "",
+		"\u00ad", "",
+		"\u200b", "", // zero-width space
+		"\ufeff", "", // byte order mark
+		"\u25cc", "", // dotted circle some extractors put under lone signs
+		"\ufb00", "ff", "\ufb01", "fi", "\ufb02", "fl", "\ufb03", "ffi", "\ufb04", "ffl", "\ufb05", "st", "\ufb06", "st",
+	)
+)
+
+func normalizeUnicode(segments []Segment) []Segment {
+	return mapText(segments, func(text string) string {
+		text = unicodeReplacer.Replace(text)
+		text = visualPreBase.ReplaceAllString(text, "$1$3$2")
+		return norm.NFC.String(text)
+	})
+}
+
+// runningHeadLines is how many lines at the top and at the bottom of a page
+// are looked at for running heads
+const runningHeadLines = 2
+
+// headKey is what a header or footer line is recognised by: lower-cased,
+// with its spacing evened out and any number standing for every number, so
+// "Page 12" and "Page 13" are the same footer
+func headKey(line string) string {
+	var sb strings.Builder
+	digit := false
+	for _, r := range strings.ToLower(strings.Join(strings.Fields(line), " ")) {
+		if unicode.IsDigit(r) {
+			if !digit {
+				sb.WriteByte('#')
+			}
+			digit = true
+			continue
+		}
+		digit = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// removeRunningHeads strips the lines at the top or bottom of a page that
+// recur there on at least three pages in a row, allowing a page between them
+// for heads that alternate between left and right pages. A heading that
+// starts a chapter is kept where its run starts, even when the chapter's
+// running head repeats it.
+func removeRunningHeads(segments []Segment) []Segment {
+	// A page's top is the start of its first segment and its bottom the end
+	// of its last one
+	first := make(map[int]int)
+	last := make(map[int]int)
+	var pages []int
+	for i, seg := range segments {
+		if _, ok := first[seg.Page]; !ok {
+			first[seg.Page] = i
+			pages = append(pages, seg.Page)
+		}
+		last[seg.Page] = i
+	}
+	sort.Ints(pages)
+
+	// The pages each key appears on at the top or bottom
+	seen := make(map[string][]int)
+	zoneKeys := func(page int) map[string]bool {
+		keys := make(map[string]bool)
+		for _, line := range edgeLines(segments[first[page]].Text, true) {
+			keys[headKey(line)] = true
+		}
+		for _, line := range edgeLines(segments[last[page]].Text, false) {
+			keys[headKey(line)] = true
+		}
+		return keys
+	}
+	pageKeys := make(map[int]map[string]bool, len(pages))
+	for _, page := range pages {
+		pageKeys[page] = zoneKeys(page)
+		for k := range pageKeys[page] {
+			seen[k] = append(seen[k], page)
+		}
+	}
+
+	running := make(map[string]bool)
+	for k, on := range seen {
+		if k != "" && longestRun(on) >= 3 {
+			running[k] = true
+		}
+	}
+	if len(running) == 0 {
+		return segments
+	}
+
+	out := append([]Segment(nil), segments...)
+	for _, page := range pages {
+		strip := func(line string) bool {
+			k := headKey(line)
+			if !running[k] {
+				return false
+			}
+			if _, ok := chapterNumber(strings.Join(strings.Fields(line), " ")); ok {
+				// Keep the chapter heading where the run starts
+				return pageKeys[page-1][k] || pageKeys[page-2][k]
+			}
+			return true
+		}
+		i, j := first[page], last[page]
+		out[i].Text = stripEdge(out[i].Text, true, strip)
+		out[j].Text = stripEdge(out[j].Text, false, strip)
+	}
+
+	// Drop segments left empty
+	kept := out[:0]
+	for _, seg := range out {
+		if strings.TrimSpace(seg.Text) != "" {
+			kept = append(kept, seg)
+		}
+	}
+	return kept
+}
+
+// edgeLines returns the first (top) or last runningHeadLines non-blank lines of text
+func edgeLines(text string, top bool) []string {
+	var lines []string
+	all := strings.Split(text, "\n")
+	for n := range all {
+		if !top {
+			n = len(all) - 1 - n
+		}
+		if strings.TrimSpace(all[n]) == "" {
+			continue
+		}
+		lines = append(lines, all[n])
+		if len(lines) == runningHeadLines {
+			break
+		}
+	}
+	return lines
+}
+
+// stripEdge removes from the top or bottom of text the lines strip picks,
+// among the runningHeadLines non-blank lines there
+func stripEdge(text string, top bool, strip func(string) bool) string {
+	lines := strings.Split(text, "\n")
+	removed := make(map[int]bool)
+	checked := 0
+	for n := 0; n < len(lines) && checked < runningHeadLines; n++ {
+		i := n
+		if !top {
+			i = len(lines) - 1 - n
+		}
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		checked++
+		removed[i] = strip(lines[i])
+	}
+
+	kept := lines[:0]
+	for i, line := range lines {
+		if !removed[i] {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// longestRun returns the length of the longest run of pages, sorted, that
+// are at most two apart
+func longestRun(pages []int) int {
+	best, run := 0, 0
+	for i, p := range pages {
+		if i > 0 && p-pages[i-1] <= 2 {
+			run++
+		} else {
+			run = 1
+		}
+		best = max(best, run)
+	}
+	return best
+}
+
+var (
+	// A word broken with a hyphen at the end of a line. The rest of a Latin
+	// word starts with a lower-case letter, so "Newton-\nRaphson" is kept;
+	// Bengali has no case and is always rejoined.
+	latinLineHyphen   = regexp.MustCompile(`(\p{L}\p{M}*)[-‐]\n[ \t]*(\p{Ll})`)
+	bengaliLineHyphen = regexp.MustCompile(`([\x{0980}-\x{09FF}])[-‐]\n[ \t]*([\x{0980}-\x{09FF}])`)
+	// The same at the end of a page, and the start of the next page
+	pageEndHyphen   = regexp.MustCompile(`(\p{L}\p{M}*)[-‐]\s*$`)
+	pageStartOfWord = regexp.MustCompile(`^\s*([\p{Ll}\x{0980}-\x{09FF}][\p{L}\p{M}]*)[^\S\n]?`)
+)
+
+// rejoinHyphenation joins words hyphenated at the end of a line. A word
+// broken at the end of a page is completed from the start of the next one.
+func rejoinHyphenation(segments []Segment) []Segment {
+	out := mapText(segments, func(text string) string {
+		text = latinLineHyphen.ReplaceAllString(text, "$1$2")
+		return bengaliLineHyphen.ReplaceAllString(text, "$1$2")
+	})
+
+	for i := 0; i+1 < len(out); i++ {
+		if !pageEndHyphen.MatchString(out[i].Text) {
+			continue
+		}
+		m := pageStartOfWord.FindStringSubmatchIndex(out[i+1].Text)
+		if m == nil {
+			continue
+		}
+		rest := out[i+1].Text[m[2]:m[3]]
+		out[i].Text = pageEndHyphen.ReplaceAllString(out[i].Text, "$1") + rest
+		out[i+1].Text = out[i+1].Text[m[1]:]
+	}
+	return out
+}
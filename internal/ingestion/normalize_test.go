@@ -0,0 +1,69 @@
+package ingestion
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the normalization tests")
+
+// testNormalizerGolden runs normalizer over every testdata/normalize/<dir>/*.txt
+// and compares the result with the .golden file next to it. Pages are
+// separated by form feeds in both, as TextParser reads them.
+func testNormalizerGolden(t *testing.T, dir string, normalizer Normalizer) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "normalize", dir, "*.txt"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, inputs, "no golden tests for %s", dir)
+
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			assert.NoError(t, err)
+			segments, err := NewTextParser().Parse(strings.NewReader(string(data)), int64(len(data)))
+			assert.NoError(t, err)
+
+			var pages []string
+			for _, seg := range normalizer.Normalize(segments) {
+				pages = append(pages, seg.Text)
+			}
+			got := strings.Join(pages, "\f")
+
+			golden := strings.TrimSuffix(input, ".txt") + ".golden"
+			if *update {
+				assert.NoError(t, os.WriteFile(golden, []byte(got), 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.Equal(t, string(want), got)
+		})
+	}
+}
+
+func TestNormalizeSteps_Golden(t *testing.T) {
+	for _, step := range DefaultNormalizeSteps {
+		t.Run(step, func(t *testing.T) {
+			normalizer, err := NewNormalizer([]string{step})
+			assert.NoError(t, err)
+			testNormalizerGolden(t, step, normalizer)
+		})
+	}
+}
+
+func TestDefaultNormalizer_Golden(t *testing.T) {
+	testNormalizerGolden(t, "pipeline", DefaultNormalizer())
+}
+
+func TestNewNormalizer_UnknownStep(t *testing.T) {
+	_, err := NewNormalizer([]string{"unicode", "spellcheck"})
+	assert.ErrorContains(t, err, `unknown normalization step "spellcheck"`)
+}
+
+func TestNormalizer_KeepsSegmentPlacement(t *testing.T) {
+	segments := []Segment{{Page: 4, Chapter: 2, Section: "2.1 Speed", Text: "eﬃcient"}}
+	assert.Equal(t, []Segment{{Page: 4, Chapter: 2, Section: "2.1 Speed", Text: "efficient"}}, DefaultNormalizer().Normalize(segments))
+}
@@ -3,24 +3,38 @@ package ingestion
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/ledongthuc/pdf"
 )
 
+// Segment is the text extracted from a single page of a document, or from
+// part of one when a chapter or section starts partway down the page
+type Segment struct {
+	Page int // 1-based page number
+	Text string
+	// Chapter and Section place the text in the book when that is known:
+	// Chapter is 0 and Section empty when it is not
+	Chapter int
+	Section string
+}
+
 type PDFParser struct{}
 
 func NewPDFParser() *PDFParser {
 	return &PDFParser{}
 }
 
-func (p *PDFParser) Parse(r io.ReaderAt, size int64) (string, error) {
+// Parse extracts the text of every page as its own segment. Pages that are
+// empty or cannot be decoded are skipped, so segment page numbers may have gaps.
+func (p *PDFParser) Parse(r io.ReaderAt, size int64) ([]Segment, error) {
 	// ledongthuc/pdf NewReader expects io.ReaderAt and size
 	reader, err := pdf.NewReader(r, size)
 	if err != nil {
-		return "", fmt.Errorf("failed to create pdf reader: %w", err)
+		return nil, fmt.Errorf("failed to create pdf reader: %w", err)
 	}
 
-	var content string
+	var segments []Segment
 	for pageIndex := 1; pageIndex <= reader.NumPage(); pageIndex++ {
 		p := reader.Page(pageIndex)
 		if p.V.IsNull() {
@@ -31,8 +45,100 @@ func (p *PDFParser) Parse(r io.ReaderAt, size int64) (string, error) {
 		if err != nil {
 			continue
 		}
-		content += text + "\n"
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		segments = append(segments, Segment{Page: pageIndex, Text: text})
+	}
+
+	if entries := readOutline(reader); len(entries) > 0 {
+		segments = applyOutline(segments, entries)
+	}
+
+	return segments, nil
+}
+
+// maxOutlineEntries bounds the outline walk, which a malformed file could
+// otherwise send round a cycle forever
+const maxOutlineEntries = 10000
+
+// readOutline returns the document's bookmarks that point at a page, in
+// document order. Entries whose destination cannot be resolved are skipped.
+func readOutline(reader *pdf.Reader) []outlineEntry {
+	root := reader.Trailer().Key("Root")
+	first := root.Key("Outlines").Key("First")
+	if first.IsNull() {
+		return nil
+	}
+
+	// The library hides object identity, so pages are told apart by their
+	// printed dictionaries, which differ at least in their content references
+	pages := make(map[string]int)
+	for i := reader.NumPage(); i >= 1; i-- {
+		pages[reader.Page(i).V.String()] = i
+	}
+
+	var entries []outlineEntry
+	seen := 0
+	var walk func(item pdf.Value, depth int)
+	walk = func(item pdf.Value, depth int) {
+		for ; !item.IsNull() && seen < maxOutlineEntries; item = item.Key("Next") {
+			seen++
+			target := outlineTarget(root, item)
+			if page, ok := pages[target.String()]; ok && !target.IsNull() {
+				entries = append(entries, outlineEntry{
+					title: item.Key("Title").Text(),
+					page:  page,
+					depth: depth,
+				})
+			}
+			walk(item.Key("First"), depth+1)
+		}
+	}
+	walk(first, 0)
+
+	return entries
+}
+
+// outlineTarget resolves the page an outline item points at, through its Dest
+// or its GoTo action and, for named destinations, the catalog's Dests
+// dictionary or Dests name tree
+func outlineTarget(root, item pdf.Value) pdf.Value {
+	dest := item.Key("Dest")
+	if dest.IsNull() {
+		if action := item.Key("A"); action.Key("S").Name() == "GoTo" {
+			dest = action.Key("D")
+		}
 	}
 
-	return content, nil
+	switch dest.Kind() {
+	case pdf.Name:
+		dest = root.Key("Dests").Key(dest.Name())
+	case pdf.String:
+		dest = lookupName(root.Key("Names").Key("Dests"), dest.RawString(), 0)
+	}
+	if dest.Kind() == pdf.Dict {
+		dest = dest.Key("D")
+	}
+	return dest.Index(0)
+}
+
+// lookupName finds key in a PDF name tree. depth guards against cycles.
+func lookupName(node pdf.Value, key string, depth int) pdf.Value {
+	if node.IsNull() || depth > 32 {
+		return pdf.Value{}
+	}
+	names := node.Key("Names")
+	for i := 0; i+1 < names.Len(); i += 2 {
+		if names.Index(i).RawString() == key {
+			return names.Index(i + 1)
+		}
+	}
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		if v := lookupName(kids.Index(i), key, depth+1); !v.IsNull() {
+			return v
+		}
+	}
+	return pdf.Value{}
 }
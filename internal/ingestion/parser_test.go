@@ -2,6 +2,7 @@ package ingestion
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,3 +23,57 @@ func TestParsePDF_EmptyFile(t *testing.T) {
 
 // In a real scenario, we would add a test file resource and test parsing it.
 // For now, testing logic that relies on the library behavior might be integration testing.
+
+// buildPDF assembles a PDF from its numbered objects, 1-based, working out the
+// cross-reference table. Object 1 must be the catalog.
+func buildPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func textStream(text string) string {
+	content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+}
+
+func TestParsePDF_AppliesOutline(t *testing.T) {
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 9 0 R /Dests << /friction [5 0 R /Fit] >> >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 8 0 R >> >> /Contents 6 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 8 0 R >> >> /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 8 0 R >> >> /Contents 13 0 R >>",
+		textStream("Preface"),
+		textStream("Force"),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Outlines /First 10 0 R /Last 10 0 R /Count 3 >>",
+		// Chapter 5 on page 2, with a section on page 3 through a named destination
+		"<< /Title (Chapter 5: Force) /Parent 9 0 R /A << /S /GoTo /D [4 0 R /Fit] >> /First 11 0 R /Last 12 0 R >>",
+		"<< /Title (5.1 What is force) /Parent 10 0 R /Dest [4 0 R /XYZ 0 700 0] /Next 12 0 R >>",
+		"<< /Title (5.2 Friction) /Parent 10 0 R /Prev 11 0 R /Dest /friction >>",
+		textStream("Friction"),
+	)
+
+	segments, err := NewPDFParser().Parse(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Len(t, segments, 3)
+
+	assert.Equal(t, 0, segments[0].Chapter)
+	assert.Equal(t, "", segments[0].Section)
+	assert.Equal(t, 5, segments[1].Chapter)
+	assert.Equal(t, "5.1 What is force", segments[1].Section)
+	assert.Equal(t, 5, segments[2].Chapter)
+	assert.Equal(t, "5.2 Friction", segments[2].Section)
+}
@@ -0,0 +1,159 @@
+package ingestion
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"backend/internal/domain"
+)
+
+// Formats the built-in parsers read, by MIME type
+const (
+	FormatPDF      = "application/pdf"
+	FormatDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	FormatEPUB     = "application/epub+zip"
+	FormatHTML     = "text/html"
+	FormatMarkdown = "text/markdown"
+	FormatText     = "text/plain"
+)
+
+// Registry is a Parser for every format it has a parser for. The format of a
+// file is sniffed from its content, so a misnamed file is still read right;
+// the file name only tells apart text formats whose content can be ambiguous,
+// such as Markdown and plain text.
+type Registry struct {
+	parsers    map[string]Parser // by MIME type
+	extensions map[string]string // MIME type by lower-case extension, with the dot
+}
+
+// NewRegistry returns a registry with the built-in parsers
+func NewRegistry() *Registry {
+	r := &Registry{
+		parsers:    make(map[string]Parser),
+		extensions: make(map[string]string),
+	}
+	r.Register(FormatPDF, NewPDFParser(), ".pdf")
+	r.Register(FormatDOCX, NewDOCXParser(), ".docx")
+	r.Register(FormatEPUB, NewEPUBParser(), ".epub")
+	r.Register(FormatHTML, NewHTMLParser(), ".html", ".htm", ".xhtml")
+	r.Register(FormatMarkdown, NewMarkdownParser(), ".md", ".markdown")
+	r.Register(FormatText, NewTextParser(), ".txt", ".text")
+	return r
+}
+
+// Register adds or replaces the parser for a MIME type and the file
+// extensions it goes by
+func (r *Registry) Register(mimeType string, parser Parser, extensions ...string) {
+	r.parsers[mimeType] = parser
+	for _, ext := range extensions {
+		r.extensions[strings.ToLower(ext)] = mimeType
+	}
+}
+
+// Detect returns the MIME type of content, which must be one the registry has
+// a parser for. filename may be empty.
+func (r *Registry) Detect(content []byte, filename string) (string, error) {
+	format := sniff(content)
+	if format == FormatText {
+		// Text formats cannot always be told apart by content
+		switch hinted := r.extensions[strings.ToLower(filepath.Ext(filename))]; {
+		case strings.HasPrefix(hinted, "text/"):
+			format = hinted
+		case looksLikeMarkdown(content):
+			format = FormatMarkdown
+		}
+	}
+
+	if _, ok := r.parsers[format]; !ok {
+		return "", fmt.Errorf("%w: %s", domain.ErrUnsupportedFormat, format)
+	}
+	return format, nil
+}
+
+// Parse reads a file of any registered format
+func (r *Registry) Parse(reader io.ReaderAt, size int64) ([]Segment, error) {
+	content, err := io.ReadAll(io.NewSectionReader(reader, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return r.ParseFile("", content)
+}
+
+// ParseFile reads a file of any registered format, with its name as a hint
+func (r *Registry) ParseFile(filename string, content []byte) ([]Segment, error) {
+	format, err := r.Detect(content, filename)
+	if err != nil {
+		return nil, err
+	}
+	return r.parsers[format].Parse(bytes.NewReader(content), int64(len(content)))
+}
+
+// sniff guesses the MIME type of content. UTF-8 text that is not HTML is
+// reported as plain text.
+func sniff(content []byte) string {
+	// The header may follow some junk, which readers tolerate within the first 1 KiB
+	if bytes.Contains(content[:min(len(content), 1024)], []byte("%PDF-")) {
+		return FormatPDF
+	}
+
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		if format := sniffZip(content); format != "" {
+			return format
+		}
+		return "application/zip"
+	}
+
+	text := bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(text) && !bytes.ContainsRune(text, 0) {
+		if isHTML(text) {
+			return FormatHTML
+		}
+		return FormatText
+	}
+
+	// Kept with its parameters, so text in another encoding than UTF-8
+	// ("text/plain; charset=utf-16le") matches no parser
+	return http.DetectContentType(content)
+}
+
+// sniffZip tells Office and EPUB files, which are zip archives, apart by the
+// parts they contain
+func sniffZip(content []byte) string {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return ""
+	}
+	for _, f := range archive.File {
+		switch f.Name {
+		case "word/document.xml":
+			return FormatDOCX
+		case "META-INF/container.xml":
+			return FormatEPUB
+		}
+	}
+	return ""
+}
+
+// isHTML reports whether text is an HTML page or fragment, including XHTML
+func isHTML(text []byte) bool {
+	if strings.HasPrefix(http.DetectContentType(text), "text/html") {
+		return true
+	}
+	head := bytes.ToLower(text[:min(len(text), 1024)])
+	return bytes.HasPrefix(bytes.TrimSpace(head), []byte("<?xml")) && bytes.Contains(head, []byte("<html"))
+}
+
+// markdownSyntax matches lines only Markdown is likely to have: ATX headings,
+// fenced code and setext heading underlines
+var markdownSyntax = regexp.MustCompile(`(?m)^(?: {0,3}#{1,6}\s+\S| {0,3}(?:` + "```" + `|~~~)| {0,3}(?:=+|-{3,})\s*$)`)
+
+func looksLikeMarkdown(content []byte) bool {
+	return markdownSyntax.Match(content)
+}
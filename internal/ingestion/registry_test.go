@@ -0,0 +1,89 @@
+package ingestion
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"backend/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// buildZip creates a zip archive of name, content pairs, in order
+func buildZip(files ...string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(files); i += 2 {
+		f, _ := w.Create(files[i])
+		f.Write([]byte(files[i+1]))
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestRegistry_DetectsFormatFromContent(t *testing.T) {
+	registry := NewRegistry()
+	docx := buildZip("[Content_Types].xml", "<Types/>", "word/document.xml", "<w:document/>")
+	epub := buildZip("mimetype", "application/epub+zip", "META-INF/container.xml", "<container/>")
+
+	for _, tc := range []struct {
+		content  []byte
+		filename string
+		want     string
+	}{
+		{[]byte("%PDF-1.7\n..."), "book.pdf", FormatPDF},
+		// The name does not override the content
+		{docx, "notes.pdf", FormatDOCX},
+		{epub, "", FormatEPUB},
+		{[]byte("<!DOCTYPE html><html><body><p>Hi</p></body></html>"), "notes.txt", FormatHTML},
+		{[]byte("<?xml version=\"1.0\"?>\n<html xmlns=\"http://www.w3.org/1999/xhtml\"><body/></html>"), "", FormatHTML},
+		{[]byte("# Motion\n\nThings move."), "", FormatMarkdown},
+		{[]byte("Things move."), "notes.md", FormatMarkdown},
+		{[]byte("Things move."), "", FormatText},
+		// A .txt file is plain text even if it looks like Markdown
+		{[]byte("# Motion\n\nThings move."), "notes.txt", FormatText},
+		{[]byte("\xef\xbb\xbfবল হলো ধাক্কা বা টান।"), "", FormatText},
+	} {
+		format, err := registry.Detect(tc.content, tc.filename)
+		assert.NoError(t, err, tc.filename)
+		assert.Equal(t, tc.want, format, string(tc.content))
+	}
+}
+
+func TestRegistry_UnsupportedFormat(t *testing.T) {
+	registry := NewRegistry()
+
+	for _, content := range [][]byte{
+		[]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+		buildZip("data.csv", "a,b"),
+		{0xff, 0xfe, 0x00, 0x01},
+	} {
+		_, err := registry.Detect(content, "notes.docx")
+		assert.ErrorIs(t, err, domain.ErrUnsupportedFormat)
+	}
+}
+
+type MockFormatParser struct {
+	mock.Mock
+}
+
+func (m *MockFormatParser) Parse(r io.ReaderAt, size int64) ([]Segment, error) {
+	args := m.Called(r, size)
+	return args.Get(0).([]Segment), args.Error(1)
+}
+
+func TestRegistry_ParseFileUsesDetectedParser(t *testing.T) {
+	registry := NewRegistry()
+	markdown := new(MockFormatParser)
+	registry.Register(FormatMarkdown, markdown)
+
+	markdown.On("Parse", mock.Anything, int64(12)).Return([]Segment{{Page: 1, Text: "Things move."}}, nil)
+
+	segments, err := registry.ParseFile("notes.md", []byte("Things move."))
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{{Page: 1, Text: "Things move."}}, segments)
+	markdown.AssertExpectations(t)
+}
@@ -1,76 +1,598 @@
 package ingestion
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"backend/internal/domain"
 
 	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 )
 
 // Interfaces for dependencies
 type Parser interface {
-	Parse(r io.ReaderAt, size int64) (string, error)
+	Parse(r io.ReaderAt, size int64) ([]Segment, error)
+}
+
+// FileParser is implemented by parsers that read several formats, such as
+// Registry. They are given the file name as a hint to the format, and are
+// asked for the format of an upload before it is stored, so a file they
+// cannot read is refused with domain.ErrUnsupportedFormat.
+type FileParser interface {
+	Parser
+	Detect(content []byte, filename string) (string, error)
+	ParseFile(filename string, content []byte) ([]Segment, error)
+}
+
+// SegmentChunker splits a document's pages into chunks
+type SegmentChunker interface {
+	ChunkSegments(segments []Segment) []Chunk
 }
 
 type Embedder interface {
 	EmbedContent(ctx context.Context, text string) ([]float32, error)
 }
 
+// BatchEmbedder is implemented by embedders that can embed several texts in
+// one call. Embeddings are returned in the same order as texts.
+type BatchEmbedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// dimensioned is implemented by vector stores that only hold embeddings of
+// one size
+type dimensioned interface {
+	Dimensions(ctx context.Context) (int, error)
+}
+
+// ProgressFunc is told how many of a document's chunks have been indexed so far
+type ProgressFunc func(processed, total int)
+
 // IngestionService coordinates the document ingestion process
 type IngestionService struct {
 	parser   Parser
-	chunker  *Chunker
+	chunker  SegmentChunker
 	embedder Embedder
 	repo     domain.VectorRepository
+	docs     domain.DocumentRepository
+	jobs     domain.JobRepository
+
+	// Normalizer cleans up the parsed text before it is chunked; nil leaves it as parsed
+	Normalizer Normalizer
+	// Chunkers are the strategies a document may ask to be chunked with.
+	// Documents without a strategy use the chunker given to NewIngestionService.
+	Chunkers map[domain.ChunkStrategy]SegmentChunker
+	// EmbedBatchSize is how many chunks are sent to the embedder per call when it implements BatchEmbedder
+	EmbedBatchSize int
+	// EmbedConcurrency is how many embedding calls may be in flight at once
+	EmbedConcurrency int
 }
 
-func NewIngestionService(parser Parser, chunker *Chunker, embedder Embedder, repo domain.VectorRepository) *IngestionService {
+func NewIngestionService(parser Parser, chunker SegmentChunker, embedder Embedder, repo domain.VectorRepository, docs domain.DocumentRepository, jobs domain.JobRepository) *IngestionService {
 	return &IngestionService{
 		parser:   parser,
 		chunker:  chunker,
 		embedder: embedder,
 		repo:     repo,
+		docs:     docs,
+		jobs:     jobs,
+
+		Normalizer:       DefaultNormalizer(),
+		EmbedBatchSize:   16,
+		EmbedConcurrency: 4,
+	}
+}
+
+// Ingest stores the file as a document and indexes its chunks before
+// returning. The caller fills in the descriptive fields of doc (filename,
+// subject, chapter, language, uploader); the service assigns the ID, hash,
+// counts and status. See Enqueue for how re-uploads are handled.
+func (s *IngestionService) Ingest(ctx context.Context, reader io.ReaderAt, size int64, doc *domain.Document) error {
+	if _, err := s.chunkerFor(doc.ChunkStrategy); err != nil {
+		return err
+	}
+
+	content, unchanged, err := s.storeDocument(ctx, reader, size, doc, domain.DocumentStatusProcessing)
+	if err != nil || unchanged {
+		return err
+	}
+
+	return s.index(ctx, doc, content, false, nil)
+}
+
+// Enqueue stores the file as a document and queues an ingestion job for it,
+// returning without waiting for the chunks to be indexed.
+//
+// Uploads are idempotent: if the same file was already ingested for the same
+// subject, chapter and language, nothing is stored, doc is filled in from the
+// existing document and the returned job is nil. A file with the same name but
+// different content replaces the earlier version of that document, and its
+// job only embeds the chunks that changed. Re-uploading an identical file
+// with a different chunk strategy counts as a change.
+func (s *IngestionService) Enqueue(ctx context.Context, reader io.ReaderAt, size int64, doc *domain.Document) (*domain.IngestionJob, error) {
+	if _, err := s.chunkerFor(doc.ChunkStrategy); err != nil {
+		return nil, err
+	}
+
+	_, unchanged, err := s.storeDocument(ctx, reader, size, doc, domain.DocumentStatusQueued)
+	if err != nil || unchanged {
+		return nil, err
+	}
+
+	job, err := s.enqueueJob(ctx, doc.ID, false)
+	if err != nil {
+		// Don't leave a document queued that nothing will ever index
+		doc.Status = domain.DocumentStatusFailed
+		_ = s.docs.UpdateDocument(context.WithoutCancel(ctx), doc)
+		return nil, err
+	}
+	return job, nil
+}
+
+// ProcessJob indexes the job's document. Chunks committed by an earlier,
+// interrupted run of the job are recognised by their hash and not redone.
+func (s *IngestionService) ProcessJob(ctx context.Context, job *domain.IngestionJob, progress ProgressFunc) error {
+	doc, err := s.docs.GetDocument(ctx, job.DocumentID)
+	if err != nil {
+		return fmt.Errorf("loading document failed: %w", err)
+	}
+
+	content, err := s.docs.GetDocumentContent(ctx, job.DocumentID)
+	if err != nil {
+		return fmt.Errorf("loading document file failed: %w", err)
+	}
+
+	doc.Status = domain.DocumentStatusProcessing
+	doc.UpdatedAt = time.Now()
+	if err := s.docs.UpdateDocument(ctx, doc); err != nil {
+		return fmt.Errorf("updating document failed: %w", err)
+	}
+
+	return s.index(ctx, doc, content, job.Rebuild, progress)
+}
+
+// FailJob marks the document of a job that is given up on as failed
+func (s *IngestionService) FailJob(ctx context.Context, job *domain.IngestionJob) error {
+	doc, err := s.docs.GetDocument(ctx, job.DocumentID)
+	if err != nil {
+		return fmt.Errorf("loading document failed: %w", err)
+	}
+
+	doc.Status = domain.DocumentStatusFailed
+	doc.UpdatedAt = time.Now()
+	if err := s.docs.UpdateDocument(ctx, doc); err != nil {
+		return fmt.Errorf("updating document failed: %w", err)
+	}
+	return nil
+}
+
+// CheckDimensions embeds a probe text and checks that the vector store can
+// hold embeddings of its size, so a mismatched model fails at startup rather
+// than on every chunk saved. Stores that take any size always pass.
+func (s *IngestionService) CheckDimensions(ctx context.Context) error {
+	store, ok := s.repo.(dimensioned)
+	if !ok {
+		return nil
+	}
+	want, err := store.Dimensions(ctx)
+	if err != nil {
+		return err
+	}
+	if want <= 0 {
+		return nil
+	}
+
+	embedding, err := s.embedder.EmbedContent(ctx, "dimension check")
+	if err != nil {
+		return fmt.Errorf("embedding probe failed: %w", err)
+	}
+	if len(embedding) != want {
+		return fmt.Errorf("%w: the model returns %d, the store holds %d", domain.ErrDimensionMismatch, len(embedding), want)
+	}
+	return nil
+}
+
+func (s *IngestionService) GetJob(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error) {
+	return s.jobs.GetJob(ctx, id)
+}
+
+func (s *IngestionService) ListDocuments(ctx context.Context, filter domain.DocumentFilter) ([]*domain.Document, error) {
+	return s.docs.ListDocuments(ctx, filter)
+}
+
+func (s *IngestionService) GetDocument(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
+	return s.docs.GetDocument(ctx, id)
+}
+
+// DeleteDocument removes a document together with its stored file and chunks.
+// The chunks are also deleted through the vector repository, for stores that
+// do not live alongside the documents.
+func (s *IngestionService) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	if err := s.docs.DeleteDocument(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteChunksByDocument(ctx, id)
+}
+
+// ReindexDocument queues a job that rebuilds a document's chunks from the
+// stored file, e.g. after the chunker or embedding model changed. Unlike a
+// re-upload, every chunk is embedded again. The current chunks stay
+// searchable until the rebuilt ones replace them, and are kept if the job fails.
+func (s *IngestionService) ReindexDocument(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error) {
+	doc, err := s.docs.GetDocument(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkIdle(ctx, id); err != nil {
+		return nil, err
+	}
+
+	doc.Status = domain.DocumentStatusQueued
+	doc.UpdatedAt = time.Now()
+	if err := s.docs.UpdateDocument(ctx, doc); err != nil {
+		return nil, fmt.Errorf("updating document failed: %w", err)
+	}
+
+	return s.enqueueJob(ctx, id, true)
+}
+
+// storeDocument reads the whole file and stores it, either as a new document
+// or as a new version of an existing one with the same filename, subject,
+// chapter and language. It reports unchanged (and stores nothing) when an
+// identical file has already been ingested or is being ingested.
+func (s *IngestionService) storeDocument(ctx context.Context, reader io.ReaderAt, size int64, doc *domain.Document, status domain.DocumentStatus) ([]byte, bool, error) {
+	content, err := io.ReadAll(io.NewSectionReader(reader, 0, size))
+	if err != nil {
+		return nil, false, fmt.Errorf("reading file failed: %w", err)
+	}
+	if fp, ok := s.parser.(FileParser); ok {
+		if _, err := fp.Detect(content, doc.Filename); err != nil {
+			return nil, false, err
+		}
+	}
+	hash := hashBytes(content)
+	now := time.Now()
+
+	identical, err := s.findDocument(ctx, domain.DocumentFilter{
+		Subject:     doc.Subject,
+		Chapter:     doc.Chapter,
+		Language:    doc.Language,
+		ContentHash: hash,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if identical != nil && identical.Status != domain.DocumentStatusFailed && identical.ChunkStrategy == doc.ChunkStrategy {
+		*doc = *identical
+		return content, true, nil
+	}
+
+	previous := identical
+	if previous == nil {
+		previous, err = s.findDocument(ctx, domain.DocumentFilter{
+			Subject:  doc.Subject,
+			Chapter:  doc.Chapter,
+			Language: doc.Language,
+			Filename: doc.Filename,
+		})
+		if err != nil {
+			return nil, false, err
+		}
 	}
+
+	if previous != nil {
+		// A new version would race the job still indexing the current one
+		if err := s.checkIdle(ctx, previous.ID); err != nil {
+			return nil, false, err
+		}
+
+		userID, strategy := doc.UserID, doc.ChunkStrategy
+		*doc = *previous
+		if userID != "" {
+			doc.UserID = userID
+		}
+		doc.ChunkStrategy = strategy
+		doc.ContentHash = hash
+		doc.Status = status
+		doc.UpdatedAt = now
+
+		if err := s.docs.ReplaceDocumentFile(ctx, doc, content); err != nil {
+			return nil, false, fmt.Errorf("saving document failed: %w", err)
+		}
+		return content, false, nil
+	}
+
+	doc.ID = uuid.New()
+	doc.ContentHash = hash
+	doc.Status = status
+	doc.PageCount = 0
+	doc.ChunkCount = 0
+	doc.CreatedAt = now
+	doc.UpdatedAt = now
+
+	if err := s.docs.CreateDocument(ctx, doc, content); err != nil {
+		return nil, false, fmt.Errorf("saving document failed: %w", err)
+	}
+	return content, false, nil
+}
+
+func (s *IngestionService) parse(filename string, content []byte) ([]Segment, error) {
+	if fp, ok := s.parser.(FileParser); ok {
+		return fp.ParseFile(filename, content)
+	}
+	return s.parser.Parse(bytes.NewReader(content), int64(len(content)))
 }
 
-func (s *IngestionService) Ingest(ctx context.Context, reader io.ReaderAt, size int64, subject string, chapter int, language string) error {
-	// 1. Parse PDF
-	text, err := s.parser.Parse(reader, size)
+// chunkerFor returns the chunker for a document's strategy
+func (s *IngestionService) chunkerFor(strategy domain.ChunkStrategy) (SegmentChunker, error) {
+	if strategy == "" {
+		return s.chunker, nil
+	}
+	chunker, ok := s.Chunkers[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown chunk strategy %q", strategy)
+	}
+	return chunker, nil
+}
+
+// checkIdle returns ErrDocumentBusy if a job for the document is queued or running
+func (s *IngestionService) checkIdle(ctx context.Context, documentID uuid.UUID) error {
+	_, err := s.jobs.ActiveJob(ctx, documentID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("looking up jobs failed: %w", err)
+	}
+	return domain.ErrDocumentBusy
+}
+
+// findDocument returns the most recent document matching filter, or nil
+func (s *IngestionService) findDocument(ctx context.Context, filter domain.DocumentFilter) (*domain.Document, error) {
+	filter.Limit = 1
+	docs, err := s.docs.ListDocuments(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("looking up document failed: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	return docs[0], nil
+}
+
+func (s *IngestionService) enqueueJob(ctx context.Context, documentID uuid.UUID, rebuild bool) (*domain.IngestionJob, error) {
+	now := time.Now()
+	job := &domain.IngestionJob{
+		ID:         uuid.New(),
+		DocumentID: documentID,
+		Status:     domain.JobStatusQueued,
+		Rebuild:    rebuild,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := s.jobs.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("queueing job failed: %w", err)
+	}
+	return job, nil
+}
+
+// index brings the document's stored chunks in line with its content, then
+// records the outcome on the document. A failure marks the document as
+// failed; chunks committed before the failure are kept so a retry can resume.
+func (s *IngestionService) index(ctx context.Context, doc *domain.Document, content []byte, rebuild bool, progress ProgressFunc) error {
+	err := s.indexChunks(ctx, doc, content, rebuild, progress)
+	if ctx.Err() != nil {
+		// Interrupted (e.g. server shutdown) rather than failed; the job will be resumed
+		return err
+	}
+
+	doc.Status = domain.DocumentStatusReady
+	if err != nil {
+		doc.Status = domain.DocumentStatusFailed
+	}
+	doc.UpdatedAt = time.Now()
+
+	if updateErr := s.docs.UpdateDocument(ctx, doc); updateErr != nil && err == nil {
+		err = fmt.Errorf("updating document failed: %w", updateErr)
+	}
+	return err
+}
+
+// indexChunks only embeds chunks whose hash is not already stored for the
+// document. Duplicate chunks within the document are stored once. Chunks that
+// are no longer part of the document are removed, and the position, pages and
+// section of those that are kept are updated, in the same transaction that
+// commits the final batch of new ones. A rebuild embeds every chunk and holds
+// them all back for that transaction, which swaps them for the stored ones.
+func (s *IngestionService) indexChunks(ctx context.Context, doc *domain.Document, content []byte, rebuild bool, progress ProgressFunc) error {
+	if progress == nil {
+		progress = func(int, int) {}
+	}
+
+	// 1. Parse the file
+	segments, err := s.parse(doc.Filename, content)
 	if err != nil {
 		return fmt.Errorf("parsing failed: %w", err)
 	}
+	if len(segments) > 0 {
+		doc.PageCount = segments[len(segments)-1].Page
+	}
+	if s.Normalizer != nil {
+		segments = s.Normalizer.Normalize(segments)
+	}
+	if !hasStructure(segments) {
+		// No usable outline: fall back to the headings in the text
+		segments = detectStructure(segments, doc.Chapter)
+	}
 
-	// 2. Chunk text
-	chunks := s.chunker.Chunk(text)
+	// 2. Chunk text section by section, keeping track of the pages each chunk spans
+	chunker, err := s.chunkerFor(doc.ChunkStrategy)
+	if err != nil {
+		return err
+	}
+	chunks := chunkSections(chunker, segments)
 
-	// 3. Process each chunk
-	for i, content := range chunks {
-		// Embed
-		embedding, err := s.embedder.EmbedContent(ctx, content)
+	// 3. Work out which chunks are new
+	stored := make(map[string]bool)
+	if !rebuild {
+		existing, err := s.repo.ChunkHashes(ctx, doc.ID)
 		if err != nil {
-			return fmt.Errorf("embedding failed for chunk %d: %w", i, err)
+			return err
+		}
+		for _, h := range existing {
+			stored[h] = true
 		}
+	}
+
+	var keep, pending []*domain.DocumentChunk
+	seen := make(map[string]bool, len(chunks))
+	for i, c := range chunks {
+		hash := hashChunk(c.Content)
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
 
-		// Save to DB
+		// A chapter given at upload wins over the detected one
+		chapter := doc.Chapter
+		if chapter == 0 {
+			chapter = c.Chapter
+		}
 		chunk := &domain.DocumentChunk{
-			ID:        uuid.New(),
-			Subject:   subject,
-			Chapter:   chapter,
-			Content:   content,
-			Embedding: embedding,
-			Language:  language,
-			Page:      0, // Parser simplified, real one might map pages
-			CreatedAt: time.Now(),
+			ID:          uuid.New(),
+			DocumentID:  doc.ID,
+			ChunkIndex:  i,
+			ContentHash: hash,
+			Subject:     doc.Subject,
+			Chapter:     chapter,
+			Section:     c.Section,
+			Content:     c.Content,
+			Language:    doc.Language,
+			Page:        c.PageStart,
+			PageEnd:     c.PageEnd,
+			CreatedAt:   time.Now(),
+		}
+		// A stored chunk is kept, but may have moved
+		keep = append(keep, chunk)
+		if !stored[hash] {
+			pending = append(pending, chunk)
+		}
+	}
+
+	total := len(keep)
+	done := total - len(pending)
+	if !rebuild {
+		doc.ChunkCount = done
+	}
+	progress(done, total)
+
+	// 4. Embed and commit the new chunks batch by batch. Committed chunks survive
+	// an interrupted job, so a retry only embeds the rest. A rebuild leaves the
+	// stored chunks in place until all of its chunks are embedded.
+	commitBatch := s.batchSize() * s.concurrency()
+	saved := 0
+	for start := 0; ; start += commitBatch {
+		end := min(start+commitBatch, len(pending))
+		batch := pending[start:end]
+
+		if err := s.embedChunks(ctx, batch); err != nil {
+			return err
+		}
+
+		if end == len(pending) {
+			if err := s.repo.ReplaceChunks(ctx, doc.ID, pending[saved:], keep); err != nil {
+				return fmt.Errorf("saving chunks failed: %w", err)
+			}
+			doc.ChunkCount = total
+			progress(total, total)
+			return nil
 		}
 
-		if err := s.repo.SaveChunk(ctx, chunk); err != nil {
-			return fmt.Errorf("saving chunk %d failed: %w", i, err)
+		if !rebuild {
+			if err := s.repo.SaveChunks(ctx, batch); err != nil {
+				return fmt.Errorf("saving chunks failed: %w", err)
+			}
+			saved = end
+			doc.ChunkCount += len(batch)
 		}
+		done += len(batch)
+		progress(done, total)
 	}
+}
 
-	return nil
+// embedChunks fills in the embedding of every chunk. Chunks are split into
+// groups of EmbedBatchSize that are embedded by up to EmbedConcurrency
+// workers; each result is written back to its own chunk, so the order of the
+// chunks does not depend on which call finishes first. Embedders without batch
+// support get one EmbedContent call per chunk.
+func (s *IngestionService) embedChunks(ctx context.Context, chunks []*domain.DocumentChunk) error {
+	batcher, canBatch := s.embedder.(BatchEmbedder)
+	size := 1
+	if canBatch {
+		size = s.batchSize()
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.concurrency())
+
+	for start := 0; start < len(chunks); start += size {
+		group := chunks[start:min(start+size, len(chunks))]
+
+		g.Go(func() error {
+			if !canBatch {
+				embedding, err := s.embedder.EmbedContent(gctx, group[0].Content)
+				if err != nil {
+					return fmt.Errorf("embedding failed for chunk %d: %w", group[0].ChunkIndex, err)
+				}
+				group[0].Embedding = embedding
+				return nil
+			}
+
+			texts := make([]string, len(group))
+			for i, chunk := range group {
+				texts[i] = chunk.Content
+			}
+			embeddings, err := batcher.EmbedBatch(gctx, texts)
+			if err != nil {
+				return fmt.Errorf("embedding failed for chunks %d-%d: %w", group[0].ChunkIndex, group[len(group)-1].ChunkIndex, err)
+			}
+			if len(embeddings) != len(group) {
+				return fmt.Errorf("embedding failed for chunks %d-%d: expected %d embeddings, got %d", group[0].ChunkIndex, group[len(group)-1].ChunkIndex, len(group), len(embeddings))
+			}
+			for i, chunk := range group {
+				chunk.Embedding = embeddings[i]
+			}
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+func (s *IngestionService) batchSize() int {
+	return max(s.EmbedBatchSize, 1)
+}
+
+func (s *IngestionService) concurrency() int {
+	return max(s.EmbedConcurrency, 1)
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashChunk hashes chunk text with whitespace normalized, so re-extracting the
+// same page with slightly different spacing does not count as a change
+func hashChunk(text string) string {
+	return hashBytes([]byte(strings.Join(strings.Fields(text), " ")))
 }
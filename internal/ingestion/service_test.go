@@ -1,12 +1,19 @@
 package ingestion
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"backend/internal/domain"
+	"backend/internal/repository"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -16,9 +23,12 @@ type MockParser struct {
 	mock.Mock
 }
 
-func (m *MockParser) Parse(r io.ReaderAt, size int64) (string, error) {
+func (m *MockParser) Parse(r io.ReaderAt, size int64) ([]Segment, error) {
 	args := m.Called(r, size)
-	return args.String(0), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Segment), args.Error(1)
 }
 
 type MockRepo struct {
@@ -30,11 +40,135 @@ func (m *MockRepo) SaveChunk(ctx context.Context, chunk *domain.DocumentChunk) e
 	return args.Error(0)
 }
 
-func (m *MockRepo) SearchSimilar(ctx context.Context, embedding []float32, limit int, filter map[string]interface{}) ([]*domain.DocumentChunk, error) {
-	args := m.Called(ctx, embedding, limit, filter)
+func (m *MockRepo) SearchSimilar(ctx context.Context, embedding []float32, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	args := m.Called(ctx, embedding, q)
 	return args.Get(0).([]*domain.DocumentChunk), args.Error(1)
 }
 
+func (m *MockRepo) SearchKeyword(ctx context.Context, text string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	args := m.Called(ctx, text, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DocumentChunk), args.Error(1)
+}
+
+func (m *MockRepo) SaveChunks(ctx context.Context, chunks []*domain.DocumentChunk) error {
+	args := m.Called(ctx, chunks)
+	return args.Error(0)
+}
+
+func (m *MockRepo) DeleteChunksByDocument(ctx context.Context, documentID uuid.UUID) error {
+	args := m.Called(ctx, documentID)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ChunkHashes(ctx context.Context, documentID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, documentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepo) ReplaceChunks(ctx context.Context, documentID uuid.UUID, add []*domain.DocumentChunk, keep []*domain.DocumentChunk) error {
+	args := m.Called(ctx, documentID, add, keep)
+	return args.Error(0)
+}
+
+type MockDocumentRepo struct {
+	mock.Mock
+}
+
+func (m *MockDocumentRepo) CreateDocument(ctx context.Context, doc *domain.Document, content []byte) error {
+	args := m.Called(ctx, doc, content)
+	return args.Error(0)
+}
+
+func (m *MockDocumentRepo) GetDocument(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Document), args.Error(1)
+}
+
+func (m *MockDocumentRepo) GetDocumentContent(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockDocumentRepo) ListDocuments(ctx context.Context, filter domain.DocumentFilter) ([]*domain.Document, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*domain.Document), args.Error(1)
+}
+
+func (m *MockDocumentRepo) UpdateDocument(ctx context.Context, doc *domain.Document) error {
+	args := m.Called(ctx, doc)
+	return args.Error(0)
+}
+
+func (m *MockDocumentRepo) ReplaceDocumentFile(ctx context.Context, doc *domain.Document, content []byte) error {
+	args := m.Called(ctx, doc, content)
+	return args.Error(0)
+}
+
+func (m *MockDocumentRepo) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+type MockJobRepo struct {
+	mock.Mock
+}
+
+func (m *MockJobRepo) CreateJob(ctx context.Context, job *domain.IngestionJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepo) GetJob(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
+func (m *MockJobRepo) ActiveJob(ctx context.Context, documentID uuid.UUID) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, documentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
+func (m *MockJobRepo) ClaimJob(ctx context.Context, lease time.Duration) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
+func (m *MockJobRepo) UpdateJobProgress(ctx context.Context, id uuid.UUID, processed, total int, lease time.Duration) error {
+	args := m.Called(ctx, id, processed, total, lease)
+	return args.Error(0)
+}
+
+func (m *MockJobRepo) RenewJobLease(ctx context.Context, id uuid.UUID, lease time.Duration) error {
+	args := m.Called(ctx, id, lease)
+	return args.Error(0)
+}
+
+func (m *MockJobRepo) FinishJob(ctx context.Context, job *domain.IngestionJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
 type MockEmbedder struct {
 	mock.Mock
 }
@@ -44,28 +178,681 @@ func (m *MockEmbedder) EmbedContent(ctx context.Context, text string) ([]float32
 	return args.Get(0).([]float32), args.Error(1)
 }
 
+// byHash and byFilename match the two lookups storeDocument makes for an upload
+func byHash(f domain.DocumentFilter) bool     { return f.ContentHash != "" }
+func byFilename(f domain.DocumentFilter) bool { return f.Filename != "" }
+
+// MockBatchEmbedder adds batch support to MockEmbedder
+type MockBatchEmbedder struct {
+	MockEmbedder
+}
+
+func (m *MockBatchEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	args := m.Called(ctx, texts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([][]float32), args.Error(1)
+}
+
 func TestIngestDocument(t *testing.T) {
 	mockParser := new(MockParser)
 	mockRepo := new(MockRepo)
+	mockDocs := new(MockDocumentRepo)
 	mockEmbedder := new(MockEmbedder)
 	chunker := NewChunker(100, 10)
 
-	service := NewIngestionService(mockParser, chunker, mockEmbedder, mockRepo)
+	service := NewIngestionService(mockParser, chunker, mockEmbedder, mockRepo, mockDocs, new(MockJobRepo))
 
 	ctx := context.Background()
-	content := "Physics Content"
-	mockParser.On("Parse", mock.Anything, int64(10)).Return(content, nil)
-	mockEmbedder.On("EmbedContent", ctx, content).Return([]float32{0.1, 0.2}, nil)
+	file := []byte("fake pdf 1")
+	segments := []Segment{{Page: 7, Text: "Physics Content"}}
+	mockParser.On("Parse", mock.Anything, int64(10)).Return(segments, nil)
+	mockEmbedder.On("EmbedContent", mock.Anything, "Physics Content\n").Return([]float32{0.1, 0.2}, nil)
 	// Note: Chunker splits "Physics Content" (15 chars) into 1 chunk if max is 100.
 
-	mockRepo.On("SaveChunk", ctx, mock.MatchedBy(func(c *domain.DocumentChunk) bool {
-		return c.Content == "Physics Content" && len(c.Embedding) == 2
+	mockDocs.On("ListDocuments", ctx, mock.Anything).Return([]*domain.Document{}, nil)
+	mockDocs.On("CreateDocument", ctx, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.ID != uuid.Nil && d.ContentHash != "" && d.Status == domain.DocumentStatusProcessing
+	}), file).Return(nil)
+	mockRepo.On("ChunkHashes", ctx, mock.Anything).Return([]string{}, nil)
+	mockRepo.On("ReplaceChunks", ctx, mock.Anything, mock.MatchedBy(func(add []*domain.DocumentChunk) bool {
+		c := add[0]
+		return len(add) == 1 && c.Content == "Physics Content\n" && len(c.Embedding) == 2 && c.Page == 7 && c.PageEnd == 7 && c.DocumentID != uuid.Nil
+	}), mock.MatchedBy(func(keep []*domain.DocumentChunk) bool {
+		return len(keep) == 1 && keep[0].ContentHash == hashChunk("Physics Content")
+	})).Return(nil)
+	mockDocs.On("UpdateDocument", mock.Anything, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.Status == domain.DocumentStatusReady
 	})).Return(nil)
 
-	err := service.Ingest(ctx, nil, 10, "Physics", 1, "en")
+	doc := &domain.Document{Filename: "ch1.pdf", Subject: "Physics", Chapter: 1, Language: "en", UserID: "user-1"}
+	err := service.Ingest(ctx, bytes.NewReader(file), int64(len(file)), doc)
 	assert.NoError(t, err)
+	assert.Equal(t, 1, doc.ChunkCount)
+	assert.Equal(t, 7, doc.PageCount)
+	assert.Equal(t, "user-1", doc.UserID)
 
 	mockParser.AssertExpectations(t)
 	mockRepo.AssertExpectations(t)
+	mockDocs.AssertExpectations(t)
+	mockEmbedder.AssertExpectations(t)
+}
+
+func TestIngestDocument_UsesDocumentChunkStrategy(t *testing.T) {
+	mockParser := new(MockParser)
+	mockRepo := new(MockRepo)
+	mockDocs := new(MockDocumentRepo)
+	mockEmbedder := new(MockEmbedder)
+
+	service := NewIngestionService(mockParser, NewChunker(100, 10), mockEmbedder, mockRepo, mockDocs, new(MockJobRepo))
+	service.Chunkers = NewChunkers(100, 10)
+
+	ctx := context.Background()
+	file := []byte("fake pdf")
+	mockParser.On("Parse", mock.Anything, mock.Anything).Return([]Segment{{Page: 1, Text: "Chapter 1\nPhysics Content"}}, nil)
+	// The fixed chunker would keep the page's trailing newline
+	mockEmbedder.On("EmbedContent", mock.Anything, "Chapter 1\nPhysics Content").Return([]float32{0.1, 0.2}, nil)
+	mockDocs.On("ListDocuments", ctx, mock.Anything).Return([]*domain.Document{}, nil)
+	mockDocs.On("CreateDocument", ctx, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.ChunkStrategy == domain.ChunkStrategyStructured
+	}), file).Return(nil)
+	mockRepo.On("ChunkHashes", ctx, mock.Anything).Return([]string{}, nil)
+	mockRepo.On("ReplaceChunks", ctx, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockDocs.On("UpdateDocument", mock.Anything, mock.Anything).Return(nil)
+
+	doc := &domain.Document{Subject: "Physics", Chapter: 1, Language: "en", ChunkStrategy: domain.ChunkStrategyStructured}
+	err := service.Ingest(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.NoError(t, err)
+
+	mockEmbedder.AssertExpectations(t)
+	mockDocs.AssertExpectations(t)
+}
+
+func TestIngestDocument_TagsChunksWithDetectedChapters(t *testing.T) {
+	mockParser := new(MockParser)
+	mockRepo := new(MockRepo)
+	mockDocs := new(MockDocumentRepo)
+	mockEmbedder := new(MockEmbedder)
+
+	service := NewIngestionService(mockParser, NewStructuredChunker(100, 0), mockEmbedder, mockRepo, mockDocs, new(MockJobRepo))
+
+	ctx := context.Background()
+	file := []byte("fake pdf")
+	mockParser.On("Parse", mock.Anything, mock.Anything).Return([]Segment{
+		{Page: 1, Text: "Chapter 1: Motion\nThings move."},
+		{Page: 2, Text: "1.1 Speed\nSpeed is distance over time.\nChapter 2: Force\nForce is a push."},
+	}, nil)
+	mockEmbedder.On("EmbedContent", mock.Anything, mock.Anything).Return([]float32{0.1, 0.2}, nil)
+	mockDocs.On("ListDocuments", ctx, mock.Anything).Return([]*domain.Document{}, nil)
+	mockDocs.On("CreateDocument", ctx, mock.Anything, file).Return(nil)
+	mockRepo.On("ChunkHashes", ctx, mock.Anything).Return([]string{}, nil)
+	mockRepo.On("ReplaceChunks", ctx, mock.Anything, mock.MatchedBy(func(add []*domain.DocumentChunk) bool {
+		if len(add) != 3 {
+			return false
+		}
+		return add[0].Chapter == 1 && add[0].Section == "" &&
+			add[1].Chapter == 1 && add[1].Section == "1.1 Speed" && add[1].Page == 2 &&
+			add[2].Chapter == 2 && add[2].Section == "" && add[2].Content == "Chapter 2: Force\nForce is a push."
+	}), mock.Anything).Return(nil)
+	mockDocs.On("UpdateDocument", mock.Anything, mock.Anything).Return(nil)
+
+	// No chapter given: the whole book is uploaded at once
+	doc := &domain.Document{Subject: "Physics", Language: "en"}
+	err := service.Ingest(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIngestDocument_UnknownChunkStrategy(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, new(MockJobRepo))
+
+	doc := &domain.Document{Subject: "Physics", Chapter: 1, Language: "en", ChunkStrategy: "semantic"}
+	err := service.Ingest(context.Background(), bytes.NewReader(nil), 0, doc)
+	assert.ErrorContains(t, err, `unknown chunk strategy "semantic"`)
+
+	mockDocs.AssertNotCalled(t, "ListDocuments", mock.Anything, mock.Anything)
+}
+
+func TestEnqueueDocument_UnsupportedFormat(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	service := NewIngestionService(NewRegistry(), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, new(MockJobRepo))
+
+	file := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	doc := &domain.Document{Filename: "scan.pdf", Subject: "Physics", Language: "en"}
+	job, err := service.Enqueue(context.Background(), bytes.NewReader(file), int64(len(file)), doc)
+
+	assert.ErrorIs(t, err, domain.ErrUnsupportedFormat)
+	assert.Nil(t, job)
+	// Refused before anything is stored
+	mockDocs.AssertNotCalled(t, "ListDocuments", mock.Anything, mock.Anything)
+	mockDocs.AssertNotCalled(t, "CreateDocument", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIngestDocument_ParsesByDetectedFormat(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockDocs := new(MockDocumentRepo)
+	mockEmbedder := new(MockEmbedder)
+	service := NewIngestionService(NewRegistry(), NewStructuredChunker(100, 0), mockEmbedder, mockRepo, mockDocs, new(MockJobRepo))
+
+	ctx := context.Background()
+	file := []byte("# Chapter 3: Heat\n\nHeat flows from hot to cold.")
+	mockEmbedder.On("EmbedContent", mock.Anything, "Chapter 3: Heat\nHeat flows from hot to cold.").Return([]float32{0.1, 0.2}, nil)
+	mockDocs.On("ListDocuments", ctx, mock.Anything).Return([]*domain.Document{}, nil)
+	mockDocs.On("CreateDocument", ctx, mock.Anything, file).Return(nil)
+	mockRepo.On("ChunkHashes", ctx, mock.Anything).Return([]string{}, nil)
+	mockRepo.On("ReplaceChunks", ctx, mock.Anything, mock.MatchedBy(func(add []*domain.DocumentChunk) bool {
+		return len(add) == 1 && add[0].Chapter == 3 && add[0].Page == 1
+	}), mock.Anything).Return(nil)
+	mockDocs.On("UpdateDocument", mock.Anything, mock.Anything).Return(nil)
+
+	doc := &domain.Document{Filename: "heat.md", Subject: "Physics", Language: "en"}
+	err := service.Ingest(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, doc.PageCount)
+
+	mockRepo.AssertExpectations(t)
+	mockEmbedder.AssertExpectations(t)
+}
+
+func TestIngestDocument_EmbeddingFailureMarksDocumentFailed(t *testing.T) {
+	mockParser := new(MockParser)
+	mockRepo := new(MockRepo)
+	mockDocs := new(MockDocumentRepo)
+	mockEmbedder := new(MockEmbedder)
+
+	service := NewIngestionService(mockParser, NewChunker(100, 10), mockEmbedder, mockRepo, mockDocs, new(MockJobRepo))
+
+	ctx := context.Background()
+	file := []byte("fake pdf")
+	mockParser.On("Parse", mock.Anything, mock.Anything).Return([]Segment{{Page: 1, Text: "Content"}}, nil)
+	mockEmbedder.On("EmbedContent", mock.Anything, mock.Anything).Return([]float32(nil), errors.New("quota exceeded"))
+	mockDocs.On("ListDocuments", ctx, mock.Anything).Return([]*domain.Document{}, nil)
+	mockDocs.On("CreateDocument", ctx, mock.Anything, file).Return(nil)
+	mockRepo.On("ChunkHashes", ctx, mock.Anything).Return([]string{}, nil)
+	mockDocs.On("UpdateDocument", mock.Anything, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.Status == domain.DocumentStatusFailed
+	})).Return(nil)
+
+	doc := &domain.Document{Subject: "Physics", Chapter: 1, Language: "en"}
+	err := service.Ingest(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.ErrorContains(t, err, "quota exceeded")
+	assert.Equal(t, domain.DocumentStatusFailed, doc.Status)
+
+	mockRepo.AssertNotCalled(t, "ReplaceChunks", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockDocs.AssertExpectations(t)
+}
+
+func TestEnqueueDocument(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	mockJobs := new(MockJobRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, mockJobs)
+
+	ctx := context.Background()
+	file := []byte("fake pdf")
+
+	mockDocs.On("ListDocuments", ctx, mock.MatchedBy(byHash)).Return([]*domain.Document{}, nil)
+	mockDocs.On("ListDocuments", ctx, mock.MatchedBy(byFilename)).Return([]*domain.Document{}, nil)
+	mockDocs.On("CreateDocument", ctx, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.Status == domain.DocumentStatusQueued
+	}), file).Return(nil)
+	mockJobs.On("CreateJob", ctx, mock.MatchedBy(func(j *domain.IngestionJob) bool {
+		return j.Status == domain.JobStatusQueued && j.DocumentID != uuid.Nil
+	})).Return(nil)
+
+	doc := &domain.Document{Filename: "ch1.pdf", Subject: "Physics", Chapter: 1, Language: "en"}
+	job, err := service.Enqueue(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.ID, job.DocumentID)
+
+	mockDocs.AssertExpectations(t)
+	mockJobs.AssertExpectations(t)
+}
+
+func TestEnqueueDocument_IdenticalFileIsNoop(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	mockJobs := new(MockJobRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, mockJobs)
+
+	ctx := context.Background()
+	file := []byte("fake pdf")
+	existing := &domain.Document{ID: uuid.New(), Filename: "ch1.pdf", ContentHash: hashBytes(file), Subject: "Physics", Chapter: 1, Language: "en", ChunkCount: 40, Status: domain.DocumentStatusReady}
+
+	mockDocs.On("ListDocuments", ctx, domain.DocumentFilter{Subject: "Physics", Chapter: 1, Language: "en", ContentHash: hashBytes(file), Limit: 1}).
+		Return([]*domain.Document{existing}, nil)
+
+	doc := &domain.Document{Filename: "renamed.pdf", Subject: "Physics", Chapter: 1, Language: "en"}
+	job, err := service.Enqueue(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.NoError(t, err)
+	assert.Nil(t, job)
+	assert.Equal(t, existing.ID, doc.ID)
+	assert.Equal(t, 40, doc.ChunkCount)
+
+	mockDocs.AssertNotCalled(t, "CreateDocument", mock.Anything, mock.Anything, mock.Anything)
+	mockJobs.AssertNotCalled(t, "CreateJob", mock.Anything, mock.Anything)
+}
+
+func TestEnqueueDocument_NewChunkStrategyRechunksIdenticalFile(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	mockJobs := new(MockJobRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, mockJobs)
+	service.Chunkers = NewChunkers(100, 10)
+
+	ctx := context.Background()
+	file := []byte("fake pdf")
+	existing := &domain.Document{ID: uuid.New(), Filename: "ch1.pdf", ContentHash: hashBytes(file), Subject: "Physics", Chapter: 1, Language: "en", Status: domain.DocumentStatusReady}
+
+	mockDocs.On("ListDocuments", ctx, mock.MatchedBy(byHash)).Return([]*domain.Document{existing}, nil)
+	mockJobs.On("ActiveJob", ctx, existing.ID).Return(nil, domain.ErrNotFound)
+	mockDocs.On("ReplaceDocumentFile", ctx, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.ID == existing.ID && d.ChunkStrategy == domain.ChunkStrategyFixed && d.Status == domain.DocumentStatusQueued
+	}), file).Return(nil)
+	mockJobs.On("CreateJob", ctx, mock.Anything).Return(nil)
+
+	doc := &domain.Document{Filename: "ch1.pdf", Subject: "Physics", Chapter: 1, Language: "en", ChunkStrategy: domain.ChunkStrategyFixed}
+	job, err := service.Enqueue(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+
+	mockDocs.AssertExpectations(t)
+	mockJobs.AssertExpectations(t)
+}
+
+func TestEnqueueDocument_ChangedFileReplacesPreviousVersion(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	mockJobs := new(MockJobRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, mockJobs)
+
+	ctx := context.Background()
+	file := []byte("fake pdf, second edition")
+	created := time.Now().Add(-time.Hour)
+	previous := &domain.Document{ID: uuid.New(), Filename: "ch1.pdf", ContentHash: "old", Subject: "Physics", Chapter: 1, Language: "en", Status: domain.DocumentStatusReady, CreatedAt: created}
+
+	mockDocs.On("ListDocuments", ctx, mock.MatchedBy(byHash)).Return([]*domain.Document{}, nil)
+	mockDocs.On("ListDocuments", ctx, mock.MatchedBy(byFilename)).Return([]*domain.Document{previous}, nil)
+	mockJobs.On("ActiveJob", ctx, previous.ID).Return(nil, domain.ErrNotFound)
+	mockDocs.On("ReplaceDocumentFile", ctx, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.ID == previous.ID && d.ContentHash == hashBytes(file) && d.Status == domain.DocumentStatusQueued
+	}), file).Return(nil)
+	mockJobs.On("CreateJob", ctx, mock.MatchedBy(func(j *domain.IngestionJob) bool {
+		return j.DocumentID == previous.ID
+	})).Return(nil)
+
+	doc := &domain.Document{Filename: "ch1.pdf", Subject: "Physics", Chapter: 1, Language: "en", UserID: "user-2"}
+	job, err := service.Enqueue(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.NoError(t, err)
+	assert.NotNil(t, job)
+	assert.Equal(t, previous.ID, doc.ID)
+	assert.Equal(t, created, doc.CreatedAt)
+	assert.Equal(t, "user-2", doc.UserID)
+
+	mockDocs.AssertExpectations(t)
+	mockDocs.AssertNotCalled(t, "CreateDocument", mock.Anything, mock.Anything, mock.Anything)
+	mockJobs.AssertExpectations(t)
+}
+
+func TestEnqueueDocument_RejectsNewVersionWhileIngesting(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	mockJobs := new(MockJobRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, mockJobs)
+
+	ctx := context.Background()
+	file := []byte("fake pdf, second edition")
+	previous := &domain.Document{ID: uuid.New(), Filename: "ch1.pdf", ContentHash: "old", Subject: "Physics", Chapter: 1, Language: "en", Status: domain.DocumentStatusProcessing}
+
+	mockDocs.On("ListDocuments", ctx, mock.MatchedBy(byHash)).Return([]*domain.Document{}, nil)
+	mockDocs.On("ListDocuments", ctx, mock.MatchedBy(byFilename)).Return([]*domain.Document{previous}, nil)
+	mockJobs.On("ActiveJob", ctx, previous.ID).Return(&domain.IngestionJob{ID: uuid.New(), DocumentID: previous.ID, Status: domain.JobStatusRunning}, nil)
+
+	doc := &domain.Document{Filename: "ch1.pdf", Subject: "Physics", Chapter: 1, Language: "en"}
+	job, err := service.Enqueue(ctx, bytes.NewReader(file), int64(len(file)), doc)
+	assert.ErrorIs(t, err, domain.ErrDocumentBusy)
+	assert.Nil(t, job)
+
+	mockDocs.AssertNotCalled(t, "ReplaceDocumentFile", mock.Anything, mock.Anything, mock.Anything)
+	mockJobs.AssertNotCalled(t, "CreateJob", mock.Anything, mock.Anything)
+}
+
+// sizedRepo is a vector store that only holds embeddings of size dims
+type sizedRepo struct {
+	*MockRepo
+	dims int
+}
+
+func (r sizedRepo) Dimensions(ctx context.Context) (int, error) {
+	return r.dims, nil
+}
+
+func TestCheckDimensions(t *testing.T) {
+	ctx := context.Background()
+	mockEmbedder := new(MockEmbedder)
+	mockEmbedder.On("EmbedContent", ctx, mock.Anything).Return(make([]float32, 1536), nil)
+
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), mockEmbedder, sizedRepo{new(MockRepo), 768}, new(MockDocumentRepo), new(MockJobRepo))
+	assert.ErrorIs(t, service.CheckDimensions(ctx), domain.ErrDimensionMismatch)
+
+	service = NewIngestionService(new(MockParser), NewChunker(100, 10), mockEmbedder, sizedRepo{new(MockRepo), 1536}, new(MockDocumentRepo), new(MockJobRepo))
+	assert.NoError(t, service.CheckDimensions(ctx))
+
+	// Stores that take any size need no probe
+	probes := len(mockEmbedder.Calls)
+	service = NewIngestionService(new(MockParser), NewChunker(100, 10), mockEmbedder, new(MockRepo), new(MockDocumentRepo), new(MockJobRepo))
+	assert.NoError(t, service.CheckDimensions(ctx))
+	assert.Len(t, mockEmbedder.Calls, probes)
+}
+
+func TestFailJob_MarksDocumentFailed(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, new(MockJobRepo))
+
+	ctx := context.Background()
+	doc := &domain.Document{ID: uuid.New(), Status: domain.DocumentStatusProcessing}
+	mockDocs.On("GetDocument", ctx, doc.ID).Return(doc, nil)
+	mockDocs.On("UpdateDocument", ctx, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.Status == domain.DocumentStatusFailed
+	})).Return(nil)
+
+	assert.NoError(t, service.FailJob(ctx, &domain.IngestionJob{DocumentID: doc.ID}))
+	mockDocs.AssertExpectations(t)
+}
+
+func TestProcessJob_OnlyEmbedsNewChunks(t *testing.T) {
+	mockParser := new(MockParser)
+	mockRepo := new(MockRepo)
+	mockDocs := new(MockDocumentRepo)
+	mockEmbedder := new(MockEmbedder)
+
+	// One-token windows without overlap: "aaaa", " bbbb", " aaaa", " cccc\n"
+	service := NewIngestionService(mockParser, NewChunker(1, 0), mockEmbedder, mockRepo, mockDocs, new(MockJobRepo))
+
+	ctx := context.Background()
+	doc := &domain.Document{ID: uuid.New(), Subject: "Physics", Chapter: 1, Language: "en", Status: domain.DocumentStatusQueued}
+	file := []byte("stored pdf")
+	job := &domain.IngestionJob{ID: uuid.New(), DocumentID: doc.ID}
+	hashA, hashB, hashC := hashChunk("aaaa"), hashChunk("bbbb"), hashChunk("cccc")
+
+	mockDocs.On("GetDocument", ctx, doc.ID).Return(doc, nil)
+	mockDocs.On("GetDocumentContent", ctx, doc.ID).Return(file, nil)
+	mockDocs.On("UpdateDocument", ctx, doc).Return(nil)
+	mockParser.On("Parse", mock.Anything, int64(len(file))).Return([]Segment{{Page: 1, Text: "aaaa bbbb aaaa cccc"}}, nil)
+	// "a" is already stored (from an earlier version or an interrupted run); "stale" is no longer in the document
+	mockRepo.On("ChunkHashes", ctx, doc.ID).Return([]string{hashA, "stale"}, nil)
+	mockEmbedder.On("EmbedContent", mock.Anything, " bbbb").Return([]float32{0.2}, nil)
+	mockEmbedder.On("EmbedContent", mock.Anything, " cccc\n").Return([]float32{0.3}, nil)
+	mockRepo.On("ReplaceChunks", ctx, doc.ID, mock.MatchedBy(func(add []*domain.DocumentChunk) bool {
+		return len(add) == 2 && add[0].ChunkIndex == 1 && add[1].ChunkIndex == 3 && add[1].ContentHash == hashC
+	}), mock.MatchedBy(func(keep []*domain.DocumentChunk) bool {
+		return len(keep) == 3 && keep[0].ContentHash == hashA && keep[1].ContentHash == hashB && keep[2].ContentHash == hashC
+	})).Return(nil)
+
+	var reported [][2]int
+	err := service.ProcessJob(ctx, job, func(processed, total int) {
+		reported = append(reported, [2]int{processed, total})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]int{{1, 3}, {3, 3}}, reported)
+	assert.Equal(t, 3, doc.ChunkCount)
+	assert.Equal(t, domain.DocumentStatusReady, doc.Status)
+
+	mockEmbedder.AssertNotCalled(t, "EmbedContent", mock.Anything, "aaaa")
+	mockRepo.AssertExpectations(t)
 	mockEmbedder.AssertExpectations(t)
 }
+
+func TestProcessJob_CommitsInBatches(t *testing.T) {
+	mockParser := new(MockParser)
+	mockRepo := new(MockRepo)
+	mockDocs := new(MockDocumentRepo)
+	mockEmbedder := new(MockEmbedder)
+
+	service := NewIngestionService(mockParser, NewChunker(4, 0), mockEmbedder, mockRepo, mockDocs, new(MockJobRepo))
+	// Commit windows of 5 * 4 = 20 chunks
+	service.EmbedBatchSize = 5
+	service.EmbedConcurrency = 4
+
+	ctx := context.Background()
+	doc := &domain.Document{ID: uuid.New()}
+	file := []byte("stored pdf")
+
+	// 49 five-digit numbers of two tokens each give 25 distinct chunks
+	var text strings.Builder
+	for i := 0; i < 49; i++ {
+		fmt.Fprintf(&text, "%05d ", i)
+	}
+
+	mockDocs.On("GetDocument", ctx, doc.ID).Return(doc, nil)
+	mockDocs.On("GetDocumentContent", ctx, doc.ID).Return(file, nil)
+	mockDocs.On("UpdateDocument", ctx, doc).Return(nil)
+	mockParser.On("Parse", mock.Anything, mock.Anything).Return([]Segment{{Page: 1, Text: text.String()}}, nil)
+	mockRepo.On("ChunkHashes", ctx, doc.ID).Return([]string{}, nil)
+	mockEmbedder.On("EmbedContent", mock.Anything, mock.Anything).Return([]float32{0.1}, nil)
+	mockRepo.On("SaveChunks", ctx, mock.MatchedBy(func(c []*domain.DocumentChunk) bool { return len(c) == 20 })).Return(nil).Once()
+	mockRepo.On("ReplaceChunks", ctx, doc.ID, mock.MatchedBy(func(c []*domain.DocumentChunk) bool { return len(c) == 5 }), mock.Anything).Return(nil).Once()
+
+	var reported [][2]int
+	err := service.ProcessJob(ctx, &domain.IngestionJob{DocumentID: doc.ID}, func(processed, total int) {
+		reported = append(reported, [2]int{processed, total})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]int{{0, 25}, {20, 25}, {25, 25}}, reported)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProcessJob_MovesUnchangedChunks(t *testing.T) {
+	mockParser := new(MockParser)
+	mockDocs := new(MockDocumentRepo)
+	mockEmbedder := new(MockEmbedder)
+	repo := repository.NewMemoryVectorRepo()
+	service := NewIngestionService(mockParser, NewChunker(100, 0), mockEmbedder, repo, mockDocs, new(MockJobRepo))
+
+	ctx := context.Background()
+	doc := &domain.Document{ID: uuid.New(), Subject: "Physics", Language: "en"}
+	file := []byte("stored pdf")
+
+	mockDocs.On("GetDocument", ctx, doc.ID).Return(doc, nil)
+	mockDocs.On("GetDocumentContent", ctx, doc.ID).Return(file, nil)
+	mockDocs.On("UpdateDocument", ctx, doc).Return(nil)
+	mockEmbedder.On("EmbedContent", mock.Anything, mock.Anything).Return([]float32{1}, nil)
+	mockParser.On("Parse", mock.Anything, mock.Anything).Return([]Segment{
+		{Page: 1, Chapter: 1, Section: "Force", Text: "Force is a push."},
+	}, nil).Once()
+	// The new version adds a page before the unchanged text
+	mockParser.On("Parse", mock.Anything, mock.Anything).Return([]Segment{
+		{Page: 1, Section: "Preface", Text: "A new preface."},
+		{Page: 2, Chapter: 2, Section: "Force", Text: "Force is a push."},
+	}, nil).Once()
+
+	assert.NoError(t, service.ProcessJob(ctx, &domain.IngestionJob{DocumentID: doc.ID}, nil))
+	assert.NoError(t, service.ProcessJob(ctx, &domain.IngestionJob{DocumentID: doc.ID}, nil))
+
+	results, err := repo.SearchKeyword(ctx, "push", domain.SearchQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, 1, results[0].ChunkIndex)
+		assert.Equal(t, 2, results[0].Page)
+		assert.Equal(t, 2, results[0].PageEnd)
+		assert.Equal(t, 2, results[0].Chapter)
+	}
+	// The unchanged text was not embedded again
+	mockEmbedder.AssertNumberOfCalls(t, "EmbedContent", 2)
+}
+
+func TestProcessJob_RebuildSwapsInNewChunks(t *testing.T) {
+	mockParser := new(MockParser)
+	mockDocs := new(MockDocumentRepo)
+	mockEmbedder := new(MockEmbedder)
+	repo := repository.NewMemoryVectorRepo()
+	service := NewIngestionService(mockParser, NewChunker(100, 0), mockEmbedder, repo, mockDocs, new(MockJobRepo))
+	service.EmbedBatchSize = 1
+	service.EmbedConcurrency = 1
+
+	ctx := context.Background()
+	doc := &domain.Document{ID: uuid.New(), Subject: "Physics", Language: "en"}
+
+	mockDocs.On("GetDocument", ctx, doc.ID).Return(doc, nil)
+	mockDocs.On("GetDocumentContent", ctx, doc.ID).Return([]byte("stored pdf"), nil)
+	mockDocs.On("UpdateDocument", ctx, doc).Return(nil)
+	mockParser.On("Parse", mock.Anything, mock.Anything).Return([]Segment{
+		{Page: 1, Chapter: 1, Section: "Force", Text: "Force is a push."},
+		{Page: 2, Chapter: 1, Section: "Mass", Text: "Mass resists a push."},
+	}, nil)
+	mockEmbedder.On("EmbedContent", mock.Anything, mock.Anything).Return([]float32{1}, nil).Times(2)
+	assert.NoError(t, service.ProcessJob(ctx, &domain.IngestionJob{DocumentID: doc.ID}, nil))
+
+	// The new model fails on the second chunk: nothing is swapped in
+	mockEmbedder.On("EmbedContent", mock.Anything, mock.Anything).Return([]float32{2}, nil).Once()
+	mockEmbedder.On("EmbedContent", mock.Anything, mock.Anything).Return([]float32(nil), assert.AnError).Once()
+	assert.Error(t, service.ProcessJob(ctx, &domain.IngestionJob{DocumentID: doc.ID, Rebuild: true}, nil))
+
+	results, err := repo.SearchSimilar(ctx, []float32{1}, domain.SearchQuery{Limit: 10, IncludeEmbeddings: true})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, []float32{1}, results[0].Embedding)
+		assert.Equal(t, []float32{1}, results[1].Embedding)
+	}
+	assert.Equal(t, 2, doc.ChunkCount)
+
+	// A rebuild that succeeds replaces every chunk
+	mockEmbedder.On("EmbedContent", mock.Anything, mock.Anything).Return([]float32{3}, nil)
+	assert.NoError(t, service.ProcessJob(ctx, &domain.IngestionJob{DocumentID: doc.ID, Rebuild: true}, nil))
+
+	results, err = repo.SearchSimilar(ctx, []float32{1}, domain.SearchQuery{Limit: 10, IncludeEmbeddings: true})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, []float32{3}, results[0].Embedding)
+		assert.Equal(t, []float32{3}, results[1].Embedding)
+	}
+	assert.Equal(t, 2, doc.ChunkCount)
+}
+
+func TestEmbedChunks_BatchesInOrder(t *testing.T) {
+	embedder := new(MockBatchEmbedder)
+	service := NewIngestionService(new(MockParser), NewChunker(10, 0), embedder, new(MockRepo), new(MockDocumentRepo), new(MockJobRepo))
+	service.EmbedBatchSize = 2
+	service.EmbedConcurrency = 3
+
+	var chunks []*domain.DocumentChunk
+	for i := 0; i < 5; i++ {
+		chunks = append(chunks, &domain.DocumentChunk{ChunkIndex: i, Content: fmt.Sprintf("chunk %d", i)})
+	}
+
+	embedder.On("EmbedBatch", mock.Anything, []string{"chunk 0", "chunk 1"}).Return([][]float32{{0}, {1}}, nil)
+	embedder.On("EmbedBatch", mock.Anything, []string{"chunk 2", "chunk 3"}).Return([][]float32{{2}, {3}}, nil)
+	embedder.On("EmbedBatch", mock.Anything, []string{"chunk 4"}).Return([][]float32{{4}}, nil)
+
+	err := service.embedChunks(context.Background(), chunks)
+	assert.NoError(t, err)
+	for i, c := range chunks {
+		assert.Equal(t, []float32{float32(i)}, c.Embedding)
+	}
+	embedder.AssertExpectations(t)
+	embedder.AssertNotCalled(t, "EmbedContent", mock.Anything, mock.Anything)
+}
+
+func TestEmbedChunks_BatchFailure(t *testing.T) {
+	embedder := new(MockBatchEmbedder)
+	service := NewIngestionService(new(MockParser), NewChunker(10, 0), embedder, new(MockRepo), new(MockDocumentRepo), new(MockJobRepo))
+	service.EmbedBatchSize = 2
+
+	chunks := []*domain.DocumentChunk{{ChunkIndex: 0, Content: "a"}, {ChunkIndex: 1, Content: "b"}}
+	embedder.On("EmbedBatch", mock.Anything, mock.Anything).Return(nil, errors.New("quota exceeded"))
+
+	err := service.embedChunks(context.Background(), chunks)
+	assert.ErrorContains(t, err, "chunks 0-1: quota exceeded")
+}
+
+func TestEmbedChunks_BatchCountMismatch(t *testing.T) {
+	embedder := new(MockBatchEmbedder)
+	service := NewIngestionService(new(MockParser), NewChunker(10, 0), embedder, new(MockRepo), new(MockDocumentRepo), new(MockJobRepo))
+
+	chunks := []*domain.DocumentChunk{{ChunkIndex: 0, Content: "a"}, {ChunkIndex: 1, Content: "b"}}
+	embedder.On("EmbedBatch", mock.Anything, mock.Anything).Return([][]float32{{0.1}}, nil)
+
+	err := service.embedChunks(context.Background(), chunks)
+	assert.ErrorContains(t, err, "expected 2 embeddings, got 1")
+}
+
+func TestHashChunk_IgnoresWhitespace(t *testing.T) {
+	assert.Equal(t, hashChunk("Newton's  first\nlaw "), hashChunk("Newton's first law"))
+	assert.NotEqual(t, hashChunk("Newton's first law"), hashChunk("Newton's second law"))
+}
+
+func TestReindexDocument(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockDocs := new(MockDocumentRepo)
+	mockJobs := new(MockJobRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), mockRepo, mockDocs, mockJobs)
+
+	ctx := context.Background()
+	doc := &domain.Document{ID: uuid.New(), Status: domain.DocumentStatusReady}
+
+	mockDocs.On("GetDocument", ctx, doc.ID).Return(doc, nil)
+	mockJobs.On("ActiveJob", ctx, doc.ID).Return(nil, domain.ErrNotFound)
+	mockDocs.On("UpdateDocument", ctx, mock.MatchedBy(func(d *domain.Document) bool {
+		return d.Status == domain.DocumentStatusQueued
+	})).Return(nil)
+	// The job embeds every chunk again
+	mockJobs.On("CreateJob", ctx, mock.MatchedBy(func(j *domain.IngestionJob) bool {
+		return j.DocumentID == doc.ID && j.ChunksProcessed == 0 && j.Rebuild
+	})).Return(nil)
+
+	job, err := service.ReindexDocument(ctx, doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.JobStatusQueued, job.Status)
+
+	// The current chunks stay until the job replaces them
+	mockRepo.AssertNotCalled(t, "DeleteChunksByDocument", mock.Anything, mock.Anything)
+	mockDocs.AssertExpectations(t)
+	mockJobs.AssertExpectations(t)
+}
+func TestReindexDocument_WhileIngesting(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	mockJobs := new(MockJobRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, mockJobs)
+
+	ctx := context.Background()
+	doc := &domain.Document{ID: uuid.New(), Status: domain.DocumentStatusQueued}
+	mockDocs.On("GetDocument", ctx, doc.ID).Return(doc, nil)
+	mockJobs.On("ActiveJob", ctx, doc.ID).Return(&domain.IngestionJob{ID: uuid.New(), DocumentID: doc.ID, Status: domain.JobStatusQueued}, nil)
+
+	_, err := service.ReindexDocument(ctx, doc.ID)
+	assert.ErrorIs(t, err, domain.ErrDocumentBusy)
+	mockJobs.AssertNotCalled(t, "CreateJob", mock.Anything, mock.Anything)
+}
+
+func TestReindexDocument_NotFound(t *testing.T) {
+	mockDocs := new(MockDocumentRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), new(MockRepo), mockDocs, new(MockJobRepo))
+
+	id := uuid.New()
+	mockDocs.On("GetDocument", mock.Anything, id).Return(nil, domain.ErrNotFound)
+
+	_, err := service.ReindexDocument(context.Background(), id)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestDeleteDocument_RemovesChunksFromVectorStore(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryVectorRepo()
+	mockDocs := new(MockDocumentRepo)
+	service := NewIngestionService(new(MockParser), NewChunker(100, 10), new(MockEmbedder), repo, mockDocs, new(MockJobRepo))
+
+	docID, otherID := uuid.New(), uuid.New()
+	assert.NoError(t, repo.SaveChunks(ctx, []*domain.DocumentChunk{
+		{ID: uuid.New(), DocumentID: docID, ContentHash: "a", Embedding: []float32{1}},
+		{ID: uuid.New(), DocumentID: otherID, ContentHash: "b", Embedding: []float32{1}},
+	}))
+	mockDocs.On("DeleteDocument", ctx, docID).Return(nil)
+	mockDocs.On("DeleteDocument", ctx, otherID).Return(domain.ErrNotFound)
+
+	assert.NoError(t, service.DeleteDocument(ctx, docID))
+	hashes, _ := repo.ChunkHashes(ctx, docID)
+	assert.Empty(t, hashes)
+
+	// Chunks stay when the document could not be deleted
+	assert.ErrorIs(t, service.DeleteDocument(ctx, otherID), domain.ErrNotFound)
+	hashes, _ = repo.ChunkHashes(ctx, otherID)
+	assert.Equal(t, []string{"b"}, hashes)
+}
@@ -0,0 +1,340 @@
+package ingestion
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// "Chapter 5", "Unit V: Motion", "অধ্যায় ৫ গতি", "Chapter Five"
+	chapterHeading = regexp.MustCompile(`(?i)^(?:chapter|unit|অধ্যায়)\s*[:.\-–]?\s*([0-9০-৯]+|[\p{L}\p{M}]+)(?:$|[\s:.\-–])`)
+	// "প্রথম অধ্যায়", "Fifth Chapter"
+	ordinalChapterHeading = regexp.MustCompile(`(?i)^([\p{L}\p{M}]+)\s+(?:chapter|অধ্যায়)(?:$|[\s:.\-–])`)
+	// "5 Motion" or "5. Motion", for outline entries only: in running text
+	// too many lines start with a number
+	numberedChapter = regexp.MustCompile(`^([0-9০-৯]+)\.?\s+\p{L}`)
+	// "5.2 Friction" or "৫.২ ঘর্ষণ"
+	numberedSection = regexp.MustCompile(`^([0-9০-৯]+)\.[0-9০-৯]+(?:\.[0-9০-৯]+)*\.?\s+\p{L}`)
+	// A Roman numeral written the standard way, up to 399, so that words like
+	// "Civil" are not read as numbers
+	romanNumeral = regexp.MustCompile(`^c{0,3}(?:xc|xl|l?x{0,3})(?:ix|iv|v?i{0,3})$`)
+)
+
+// numberWords are the spelled-out numbers chapter headings use
+var numberWords = map[string]int{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8,
+	"nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15,
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6, "seventh": 7, "eighth": 8,
+	"ninth": 9, "tenth": 10, "eleventh": 11, "twelfth": 12, "thirteenth": 13, "fourteenth": 14, "fifteenth": 15,
+	"প্রথম": 1, "দ্বিতীয়": 2, "তৃতীয়": 3, "চতুর্থ": 4, "পঞ্চম": 5, "ষষ্ঠ": 6, "সপ্তম": 7, "অষ্টম": 8,
+	"নবম": 9, "দশম": 10, "একাদশ": 11, "দ্বাদশ": 12, "ত্রয়োদশ": 13, "চতুর্দশ": 14, "পঞ্চদশ": 15,
+}
+
+// maxTOCChapters is how many chapter headings a page may have before it is
+// taken for a table of contents, whose headings are ignored
+const maxTOCChapters = 2
+
+// chapterNumber reports the number of the chapter line is the heading of
+func chapterNumber(line string) (int, bool) {
+	// NFC spells য় as য with nukta, as the patterns do, whichever form the
+	// source used
+	line = norm.NFC.String(line)
+	if !headingShaped(line) {
+		return 0, false
+	}
+	if m := chapterHeading.FindStringSubmatch(line); m != nil {
+		return parseNumber(m[1])
+	}
+	if m := ordinalChapterHeading.FindStringSubmatch(line); m != nil {
+		return parseNumber(m[1])
+	}
+	return 0, false
+}
+
+// sectionTitle reports whether line is the heading of a numbered section of
+// chapter, e.g. "5.2 Friction" in chapter 5
+func sectionTitle(line string, chapter int) (string, bool) {
+	if chapter == 0 || !isHeading(norm.NFC.String(line)) {
+		return "", false
+	}
+	m := numberedSection.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	if n, ok := parseNumber(m[1]); !ok || n != chapter {
+		return "", false
+	}
+	return line, true
+}
+
+// parseNumber reads a chapter number written in Latin or Bengali digits, in
+// Roman numerals or as an English or Bengali word
+func parseNumber(s string) (int, bool) {
+	if n, ok := numberWords[strings.ToLower(s)]; ok {
+		return n, true
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '০' && r <= '৯' {
+			return '0' + r - '০'
+		}
+		return r
+	}, s)
+	if n, err := strconv.Atoi(digits); err == nil {
+		return n, n > 0
+	}
+
+	return parseRoman(strings.ToLower(s))
+}
+
+func parseRoman(s string) (int, bool) {
+	if !romanNumeral.MatchString(s) {
+		return 0, false
+	}
+	values := map[rune]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100}
+	n, prev := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		v, ok := values[rune(s[i])]
+		if !ok {
+			return 0, false
+		}
+		if v < prev {
+			n -= v
+		} else {
+			n += v
+			prev = v
+		}
+	}
+	return n, n > 0
+}
+
+// hasStructure reports whether any segment is placed in a chapter or section
+func hasStructure(segments []Segment) bool {
+	for _, seg := range segments {
+		if seg.Chapter != 0 || seg.Section != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// detectStructure places segments in chapters and sections by their headings:
+// "Chapter 5" or "অধ্যায় ৫" starts chapter 5, and a numbered heading such as
+// "5.2 Friction" starts a section of it. A page is split where a heading
+// starts partway down. chapter is the chapter the text starts in, or 0 if it
+// is not known.
+//
+// Only a heading that stands apart from the text around it and changes the
+// chapter or section counts, so wrapped lines of prose and running heads
+// repeated on every page change nothing. Headings on a page with more than
+// maxTOCChapters chapter headings, which is a table of contents, are ignored.
+func detectStructure(segments []Segment, chapter int) []Segment {
+	section := ""
+	var out []Segment
+	for _, seg := range segments {
+		lines := strings.Split(seg.Text, "\n")
+		chapters := 0
+		for _, line := range lines {
+			if _, ok := chapterNumber(strings.Join(strings.Fields(line), " ")); ok {
+				chapters++
+			}
+		}
+		toc := chapters > maxTOCChapters
+
+		current := Segment{Page: seg.Page, Chapter: chapter, Section: section}
+		start := 0
+		flush := func(end int) {
+			text := strings.Join(lines[start:end], "\n")
+			if strings.TrimSpace(text) != "" {
+				current.Text = text
+				out = append(out, current)
+			}
+			start = end
+		}
+
+		for i, line := range lines {
+			if toc {
+				break
+			}
+			if !standsAlone(lines, i) {
+				continue
+			}
+			line = strings.Join(strings.Fields(line), " ")
+			if n, ok := chapterNumber(line); ok && n != chapter {
+				flush(i)
+				chapter, section = n, ""
+			} else if title, ok := sectionTitle(line, chapter); ok && title != section {
+				flush(i)
+				section = title
+			} else {
+				continue
+			}
+			current = Segment{Page: seg.Page, Chapter: chapter, Section: section}
+		}
+		flush(len(lines))
+	}
+	return out
+}
+
+// outlineEntry is a bookmark in a document's outline
+type outlineEntry struct {
+	title string
+	page  int
+	depth int // 0 for top-level entries
+}
+
+// headingTracker follows the chapter and section a document is in through
+// its headings, each with its nesting depth (0 for top-level). A heading
+// titled like a chapter heading, or a top-level one starting with a number,
+// starts a chapter; headings nested below it start its sections. Other
+// headings, such as "Index", start a section outside any chapter.
+type headingTracker struct {
+	chapter      int
+	chapterDepth int // -1 outside a chapter
+}
+
+func newHeadingTracker() *headingTracker {
+	return &headingTracker{chapterDepth: -1}
+}
+
+// enter moves past a heading, returning the chapter and section it starts
+func (t *headingTracker) enter(title string, depth int) (int, string) {
+	title = strings.Join(strings.Fields(title), " ")
+	n, ok := chapterNumber(title)
+	if !ok && depth == 0 {
+		if m := numberedChapter.FindStringSubmatch(title); m != nil {
+			n, ok = parseNumber(m[1])
+		}
+	}
+
+	switch {
+	case ok:
+		t.chapter, t.chapterDepth = n, depth
+		return n, ""
+	case t.chapterDepth >= 0 && depth > t.chapterDepth:
+		return t.chapter, title
+	default:
+		t.chapter, t.chapterDepth = 0, -1
+		return 0, title
+	}
+}
+
+// applyOutline places segments in chapters and sections by the outline
+// entries pointing at their pages, read as headings by a headingTracker. When
+// several entries point at one page the last wins.
+func applyOutline(segments []Segment, entries []outlineEntry) []Segment {
+	type mark struct {
+		page    int
+		chapter int
+		section string
+	}
+	var marks []mark
+	tracker := newHeadingTracker()
+	for _, e := range entries {
+		chapter, section := tracker.enter(e.title, e.depth)
+		marks = append(marks, mark{e.page, chapter, section})
+	}
+	if len(marks) == 0 {
+		return segments
+	}
+	sort.SliceStable(marks, func(i, j int) bool { return marks[i].page < marks[j].page })
+
+	out := make([]Segment, len(segments))
+	for i, seg := range segments {
+		// Last mark at or before the page
+		j := sort.Search(len(marks), func(j int) bool { return marks[j].page > seg.Page }) - 1
+		if j >= 0 {
+			seg.Chapter, seg.Section = marks[j].chapter, marks[j].section
+		}
+		out[i] = seg
+	}
+	return out
+}
+
+// chunkSections chunks each run of segments from one chapter and section on
+// its own, so no chunk straddles two sections, and tags the chunks with them
+func chunkSections(chunker SegmentChunker, segments []Segment) []Chunk {
+	var chunks []Chunk
+	for start := 0; start < len(segments); {
+		first := segments[start]
+		end := start + 1
+		for end < len(segments) && segments[end].Chapter == first.Chapter && segments[end].Section == first.Section {
+			end++
+		}
+
+		for _, c := range chunker.ChunkSegments(segments[start:end]) {
+			c.Chapter, c.Section = first.Chapter, first.Section
+			chunks = append(chunks, c)
+		}
+		start = end
+	}
+	return chunks
+}
+
+// segmentBuilder turns the blocks of a document read in order, its headings,
+// paragraphs and page breaks, into segments placed in chapters and sections by
+// the headings. It is for formats that mark up their headings; a document
+// without page breaks is a single page.
+type segmentBuilder struct {
+	tracker  *headingTracker
+	segments []Segment
+	current  Segment
+	text     strings.Builder
+	// pageText is whether the current page has any text yet
+	pageText bool
+}
+
+func newSegmentBuilder() *segmentBuilder {
+	return &segmentBuilder{tracker: newHeadingTracker(), current: Segment{Page: 1}}
+}
+
+// heading starts a new segment at a heading nested depth levels deep
+func (b *segmentBuilder) heading(title string, depth int) {
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" {
+		return
+	}
+	b.flush()
+	b.current.Chapter, b.current.Section = b.tracker.enter(title, depth)
+	b.text.WriteString(title + "\n")
+	b.pageText = true
+}
+
+// paragraph adds a paragraph, whose lines are kept
+func (b *segmentBuilder) paragraph(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	b.text.WriteString(text + "\n\n")
+	b.pageText = true
+}
+
+// pageBreak starts a new page, unless nothing is on the current one yet
+func (b *segmentBuilder) pageBreak() {
+	if !b.pageText {
+		return
+	}
+	b.flush()
+	b.current.Page++
+	b.pageText = false
+}
+
+func (b *segmentBuilder) flush() {
+	if text := strings.TrimSpace(b.text.String()); text != "" {
+		seg := b.current
+		seg.Text = text
+		b.segments = append(b.segments, seg)
+	}
+	b.text.Reset()
+}
+
+// finish returns the segments built
+func (b *segmentBuilder) finish() []Segment {
+	b.flush()
+	return b.segments
+}
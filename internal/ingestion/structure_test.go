@@ -0,0 +1,152 @@
+package ingestion
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChapterNumber(t *testing.T) {
+	for line, want := range map[string]int{
+		"Chapter 5":         5,
+		"Chapter 12: Waves": 12,
+		"CHAPTER V":         5,
+		"Unit IX - Light":   9,
+		"Chapter Five":      5,
+		"অধ্যায় ৫":         5,
+		"অধ্যায় ৫: গতি":    5,
+		// য় precomposed, as most keyboards type it
+		"\u0985\u09a7\u09cd\u09af\u09be\u09df \u09eb":                         5,
+		"\u09a4\u09c3\u09a4\u09c0\u09df \u0985\u09a7\u09cd\u09af\u09be\u09df": 3,
+		"প্রথম অধ্যায়":                                                       1,
+		"Third Chapter":                                                       3,
+		"Chapter 5 explains.":                                                 0,
+		"Chapters":                                                            0,
+		"Chapter Summary":                                                     0,
+		"Chapter Civil":                                                       0,
+		"Chapter Mix":                                                         0,
+		"Unit Dim":                                                            0,
+		"Chapter IIII":                                                        0,
+		"Chapter VX":                                                          0,
+		"Unit XLII":                                                           42,
+		"2.1 Newton's Laws":                                                   0,
+		"in chapter 5 we saw":                                                 0,
+		"an ordinary sentence":                                                0,
+	} {
+		n, ok := chapterNumber(line)
+		assert.Equal(t, want, n, line)
+		assert.Equal(t, want != 0, ok, line)
+	}
+}
+
+func TestDetectStructure_SplitsPageAtHeadings(t *testing.T) {
+	segments := []Segment{
+		{Page: 1, Text: "Preface text."},
+		{Page: 2, Text: "Last words of the preface.\nChapter 5: Force\nForce is a push.\n5.1 Friction\nFriction opposes motion."},
+		{Page: 3, Text: "More on friction.\n5.2 Pressure\nPressure is force per area."},
+	}
+
+	assert.Equal(t, []Segment{
+		{Page: 1, Text: "Preface text."},
+		{Page: 2, Text: "Last words of the preface."},
+		{Page: 2, Chapter: 5, Text: "Chapter 5: Force\nForce is a push."},
+		{Page: 2, Chapter: 5, Section: "5.1 Friction", Text: "5.1 Friction\nFriction opposes motion."},
+		{Page: 3, Chapter: 5, Section: "5.1 Friction", Text: "More on friction."},
+		{Page: 3, Chapter: 5, Section: "5.2 Pressure", Text: "5.2 Pressure\nPressure is force per area."},
+	}, detectStructure(segments, 0))
+}
+
+func TestDetectStructure_IgnoresRunningHeadsAndContents(t *testing.T) {
+	segments := []Segment{
+		{Page: 1, Text: "Contents\nChapter 1 Motion\nChapter 2 Force\nChapter 3 Energy"},
+		{Page: 2, Text: "Chapter 1\nThings move."},
+		{Page: 3, Text: "Chapter 1\nThey keep moving."},
+		// A section numbered for another chapter is not a section of this one
+		{Page: 4, Text: "3.1 Work\nSee chapter 3."},
+	}
+
+	out := detectStructure(segments, 0)
+	assert.Len(t, out, 4)
+	assert.Equal(t, 0, out[0].Chapter)
+	for _, seg := range out[1:] {
+		assert.Equal(t, 1, seg.Chapter)
+		assert.Equal(t, "", seg.Section)
+	}
+}
+
+func TestDetectStructure_IgnoresWrappedNumberedLines(t *testing.T) {
+	segments := []Segment{{Page: 1, Text: strings.Join([]string{
+		"Chapter 9: Gravity",
+		"Near the Earth every falling body speeds up by",
+		"9.8 m/s2 every second, whatever its mass, which",
+		"Galileo showed. The same pull is known as",
+		"9.2 Newtons of force on each kilogram, so a",
+		"1 kg mass weighs 9.8 N.",
+		"9.1 Free Fall",
+		"A body in free fall feels no weight.",
+	}, "\n")}}
+
+	out := detectStructure(segments, 0)
+	assert.Len(t, out, 2)
+	assert.Equal(t, Segment{Page: 1, Chapter: 9, Text: strings.Join(strings.Split(segments[0].Text, "\n")[:6], "\n")}, out[0])
+	assert.Equal(t, "9.1 Free Fall", out[1].Section)
+}
+
+func TestDetectStructure_StartsInGivenChapter(t *testing.T) {
+	segments := []Segment{{Page: 1, Text: "Intro.\n4.1 Heat\nHeat flows."}}
+
+	assert.Equal(t, []Segment{
+		{Page: 1, Chapter: 4, Text: "Intro."},
+		{Page: 1, Chapter: 4, Section: "4.1 Heat", Text: "4.1 Heat\nHeat flows."},
+	}, detectStructure(segments, 4))
+}
+
+func TestApplyOutline(t *testing.T) {
+	segments := []Segment{{Page: 1}, {Page: 2}, {Page: 3}, {Page: 4}, {Page: 6}}
+	entries := []outlineEntry{
+		{title: "1 Motion", page: 2},
+		{title: "Speed", page: 3, depth: 1},
+		{title: "অধ্যায় ২", page: 4},
+		{title: "Index", page: 6},
+	}
+
+	out := applyOutline(segments, entries)
+	assert.Equal(t, []Segment{
+		{Page: 1},
+		{Page: 2, Chapter: 1},
+		{Page: 3, Chapter: 1, Section: "Speed"},
+		{Page: 4, Chapter: 2},
+		{Page: 6, Section: "Index"},
+	}, out)
+}
+
+func TestSegmentBuilder_PrecomposedBengaliHeading(t *testing.T) {
+	b := newSegmentBuilder()
+	b.heading("\u0985\u09a7\u09cd\u09af\u09be\u09df \u09eb", 0)
+	b.paragraph("বল হলো ধাক্কা বা টান।")
+	b.heading("৫.১ ঘর্ষণ", 1)
+	b.paragraph("ঘর্ষণ গতিকে বাধা দেয়।")
+
+	out := b.finish()
+	assert.Len(t, out, 2)
+	assert.Equal(t, 5, out[0].Chapter)
+	assert.Empty(t, out[0].Section)
+	assert.Equal(t, 5, out[1].Chapter)
+	assert.Equal(t, "৫.১ ঘর্ষণ", out[1].Section)
+}
+
+func TestChunkSections_KeepsSectionsApart(t *testing.T) {
+	chunker := NewStructuredChunker(200, 50)
+	segments := []Segment{
+		{Page: 1, Chapter: 5, Text: "Force is a push."},
+		{Page: 1, Chapter: 5, Section: "5.1 Friction", Text: "Friction opposes motion."},
+		{Page: 2, Chapter: 5, Section: "5.1 Friction", Text: "It makes heat."},
+	}
+
+	chunks := chunkSections(chunker, segments)
+	assert.Equal(t, []Chunk{
+		{Content: "Force is a push.", PageStart: 1, PageEnd: 1, Chapter: 5},
+		{Content: "Friction opposes motion. It makes heat.", PageStart: 1, PageEnd: 2, Chapter: 5, Section: "5.1 Friction"},
+	}, chunks)
+}
@@ -0,0 +1,351 @@
+package ingestion
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"backend/internal/tokenizer"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// StructuredChunker splits text along its structure: a heading always starts
+// a new chunk, and within a section paragraphs and then sentences are packed
+// into chunks of up to MaxChunkSize tokens. Only a sentence longer than that
+// is cut, between words. Overlap is carried as whole sentences: each chunk
+// that continues a section starts with as many of the previous chunk's last
+// sentences as fit in Overlap tokens.
+type StructuredChunker struct {
+	MaxChunkSize int
+	Overlap      int
+	Tokenizer    tokenizer.Tokenizer
+}
+
+func NewStructuredChunker(maxChunkSize, overlap int) *StructuredChunker {
+	return &StructuredChunker{
+		MaxChunkSize: maxChunkSize,
+		Overlap:      overlap,
+		Tokenizer:    tokenizer.Approximate{},
+	}
+}
+
+var (
+	// A sentence ends with Latin punctuation or the Bengali dari, optionally
+	// followed by closing quotes or brackets, and then whitespace
+	sentenceBoundary = regexp.MustCompile(`[.!?।॥]+["'”’)\]]*\s+`)
+	// Words that end in a full stop without ending the sentence
+	abbreviation = regexp.MustCompile(`(?i)(?:^|\s)(?:e\.g|i\.e|etc|fig|figs|eq|eqs|no|vs|dr|mr|mrs|ms|st|approx|\p{L})\.$`)
+
+	// "Chapter 3", "Section 2.1", "Part II" or "পাঠ ১"; \b only knows ASCII
+	// word characters, so the number's end is spelled out
+	headingKeyword = regexp.MustCompile(`(?i)^(?:chapter|section|unit|part|lesson|অধ্যায়|পরিচ্ছেদ|পাঠ)\s*[:.\-–]?\s*([0-9০-৯]+(?:\.[0-9০-৯]+)*|[\p{L}\p{M}]+)(?:$|[\s:.\-–])`)
+	// "2.", "2.1 Motion" or "২.১ গতি": the number and the word after it
+	headingNumber = regexp.MustCompile(`^([0-9০-৯]+(?:\.[0-9০-৯]+)*)\.?\s+(\S+)`)
+	// A line ending a sentence, perhaps inside quotes or brackets
+	sentenceEnd = regexp.MustCompile(`[.!?।॥]["'”’)\]]*$`)
+)
+
+// units are the unit symbols that, after a number, mark a line as a quantity
+// in running text rather than a numbered heading. Lower-case ones need no
+// listing, as a title never starts with a lower-case word.
+var units = map[string]bool{
+	"N": true, "J": true, "W": true, "Pa": true, "K": true, "V": true, "Hz": true,
+	"C": true, "°C": true, "Ω": true, "L": true, "MJ": true, "kJ": true, "kW": true,
+}
+
+// maxHeadingLength is the longest line, in characters, taken for a heading
+const maxHeadingLength = 80
+
+// piece is a heading or a sentence, with the pages it spans
+type piece struct {
+	text      string
+	size      int // tokens
+	heading   bool
+	paragraph bool // first sentence of a paragraph
+	pageStart int
+	pageEnd   int
+}
+
+func (c *StructuredChunker) ChunkSegments(segments []Segment) []Chunk {
+	return c.pack(c.pieces(segments))
+}
+
+// pieces breaks the pages into headings and sentences, in order. Paragraphs
+// run on across page breaks; their lines are joined with single spaces.
+func (c *StructuredChunker) pieces(segments []Segment) []piece {
+	var pieces []piece
+
+	var para strings.Builder
+	// lineStarts[i] is the byte offset in para of the paragraph's i-th line,
+	// which is on page linePages[i]
+	var lineStarts, linePages []int
+	endParagraph := func() {
+		if para.Len() == 0 {
+			return
+		}
+		text := para.String()
+		pageAt := func(offset int) int {
+			return linePages[sort.SearchInts(lineStarts, offset+1)-1]
+		}
+		for i, s := range splitSentences(text) {
+			for _, part := range c.splitLong(text[s[0]:s[1]]) {
+				pieces = append(pieces, piece{
+					text:      part,
+					size:      c.Tokenizer.Count(part),
+					paragraph: i == 0,
+					pageStart: pageAt(s[0]),
+					pageEnd:   pageAt(s[1] - 1),
+				})
+			}
+		}
+		para.Reset()
+		lineStarts, linePages = nil, nil
+	}
+
+	for _, seg := range segments {
+		lines := strings.Split(seg.Text, "\n")
+		for i, line := range lines {
+			line = strings.Join(strings.Fields(line), " ")
+			switch {
+			case line == "":
+				endParagraph()
+			case isHeading(line) && standsAlone(lines, i):
+				endParagraph()
+				pieces = append(pieces, piece{
+					text:      line,
+					size:      c.Tokenizer.Count(line),
+					heading:   true,
+					pageStart: seg.Page,
+					pageEnd:   seg.Page,
+				})
+			default:
+				if para.Len() > 0 {
+					para.WriteByte(' ')
+				}
+				lineStarts = append(lineStarts, para.Len())
+				linePages = append(linePages, seg.Page)
+				para.WriteString(line)
+			}
+		}
+	}
+	endParagraph()
+
+	return pieces
+}
+
+// pack merges pieces into chunks of up to MaxChunkSize tokens. The space or
+// newline joining two pieces costs nothing, as it is part of the token after it.
+func (c *StructuredChunker) pack(pieces []piece) []Chunk {
+	var chunks []Chunk
+	var current []piece
+	size := 0
+	// fresh counts the pieces of current that are not overlap from the
+	// previous chunk, and body those of them that are sentences
+	fresh, body := 0, 0
+
+	emit := func() {
+		var sb strings.Builder
+		for i, p := range current {
+			switch {
+			case i == 0:
+			case p.heading || p.paragraph || current[i-1].heading:
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(p.text)
+		}
+		chunks = append(chunks, Chunk{
+			Content:   sb.String(),
+			PageStart: current[0].pageStart,
+			PageEnd:   current[len(current)-1].pageEnd,
+		})
+	}
+
+	for _, p := range pieces {
+		switch {
+		case p.heading && body > 0:
+			// A new section starts a new chunk, without overlap
+			emit()
+			current, size, fresh, body = nil, 0, 0, 0
+		case p.heading && fresh == 0:
+			// Overlap does not carry into a new section either
+			current, size = nil, 0
+		case !p.heading && body > 0 && size+p.size > c.MaxChunkSize:
+			emit()
+			current = c.overlapTail(current)
+			size, fresh, body = 0, 0, 0
+			for _, o := range current {
+				size += o.size
+			}
+		}
+
+		// Drop overlap that would push the chunk over budget
+		for fresh == 0 && len(current) > 0 && size+p.size > c.MaxChunkSize {
+			size -= current[0].size
+			current = current[1:]
+		}
+
+		current = append(current, p)
+		size += p.size
+		fresh++
+		if !p.heading {
+			body++
+		}
+	}
+	if fresh > 0 {
+		emit()
+	}
+
+	return chunks
+}
+
+// overlapTail returns the last sentences of chunk that fit in Overlap tokens
+func (c *StructuredChunker) overlapTail(chunk []piece) []piece {
+	size := 0
+	start := len(chunk)
+	for start > 0 {
+		p := chunk[start-1]
+		if p.heading || size+p.size > c.Overlap {
+			break
+		}
+		size += p.size
+		start--
+	}
+
+	return append([]piece(nil), chunk[start:]...)
+}
+
+// splitLong cuts a sentence longer than MaxChunkSize tokens between words,
+// or between tokens if a single word is that long
+func (c *StructuredChunker) splitLong(sentence string) []string {
+	if c.Tokenizer.Count(sentence) <= c.MaxChunkSize {
+		return []string{sentence}
+	}
+
+	var parts []string
+	var part strings.Builder
+	size := 0
+	flush := func() {
+		if part.Len() > 0 {
+			parts = append(parts, part.String())
+			part.Reset()
+			size = 0
+		}
+	}
+	for _, word := range strings.Fields(sentence) {
+		n := c.Tokenizer.Count(word)
+		if size+n > c.MaxChunkSize {
+			flush()
+		}
+		if n > c.MaxChunkSize {
+			tokens := c.Tokenizer.Split(word)
+			for len(tokens) > c.MaxChunkSize {
+				parts = append(parts, strings.Join(tokens[:c.MaxChunkSize], ""))
+				tokens = tokens[c.MaxChunkSize:]
+			}
+			word, n = strings.Join(tokens, ""), len(tokens)
+		}
+		if part.Len() > 0 {
+			part.WriteByte(' ')
+		}
+		part.WriteString(word)
+		size += n
+	}
+	flush()
+	return parts
+}
+
+// splitSentences returns the [start, end) byte ranges of the sentences in
+// text, without the whitespace between them
+func splitSentences(text string) [][2]int {
+	var sentences [][2]int
+	start := 0
+	for _, loc := range sentenceBoundary.FindAllStringIndex(text, -1) {
+		end := strings.TrimRightFunc(text[:loc[1]], unicode.IsSpace)
+		if abbreviation.MatchString(end[start:]) {
+			continue
+		}
+		sentences = append(sentences, [2]int{start, len(end)})
+		start = loc[1]
+	}
+	if start < len(text) {
+		sentences = append(sentences, [2]int{start, len(text)})
+	}
+	return sentences
+}
+
+// isHeading guesses whether a line of text is a heading: a short line that is
+// neither a sentence nor a formula, and is numbered and titled, starts with a
+// word like "Chapter" and a number, is marked up with "#", or is in capitals.
+// Whether the line also stands apart from the text around it, as a heading
+// does, is up to standsAlone.
+func isHeading(line string) bool {
+	if !headingShaped(line) {
+		return false
+	}
+	line = norm.NFC.String(line)
+
+	if strings.HasPrefix(line, "#") {
+		return true
+	}
+	if m := headingKeyword.FindStringSubmatch(line); m != nil {
+		if _, ok := parseNumber(strings.SplitN(m[1], ".", 2)[0]); ok {
+			return true
+		}
+	}
+	if m := headingNumber.FindStringSubmatch(line); m != nil {
+		return titleWord(m[2])
+	}
+
+	letters := 0
+	for _, r := range line {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsUpper(r) {
+			letters++
+		}
+	}
+	return letters >= 2
+}
+
+// titleWord reports whether word, following a number at the start of a line,
+// begins a title rather than running text: "2.1 Friction", not "2 kg of" or
+// "10 m/s in"
+func titleWord(word string) bool {
+	r, _ := utf8.DecodeRuneInString(word)
+	if !unicode.IsLetter(r) || unicode.IsLower(r) {
+		return false
+	}
+	return !units[strings.TrimRight(word, ",.;:")] && !strings.Contains(word, "/")
+}
+
+// standsAlone reports whether lines[i] is set apart as a heading is: at the
+// edge of the page, next to a blank line, or after a line that ends a
+// sentence or is a heading itself. A line that carries on the sentence of the
+// line before is wrapped prose, however much it looks like a heading.
+func standsAlone(lines []string, i int) bool {
+	if i == 0 || i == len(lines)-1 {
+		return true
+	}
+	prev := strings.Join(strings.Fields(lines[i-1]), " ")
+	next := strings.TrimSpace(lines[i+1])
+	return prev == "" || next == "" || sentenceEnd.MatchString(prev) || isHeading(prev)
+}
+
+// headingShaped reports whether line is short and neither a sentence nor a formula
+func headingShaped(line string) bool {
+	if utf8.RuneCountInString(line) > maxHeadingLength {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(line)
+	if strings.ContainsRune(".!?।॥,;", last) {
+		return false
+	}
+	// Formulas like "F = MA" on a line of their own are not headings
+	return !strings.ContainsAny(line, "=<>+^")
+}
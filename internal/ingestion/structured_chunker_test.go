@@ -0,0 +1,161 @@
+package ingestion
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func contents(chunks []Chunk) []string {
+	var out []string
+	for _, c := range chunks {
+		out = append(out, c.Content)
+	}
+	return out
+}
+
+func TestStructuredChunker_MergesSentencesUpToBudget(t *testing.T) {
+	chunker := NewStructuredChunker(15, 0)
+	segments := []Segment{{Page: 1, Text: "Force moves things. Mass resists it. Speed is distance over time."}}
+
+	assert.Equal(t, []string{
+		"Force moves things. Mass resists it.",
+		"Speed is distance over time.",
+	}, contents(chunker.ChunkSegments(segments)))
+}
+
+func TestStructuredChunker_SplitsOnDari(t *testing.T) {
+	chunker := NewStructuredChunker(20, 0)
+	segments := []Segment{{Page: 1, Text: "বল হলো ধাক্কা বা টান। ভর জড়তার পরিমাপ। বেগ হলো সরণের হার।"}}
+
+	assert.Equal(t, []string{
+		"বল হলো ধাক্কা বা টান। ভর জড়তার পরিমাপ।",
+		"বেগ হলো সরণের হার।",
+	}, contents(chunker.ChunkSegments(segments)))
+}
+
+func TestStructuredChunker_KeepsAbbreviationsInSentence(t *testing.T) {
+	chunker := NewStructuredChunker(15, 0)
+	segments := []Segment{{Page: 1, Text: "See Fig. 3 for the setup. It uses a spring, e.g. a coil."}}
+
+	assert.Equal(t, []string{
+		"See Fig. 3 for the setup.",
+		"It uses a spring, e.g. a coil.",
+	}, contents(chunker.ChunkSegments(segments)))
+}
+
+func TestStructuredChunker_HeadingStartsChunk(t *testing.T) {
+	chunker := NewStructuredChunker(200, 50)
+	segments := []Segment{{Page: 1, Text: "Chapter 2: Motion\nThings move.\n\n2.1 Speed\nSpeed is distance over time.\nIt is a scalar."}}
+
+	assert.Equal(t, []string{
+		"Chapter 2: Motion\nThings move.",
+		"2.1 Speed\nSpeed is distance over time. It is a scalar.",
+	}, contents(chunker.ChunkSegments(segments)))
+}
+
+func TestStructuredChunker_ParagraphsStartOnNewLines(t *testing.T) {
+	chunker := NewStructuredChunker(200, 0)
+	segments := []Segment{{Page: 1, Text: "The first line\nof a paragraph.\n\nA second paragraph."}}
+
+	assert.Equal(t, []string{
+		"The first line of a paragraph.\nA second paragraph.",
+	}, contents(chunker.ChunkSegments(segments)))
+}
+
+func TestStructuredChunker_WrappedLinesAreNotHeadings(t *testing.T) {
+	chunker := NewStructuredChunker(256, 48)
+	text := strings.Join([]string{
+		"A car accelerates uniformly from rest and reaches",
+		"10 m/s in 5 seconds. Find its acceleration and the",
+		"distance it covers. Some of the work is wasted.",
+		"Part of the energy is lost as heat to the",
+		"road, and the rest moves the car. The SI",
+		"Unit of force is the newton, named after",
+		"Isaac Newton. Heating takes energy too:",
+		"2 kg of water is heated from 20 to 80 degrees in",
+		"Section 3 of the book, which works out the",
+		"energy that takes.",
+	}, "\n")
+
+	chunks := chunker.ChunkSegments([]Segment{{Page: 1, Text: text}})
+	assert.Equal(t, []string{strings.Join(strings.Fields(text), " ")}, contents(chunks))
+}
+
+func TestStructuredChunker_OverlapsWholeSentences(t *testing.T) {
+	chunker := NewStructuredChunker(10, 5)
+	segments := []Segment{{Page: 1, Text: "One sentence here. Two is short. Three ends it."}}
+
+	assert.Equal(t, []string{
+		"One sentence here. Two is short.",
+		"Two is short. Three ends it.",
+	}, contents(chunker.ChunkSegments(segments)))
+}
+
+func TestStructuredChunker_SplitsLongSentenceBetweenWords(t *testing.T) {
+	chunker := NewStructuredChunker(8, 0)
+	sentence := "পদার্থবিজ্ঞান হলো বিজ্ঞানের একটি শাখা যা পদার্থ ও শক্তি নিয়ে কাজ করে।"
+	chunks := chunker.ChunkSegments([]Segment{{Page: 1, Text: sentence}})
+
+	assert.Greater(t, len(chunks), 1)
+	var words []string
+	for _, c := range chunks {
+		assert.LessOrEqual(t, chunker.Tokenizer.Count(c.Content), 8)
+		words = append(words, strings.Fields(c.Content)...)
+	}
+	// No word was cut in half
+	assert.Equal(t, strings.Fields(sentence), words)
+}
+
+func TestStructuredChunker_TracksPages(t *testing.T) {
+	chunker := NewStructuredChunker(12, 0)
+	segments := []Segment{
+		{Page: 3, Text: "A sentence that runs"},
+		{Page: 4, Text: "onto the next page. Then another one."},
+		{Page: 5, Text: "And the last sentence."},
+	}
+
+	chunks := chunker.ChunkSegments(segments)
+	assert.Equal(t, []string{
+		"A sentence that runs onto the next page.",
+		"Then another one. And the last sentence.",
+	}, contents(chunks))
+	assert.Equal(t, 3, chunks[0].PageStart)
+	assert.Equal(t, 4, chunks[0].PageEnd)
+	assert.Equal(t, 4, chunks[1].PageStart)
+	assert.Equal(t, 5, chunks[1].PageEnd)
+}
+
+func TestStructuredChunker_Empty(t *testing.T) {
+	chunker := NewStructuredChunker(100, 10)
+	assert.Empty(t, chunker.ChunkSegments(nil))
+	assert.Empty(t, chunker.ChunkSegments([]Segment{{Page: 1, Text: " \n\n "}}))
+}
+
+func TestIsHeading(t *testing.T) {
+	for line, want := range map[string]bool{
+		"Chapter 3":                true,
+		"অধ্যায় ৩: বল":            true,
+		"2.1 Newton's Laws":        true,
+		"২.১ গতির সূত্র":           true,
+		"# Summary":                true,
+		"EXERCISES":                true,
+		"F = MA":                   false,
+		"The force is 10 N.":       false,
+		"2 apples and 3 oranges.":  false,
+		"an ordinary line of text": false,
+		"Part II":                  true,
+		"Section 2.1 Friction":     true,
+		"Lesson 3: Waves":          true,
+		"10 m/s in 5 seconds. Find its acceleration and the": false,
+		"Part of the energy is lost as heat to the":          false,
+		"Unit of force is the newton, named after":           false,
+		"2 kg of water is heated from 20 to 80 degrees":      false,
+		"Section of the pipe where the flow is fastest":      false,
+		"9.8 m/s2 every second, whatever its mass, which":    false,
+		"5 N acts on the block":                              false,
+	} {
+		assert.Equal(t, want, isHeading(line), line)
+	}
+}
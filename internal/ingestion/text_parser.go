@@ -0,0 +1,141 @@
+package ingestion
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// readText reads a whole UTF-8 file, dropping a byte order mark and turning
+// Windows line endings into newlines
+func readText(r io.ReaderAt, size int64) (string, error) {
+	content, err := io.ReadAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	text := strings.TrimPrefix(string(content), "\ufeff")
+	return strings.ReplaceAll(text, "\r\n", "\n"), nil
+}
+
+// TextParser reads plain UTF-8 text. Form feeds separate pages; without them
+// the file is a single page.
+type TextParser struct{}
+
+func NewTextParser() *TextParser {
+	return &TextParser{}
+}
+
+func (p *TextParser) Parse(r io.ReaderAt, size int64) ([]Segment, error) {
+	text, err := readText(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []Segment
+	for i, page := range strings.Split(text, "\f") {
+		if strings.TrimSpace(page) == "" {
+			continue
+		}
+		segments = append(segments, Segment{Page: i + 1, Text: page})
+	}
+	return segments, nil
+}
+
+// MarkdownParser reads Markdown. Its headings place the text in chapters and
+// sections, and inline markup is reduced to the text it marks up. Form feeds
+// separate pages, as in plain text.
+type MarkdownParser struct{}
+
+func NewMarkdownParser() *MarkdownParser {
+	return &MarkdownParser{}
+}
+
+var (
+	// "## Title ##"
+	atxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	// The line under a setext heading: "===" for level 1 and "---" for level 2
+	setextUnderline = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	codeFence       = regexp.MustCompile("^ {0,3}(```|~~~)")
+	thematicBreak   = regexp.MustCompile(`^ {0,3}(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+
+	mdImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdStrong   = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	mdEmphasis = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	mdCode     = regexp.MustCompile("`([^`]*)`")
+)
+
+func (p *MarkdownParser) Parse(r io.ReaderAt, size int64) ([]Segment, error) {
+	text, err := readText(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newSegmentBuilder()
+	var para []string
+	endParagraph := func() {
+		b.paragraph(strings.Join(para, "\n"))
+		para = nil
+	}
+
+	fence := ""
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if fence != "" {
+			// Code is kept as it is, and nothing in it is a heading
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+				endParagraph()
+			} else {
+				para = append(para, line)
+			}
+			continue
+		}
+
+		for strings.HasPrefix(line, "\f") {
+			endParagraph()
+			b.pageBreak()
+			line = line[1:]
+		}
+
+		switch {
+		case codeFence.MatchString(line):
+			endParagraph()
+			fence = codeFence.FindStringSubmatch(line)[1]
+		case atxHeading.MatchString(line):
+			endParagraph()
+			m := atxHeading.FindStringSubmatch(line)
+			b.heading(inlineText(m[2]), len(m[1])-1)
+		case len(para) == 0 && strings.TrimSpace(line) != "" && i+1 < len(lines) && setextUnderline.MatchString(lines[i+1]) && !thematicBreak.MatchString(line):
+			depth := 0
+			if strings.Contains(lines[i+1], "-") {
+				depth = 1
+			}
+			b.heading(inlineText(line), depth)
+			i++
+		case thematicBreak.MatchString(line):
+			endParagraph()
+		case strings.TrimSpace(line) == "":
+			endParagraph()
+		default:
+			para = append(para, inlineText(strings.TrimPrefix(strings.TrimLeft(line, " "), "> ")))
+		}
+	}
+	endParagraph()
+
+	return b.finish(), nil
+}
+
+// inlineText strips Markdown inline markup, keeping the text of links and
+// the alt text of images
+func inlineText(s string) string {
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdCode.ReplaceAllString(s, "$1")
+	s = mdStrong.ReplaceAllString(s, "$1$2")
+	s = mdEmphasis.ReplaceAllString(s, "$1")
+	return s
+}
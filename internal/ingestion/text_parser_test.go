@@ -0,0 +1,62 @@
+package ingestion
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextParser_SplitsPagesOnFormFeed(t *testing.T) {
+	text := "\ufeffFirst page.\r\n\fSecond page.\n\f\n\fFourth page."
+	segments, err := NewTextParser().Parse(strings.NewReader(text), int64(len(text)))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{
+		{Page: 1, Text: "First page.\n"},
+		{Page: 2, Text: "Second page.\n"},
+		{Page: 4, Text: "Fourth page."},
+	}, segments)
+}
+
+func TestMarkdownParser_HeadingsPlaceText(t *testing.T) {
+	text := strings.Join([]string{
+		"Notes for class nine",
+		"",
+		"# Chapter 5: Force",
+		"",
+		"A **force** is a push",
+		"or a *pull*; see [the lab](lab.md).",
+		"",
+		"## 5.1 Friction ##",
+		"",
+		"```",
+		"# not a heading",
+		"```",
+		"",
+		"Summary",
+		"-------",
+		"",
+		"> Friction opposes `motion`.",
+	}, "\n")
+
+	segments, err := NewMarkdownParser().Parse(strings.NewReader(text), int64(len(text)))
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{
+		{Page: 1, Text: "Notes for class nine"},
+		{Page: 1, Chapter: 5, Text: "Chapter 5: Force\nA force is a push\nor a pull; see the lab."},
+		{Page: 1, Chapter: 5, Section: "5.1 Friction", Text: "5.1 Friction\n# not a heading"},
+		{Page: 1, Chapter: 5, Section: "Summary", Text: "Summary\nFriction opposes motion."},
+	}, segments)
+}
+
+func TestMarkdownParser_FormFeedStartsPage(t *testing.T) {
+	text := "# অধ্যায় ২\n\nবল।\n\fভর।"
+	segments, err := NewMarkdownParser().Parse(strings.NewReader(text), int64(len(text)))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{
+		{Page: 1, Chapter: 2, Text: "অধ্যায় ২\nবল।"},
+		{Page: 2, Chapter: 2, Text: "ভর।"},
+	}, segments)
+}
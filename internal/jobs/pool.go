@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"backend/internal/domain"
+	"backend/internal/ingestion"
+)
+
+// Processor runs a claimed job, reporting progress as it goes
+type Processor interface {
+	ProcessJob(ctx context.Context, job *domain.IngestionJob, progress ingestion.ProgressFunc) error
+}
+
+// JobFailer is implemented by processors that record, e.g. on the job's
+// document, that a job was given up on without being run
+type JobFailer interface {
+	FailJob(ctx context.Context, job *domain.IngestionJob) error
+}
+
+// Pool runs ingestion jobs from a JobRepository on a fixed number of
+// in-process workers. Each claimed job is leased; the lease is renewed on
+// every progress update and on a heartbeat while the job runs, so if the
+// process dies mid-job the lease runs out and another worker (or this one
+// after a restart) picks the job up again. A job claimed more than
+// MaxAttempts times is marked failed rather than run again.
+type Pool struct {
+	repo      domain.JobRepository
+	processor Processor
+	workers   int
+
+	// PollInterval is how long an idle worker waits before checking the queue again
+	PollInterval time.Duration
+	// Lease is how long a worker may go without renewing its job's lease before the job is considered abandoned
+	Lease time.Duration
+	// Heartbeat is how often a running job's lease is renewed; it should be well
+	// under Lease. Zero leaves renewing the lease to progress updates.
+	Heartbeat time.Duration
+	// MaxAttempts is how many times a job may be claimed, counting runs cut
+	// short by a crash or an expired lease; zero allows any number
+	MaxAttempts int
+
+	wg sync.WaitGroup
+}
+
+func NewPool(repo domain.JobRepository, processor Processor, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		repo:         repo,
+		processor:    processor,
+		workers:      workers,
+		PollInterval: 2 * time.Second,
+		Lease:        2 * time.Minute,
+		Heartbeat:    30 * time.Second,
+		MaxAttempts:  3,
+	}
+}
+
+// Start launches the workers. They stop when ctx is cancelled; jobs they were
+// running are left leased and will be resumed once the lease expires.
+func (p *Pool) Start(ctx context.Context) {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.work(ctx)
+		}()
+	}
+}
+
+// Wait blocks until all workers have stopped
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for {
+		// Drain the queue before going back to sleep
+		for ctx.Err() == nil {
+			if !p.RunOnce(ctx) {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.PollInterval):
+		}
+	}
+}
+
+// RunOnce claims and runs a single job. It reports whether a job was found.
+func (p *Pool) RunOnce(ctx context.Context) bool {
+	job, err := p.repo.ClaimJob(ctx, p.Lease)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("jobs: claim failed: %v", err)
+		}
+		return false
+	}
+
+	if p.MaxAttempts > 0 && job.Attempts > p.MaxAttempts {
+		p.giveUp(ctx, job)
+		return true
+	}
+
+	progress := func(processed, total int) {
+		job.ChunksProcessed = processed
+		job.ChunksTotal = total
+		if err := p.repo.UpdateJobProgress(ctx, job.ID, processed, total, p.Lease); err != nil && ctx.Err() == nil {
+			log.Printf("jobs: recording progress for job %s failed: %v", job.ID, err)
+		}
+	}
+
+	stop := p.heartbeat(ctx, job)
+	err = p.processor.ProcessJob(ctx, job, progress)
+	stop()
+	if ctx.Err() != nil {
+		// Shutting down: keep the job as running so it resumes after the lease expires
+		return true
+	}
+
+	job.Status = domain.JobStatusCompleted
+	job.Error = ""
+	if err != nil {
+		job.Status = domain.JobStatusFailed
+		job.Error = err.Error()
+		log.Printf("jobs: job %s for document %s failed: %v", job.ID, job.DocumentID, err)
+	}
+
+	if err := p.repo.FinishJob(ctx, job); err != nil {
+		log.Printf("jobs: finishing job %s failed: %v", job.ID, err)
+	}
+	return true
+}
+
+// heartbeat renews job's lease every Heartbeat until the returned function is
+// called, so a long parse or embedding batch that reports no progress does not
+// let another worker claim the job while it is still running
+func (p *Pool) heartbeat(ctx context.Context, job *domain.IngestionJob) func() {
+	if p.Heartbeat <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(p.Heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.repo.RenewJobLease(ctx, job.ID, p.Lease); err != nil && ctx.Err() == nil {
+					log.Printf("jobs: renewing lease for job %s failed: %v", job.ID, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// giveUp marks a job that has used up its attempts as failed, without running
+// it, so one that keeps crashing its worker is not claimed forever
+func (p *Pool) giveUp(ctx context.Context, job *domain.IngestionJob) {
+	reason := fmt.Errorf("gave up after %d attempts", p.MaxAttempts)
+	log.Printf("jobs: job %s for document %s: %v", job.ID, job.DocumentID, reason)
+
+	if failer, ok := p.processor.(JobFailer); ok {
+		if err := failer.FailJob(ctx, job); err != nil {
+			log.Printf("jobs: recording failure of job %s failed: %v", job.ID, err)
+		}
+	}
+
+	job.Status = domain.JobStatusFailed
+	job.Error = reason.Error()
+	if err := p.repo.FinishJob(ctx, job); err != nil {
+		log.Printf("jobs: finishing job %s failed: %v", job.ID, err)
+	}
+}
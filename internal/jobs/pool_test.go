@@ -0,0 +1,220 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/domain"
+	"backend/internal/ingestion"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockJobRepo struct {
+	mock.Mock
+}
+
+func (m *MockJobRepo) CreateJob(ctx context.Context, job *domain.IngestionJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepo) GetJob(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
+func (m *MockJobRepo) ActiveJob(ctx context.Context, documentID uuid.UUID) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, documentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
+func (m *MockJobRepo) ClaimJob(ctx context.Context, lease time.Duration) (*domain.IngestionJob, error) {
+	args := m.Called(ctx, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.IngestionJob), args.Error(1)
+}
+
+func (m *MockJobRepo) UpdateJobProgress(ctx context.Context, id uuid.UUID, processed, total int, lease time.Duration) error {
+	args := m.Called(ctx, id, processed, total, lease)
+	return args.Error(0)
+}
+
+func (m *MockJobRepo) RenewJobLease(ctx context.Context, id uuid.UUID, lease time.Duration) error {
+	args := m.Called(ctx, id, lease)
+	return args.Error(0)
+}
+
+func (m *MockJobRepo) FinishJob(ctx context.Context, job *domain.IngestionJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+// fakeProcessor reports two chunks of progress and then returns err
+type fakeProcessor struct {
+	err error
+}
+
+func (p *fakeProcessor) ProcessJob(ctx context.Context, job *domain.IngestionJob, progress ingestion.ProgressFunc) error {
+	progress(1, 2)
+	progress(2, 2)
+	return p.err
+}
+
+func TestRunOnce_Completes(t *testing.T) {
+	repo := new(MockJobRepo)
+	pool := NewPool(repo, &fakeProcessor{}, 1)
+	ctx := context.Background()
+
+	job := &domain.IngestionJob{ID: uuid.New(), DocumentID: uuid.New(), Status: domain.JobStatusRunning}
+	repo.On("ClaimJob", ctx, pool.Lease).Return(job, nil)
+	repo.On("UpdateJobProgress", ctx, job.ID, 1, 2, pool.Lease).Return(nil)
+	repo.On("UpdateJobProgress", ctx, job.ID, 2, 2, pool.Lease).Return(nil)
+	repo.On("FinishJob", ctx, mock.MatchedBy(func(j *domain.IngestionJob) bool {
+		return j.Status == domain.JobStatusCompleted && j.ChunksProcessed == 2 && j.ChunksTotal == 2
+	})).Return(nil)
+
+	assert.True(t, pool.RunOnce(ctx))
+	repo.AssertExpectations(t)
+}
+
+func TestRunOnce_RecordsFailure(t *testing.T) {
+	repo := new(MockJobRepo)
+	pool := NewPool(repo, &fakeProcessor{err: errors.New("embedding failed for chunk 1")}, 1)
+	ctx := context.Background()
+
+	job := &domain.IngestionJob{ID: uuid.New(), Status: domain.JobStatusRunning}
+	repo.On("ClaimJob", ctx, pool.Lease).Return(job, nil)
+	repo.On("UpdateJobProgress", ctx, job.ID, mock.Anything, 2, pool.Lease).Return(nil)
+	repo.On("FinishJob", ctx, mock.MatchedBy(func(j *domain.IngestionJob) bool {
+		return j.Status == domain.JobStatusFailed && j.Error == "embedding failed for chunk 1"
+	})).Return(nil)
+
+	assert.True(t, pool.RunOnce(ctx))
+	repo.AssertExpectations(t)
+}
+
+func TestRunOnce_EmptyQueue(t *testing.T) {
+	repo := new(MockJobRepo)
+	pool := NewPool(repo, &fakeProcessor{}, 1)
+	ctx := context.Background()
+
+	repo.On("ClaimJob", ctx, pool.Lease).Return(nil, domain.ErrNotFound)
+
+	assert.False(t, pool.RunOnce(ctx))
+	repo.AssertNotCalled(t, "FinishJob", mock.Anything, mock.Anything)
+}
+
+func TestRunOnce_ShutdownLeavesJobLeased(t *testing.T) {
+	repo := new(MockJobRepo)
+	ctx, cancel := context.WithCancel(context.Background())
+	processor := &cancellingProcessor{cancel: cancel}
+	pool := NewPool(repo, processor, 1)
+
+	job := &domain.IngestionJob{ID: uuid.New(), Status: domain.JobStatusRunning}
+	repo.On("ClaimJob", ctx, pool.Lease).Return(job, nil)
+
+	assert.True(t, pool.RunOnce(ctx))
+	repo.AssertNotCalled(t, "FinishJob", mock.Anything, mock.Anything)
+}
+
+// cancellingProcessor simulates a shutdown arriving while a job is running
+type cancellingProcessor struct {
+	cancel context.CancelFunc
+}
+
+func (p *cancellingProcessor) ProcessJob(ctx context.Context, job *domain.IngestionJob, progress ingestion.ProgressFunc) error {
+	p.cancel()
+	return ctx.Err()
+}
+
+// slowProcessor runs for a while without reporting any progress
+type slowProcessor struct {
+	d time.Duration
+}
+
+func (p *slowProcessor) ProcessJob(ctx context.Context, job *domain.IngestionJob, progress ingestion.ProgressFunc) error {
+	time.Sleep(p.d)
+	return nil
+}
+
+func TestRunOnce_HeartbeatRenewsLease(t *testing.T) {
+	repo := new(MockJobRepo)
+	pool := NewPool(repo, &slowProcessor{d: 50 * time.Millisecond}, 1)
+	pool.Heartbeat = 5 * time.Millisecond
+	ctx := context.Background()
+
+	job := &domain.IngestionJob{ID: uuid.New(), Status: domain.JobStatusRunning}
+	repo.On("ClaimJob", ctx, pool.Lease).Return(job, nil)
+	repo.On("RenewJobLease", ctx, job.ID, pool.Lease).Return(nil)
+	repo.On("FinishJob", ctx, mock.Anything).Return(nil)
+
+	assert.True(t, pool.RunOnce(ctx))
+	repo.AssertCalled(t, "RenewJobLease", ctx, job.ID, pool.Lease)
+
+	// The heartbeat stops with the job
+	calls := len(repo.Calls)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, repo.Calls, calls)
+}
+
+// failingProcessor records the jobs it is told were given up on
+type failingProcessor struct {
+	failed []*domain.IngestionJob
+}
+
+func (p *failingProcessor) ProcessJob(ctx context.Context, job *domain.IngestionJob, progress ingestion.ProgressFunc) error {
+	panic("a job out of attempts must not run")
+}
+
+func (p *failingProcessor) FailJob(ctx context.Context, job *domain.IngestionJob) error {
+	p.failed = append(p.failed, job)
+	return nil
+}
+
+func TestRunOnce_GivesUpAfterMaxAttempts(t *testing.T) {
+	repo := new(MockJobRepo)
+	processor := &failingProcessor{}
+	pool := NewPool(repo, processor, 1)
+	pool.MaxAttempts = 3
+	ctx := context.Background()
+
+	// Claimed for the fourth time, e.g. after crashing its worker three times
+	job := &domain.IngestionJob{ID: uuid.New(), Status: domain.JobStatusRunning, Attempts: 4}
+	repo.On("ClaimJob", ctx, pool.Lease).Return(job, nil)
+	repo.On("FinishJob", ctx, mock.MatchedBy(func(j *domain.IngestionJob) bool {
+		return j.Status == domain.JobStatusFailed && j.Error == "gave up after 3 attempts"
+	})).Return(nil)
+
+	assert.True(t, pool.RunOnce(ctx))
+	assert.Equal(t, []*domain.IngestionJob{job}, processor.failed)
+	repo.AssertExpectations(t)
+}
+
+func TestPool_StartAndStop(t *testing.T) {
+	repo := new(MockJobRepo)
+	pool := NewPool(repo, &fakeProcessor{}, 2)
+	pool.PollInterval = time.Millisecond
+
+	repo.On("ClaimJob", mock.Anything, pool.Lease).Return(nil, domain.ErrNotFound)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	pool.Wait()
+
+	repo.AssertCalled(t, "ClaimJob", mock.Anything, pool.Lease)
+}
@@ -0,0 +1,87 @@
+// Package llmhttp is the JSON-over-HTTP plumbing shared by the clients for
+// self-hostable model APIs (OpenAI-compatible endpoints and Ollama).
+package llmhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody is how much of an error response is kept in a StatusError
+const maxErrorBody = 4 << 10
+
+// Client posts JSON requests to one API
+type Client struct {
+	BaseURL string
+	// APIKey is sent as a bearer token when set
+	APIKey string
+	HTTP   *http.Client
+}
+
+// New creates a client for the API at baseURL
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		HTTP:    http.DefaultClient,
+	}
+}
+
+// StatusError is returned when the API answers with a non-2xx status
+type StatusError struct {
+	Code    int
+	Message string
+	Header  http.Header
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %d: %s", http.StatusText(e.Code), e.Code, e.Message)
+}
+
+// Post sends body as JSON to path and returns the response, whose body the
+// caller must close. Non-2xx responses are returned as a *StatusError.
+func (c *Client) Post(ctx context.Context, path string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(msg)), Header: resp.Header}
+	}
+	return resp, nil
+}
+
+// PostJSON sends body as JSON to path and decodes the JSON response into out
+func (c *Client) PostJSON(ctx context.Context, path string, body, out any) error {
+	resp, err := c.Post(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// Package migrations keeps the database schema up to date. The migrations are
+// SQL files embedded in the binary, named NNNN_description.up.sql and
+// NNNN_description.down.sql, and applied in version order. Scripts are
+// text/template templates over Vars, for the parts of the schema that follow
+// configuration.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"backend/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockID is the advisory lock held while migrating, so that servers starting
+// at the same time do not apply a migration twice
+const lockID = 7_316_253_401
+
+// Migration is one schema change and how to undo it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied, and when
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in dir of fsys, sorted by version. Every version
+// needs both an up and a down file.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration file %q is not named NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// DefaultDimensions is the size of the embeddings column unless configured
+// otherwise: that of Gemini's text-embedding-004
+const DefaultDimensions = 768
+
+// Vars are the values migration scripts are rendered with
+type Vars struct {
+	VectorIndex repository.VectorIndex
+	// Dimensions is the size of the stored embeddings
+	Dimensions int
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+	// Vars default to the repository defaults
+	Vars Vars
+}
+
+// New creates a migrator for the migrations embedded in the binary
+func New(db *sqlx.DB) (*Migrator, error) {
+	migrations, err := Load(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return NewWithMigrations(db, migrations), nil
+}
+
+// NewWithMigrations creates a migrator for the given migrations, which must be sorted by version
+func NewWithMigrations(db *sqlx.DB, migrations []Migration) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		Vars:       Vars{VectorIndex: repository.DefaultVectorIndex(), Dimensions: DefaultDimensions},
+	}
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the ones it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sqlx.Conn, done map[int]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			script, err := m.render(migration.Up)
+			if err == nil {
+				err = inTx(ctx, conn, script,
+					`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+			}
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and
+// returns the ones it reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sqlx.Conn, done map[int]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			script, err := m.render(migration.Down)
+			if err == nil {
+				err = inTx(ctx, conn, script, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			}
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sqlx.Conn, done map[int]time.Time) error {
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+			if at, ok := done[migration.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked runs fn on one connection while holding the migration lock, passing
+// it the versions applied so far. Advisory locks belong to a session, so the
+// lock, the migrations and the unlock must all use the same connection.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sqlx.Conn, done map[int]time.Time) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("connect failed: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock failed: %w", err)
+	}
+	// Unlock even if ctx was cancelled mid-migration
+	defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text        NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations failed: %w", err)
+	}
+
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := conn.SelectContext(ctx, &rows, `SELECT version, applied_at FROM schema_migrations`); err != nil {
+		return fmt.Errorf("list applied migrations failed: %w", err)
+	}
+	done := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		done[r.Version] = r.AppliedAt
+	}
+
+	return fn(conn, done)
+}
+
+// render fills in a script's placeholders
+func (m *Migrator) render(script string) (string, error) {
+	tmpl, err := template.New("migration").Option("missingkey=error").Parse(script)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, m.Vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// inTx runs script and then the bookkeeping statement in one transaction
+func inTx(ctx context.Context, conn *sqlx.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
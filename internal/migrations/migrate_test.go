@@ -0,0 +1,184 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"backend/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "first", Up: "CREATE TABLE a (id int)", Down: "DROP TABLE a"},
+	{Version: 2, Name: "second", Up: "CREATE TABLE b (id int)", Down: "DROP TABLE b"},
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id int)")},
+		"sql/0002_second.down.sql": {Data: []byte("DROP TABLE b")},
+		"sql/0001_first.up.sql":    {Data: []byte("CREATE TABLE a (id int)")},
+		"sql/0001_first.down.sql":  {Data: []byte("DROP TABLE a")},
+	}
+	migrations, err := Load(fsys, "sql")
+	assert.NoError(t, err)
+	assert.Equal(t, testMigrations, migrations)
+
+	_, err = Load(fstest.MapFS{"sql/0001_first.up.sql": {Data: []byte("x")}}, "sql")
+	assert.ErrorContains(t, err, "needs both an up and a down file")
+
+	_, err = Load(fstest.MapFS{"sql/first.sql": {Data: []byte("x")}}, "sql")
+	assert.ErrorContains(t, err, "is not named")
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load(files, "sql")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "migration versions must have no gaps")
+	}
+}
+
+// expectLocked sets up the statements every operation runs before its own work
+func expectLocked(mock sqlmock.Sqlmock, applied ...int) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WithArgs(lockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "applied_at"})
+	for _, v := range applied {
+		rows.AddRow(v, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version, applied_at FROM schema_migrations`)).WillReturnRows(rows)
+}
+
+func expectUnlocked(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WithArgs(lockID).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestUp_AppliesPendingMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	m := NewWithMigrations(sqlx.NewDb(db, "postgres"), testMigrations)
+
+	expectLocked(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE b (id int)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`)).
+		WithArgs(2, "second").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlocked(mock)
+
+	applied, err := m.Up(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, testMigrations[1:], applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_StopsAtFailingMigration(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	m := NewWithMigrations(sqlx.NewDb(db, "postgres"), testMigrations)
+
+	expectLocked(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE a (id int)`)).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	expectUnlocked(mock)
+
+	applied, err := m.Up(context.Background())
+	assert.ErrorContains(t, err, "migration 1_first failed: syntax error")
+	assert.Empty(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown_RevertsNewestFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	m := NewWithMigrations(sqlx.NewDb(db, "postgres"), testMigrations)
+
+	expectLocked(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE b`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM schema_migrations WHERE version = $1`)).
+		WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlocked(mock)
+
+	reverted, err := m.Down(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, testMigrations[1:], reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	m := NewWithMigrations(sqlx.NewDb(db, "postgres"), testMigrations)
+
+	expectLocked(mock, 1)
+	expectUnlocked(mock)
+
+	statuses, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.Nil(t, statuses[1].AppliedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_RendersDimensions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	migrations, err := Load(files, "sql")
+	assert.NoError(t, err)
+	m := NewWithMigrations(sqlx.NewDb(db, "postgres"), migrations[:1])
+	assert.Equal(t, DefaultDimensions, m.Vars.Dimensions)
+	m.Vars.Dimensions = 1536
+
+	expectLocked(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`embedding\s+vector\(1536\) NOT NULL`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`)).
+		WithArgs(1, "initial_schema").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlocked(mock)
+
+	_, err = m.Up(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUp_RendersVars(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	migrations, err := Load(files, "sql")
+	assert.NoError(t, err)
+	m := NewWithMigrations(sqlx.NewDb(db, "postgres"), migrations[:2])
+	m.Vars.VectorIndex = repository.VectorIndex{Method: "ivfflat", Lists: 200}
+
+	expectLocked(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX embeddings_embedding_idx ON embeddings USING ivfflat (embedding vector_cosine_ops) WITH (lists = 200);`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`)).
+		WithArgs(2, "vector_index").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlocked(mock)
+
+	_, err = m.Up(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
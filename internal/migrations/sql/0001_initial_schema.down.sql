@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS questions;
+DROP FUNCTION IF EXISTS question_document(text, text[], text);
+DROP TABLE IF EXISTS ingestion_jobs;
+DROP TABLE IF EXISTS embeddings;
+DROP TABLE IF EXISTS document_files;
+DROP TABLE IF EXISTS documents;
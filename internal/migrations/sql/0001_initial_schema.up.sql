@@ -0,0 +1,92 @@
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE documents (
+    id           uuid PRIMARY KEY,
+    filename     text        NOT NULL,
+    content_hash text        NOT NULL DEFAULT '',
+    subject      text        NOT NULL,
+    chapter      integer     NOT NULL DEFAULT 0,
+    language     text        NOT NULL,
+    page_count   integer     NOT NULL DEFAULT 0,
+    chunk_count  integer     NOT NULL DEFAULT 0,
+    status       text        NOT NULL,
+    user_id      text        NOT NULL DEFAULT '',
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    updated_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX documents_content_hash_idx ON documents (content_hash);
+CREATE INDEX documents_filename_idx ON documents (filename);
+CREATE INDEX documents_created_at_idx ON documents (created_at DESC);
+
+CREATE TABLE document_files (
+    document_id uuid PRIMARY KEY REFERENCES documents (id) ON DELETE CASCADE,
+    content     bytea NOT NULL
+);
+
+-- Embeddings have EMBEDDING_DIMENSIONS dimensions, or 768, the size of
+-- text-embedding-004's, when that is not set
+CREATE TABLE embeddings (
+    id           uuid PRIMARY KEY,
+    document_id  uuid        NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    chunk_index  integer     NOT NULL,
+    content_hash text,
+    subject      text        NOT NULL,
+    chapter      integer     NOT NULL DEFAULT 0,
+    content      text        NOT NULL,
+    embedding    vector({{.Dimensions}}) NOT NULL,
+    language     text        NOT NULL,
+    page         integer     NOT NULL DEFAULT 0,
+    page_end     integer     NOT NULL DEFAULT 0,
+    created_at   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX embeddings_document_id_idx ON embeddings (document_id, content_hash);
+CREATE INDEX embeddings_subject_chapter_idx ON embeddings (subject, chapter);
+
+CREATE TABLE ingestion_jobs (
+    id               uuid PRIMARY KEY,
+    document_id      uuid        NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    status           text        NOT NULL,
+    chunks_processed integer     NOT NULL DEFAULT 0,
+    chunks_total     integer     NOT NULL DEFAULT 0,
+    error            text        NOT NULL DEFAULT '',
+    attempts         integer     NOT NULL DEFAULT 0,
+    locked_until     timestamptz,
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    updated_at       timestamptz NOT NULL DEFAULT now()
+);
+
+-- Workers claim the oldest runnable job
+CREATE INDEX ingestion_jobs_runnable_idx ON ingestion_jobs (created_at) WHERE status IN ('queued', 'running');
+
+CREATE TABLE questions (
+    id               uuid PRIMARY KEY,
+    type             text        NOT NULL,
+    stem             text        NOT NULL,
+    options          text[]      NOT NULL DEFAULT '{}',
+    answer           text        NOT NULL,
+    explanation      text        NOT NULL DEFAULT '',
+    difficulty       text        NOT NULL,
+    marks            integer     NOT NULL,
+    source_chunk_ids uuid[]      NOT NULL DEFAULT '{}',
+    subject          text        NOT NULL DEFAULT '',
+    chapter          integer     NOT NULL DEFAULT 0,
+    topic            text        NOT NULL DEFAULT '',
+    language         text        NOT NULL,
+    user_id          text        NOT NULL DEFAULT '',
+    created_at       timestamptz NOT NULL DEFAULT now(),
+    updated_at       timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX questions_created_at_idx ON questions (created_at DESC);
+CREATE INDEX questions_subject_chapter_idx ON questions (subject, chapter);
+
+-- The text searched by the question bank. array_to_string is only STABLE, so
+-- it is wrapped in an IMMUTABLE function that the index can use; the
+-- 'simple' configuration does no stemming and suits Bengali and English alike.
+CREATE FUNCTION question_document(stem text, options text[], explanation text) RETURNS tsvector
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT to_tsvector('simple', stem || ' ' || array_to_string(options, ' ') || ' ' || explanation) $$;
+
+CREATE INDEX questions_search_idx ON questions USING gin (question_document(stem, options, explanation));
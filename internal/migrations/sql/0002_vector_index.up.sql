@@ -0,0 +1,5 @@
+-- Approximate nearest neighbour index for similarity search. Its method and
+-- parameters come from configuration (VECTOR_INDEX, HNSW_M,
+-- HNSW_EF_CONSTRUCTION, IVFFLAT_LISTS); "cli index rebuild" applies later
+-- changes to an existing database.
+{{.VectorIndex.CreateSQL}};
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS embeddings_content_tsv_idx;
+ALTER TABLE embeddings DROP COLUMN IF EXISTS content_tsv;
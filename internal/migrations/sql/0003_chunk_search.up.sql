@@ -0,0 +1,7 @@
+-- Full-text search over chunk content for keyword and hybrid retrieval. The
+-- 'simple' configuration lowercases words without stemming them, so it works
+-- for Bengali as well as English.
+ALTER TABLE embeddings
+    ADD COLUMN content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX embeddings_content_tsv_idx ON embeddings USING gin (content_tsv);
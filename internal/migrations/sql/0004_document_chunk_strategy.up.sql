@@ -0,0 +1,2 @@
+-- How each document is chunked; empty means the server's default
+ALTER TABLE documents ADD COLUMN chunk_strategy text NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Title of the section each chunk is from, detected at ingestion
+ALTER TABLE embeddings ADD COLUMN section text NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Rebuild jobs embed every chunk again instead of only the new ones
+ALTER TABLE ingestion_jobs ADD COLUMN rebuild boolean NOT NULL DEFAULT false;
@@ -0,0 +1,185 @@
+// Package provider creates the generation and embedding clients for the model
+// provider chosen in configuration.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"backend/internal/embedding"
+	"backend/internal/generation"
+)
+
+// GenerationClient matches the text generation clients of every provider.
+// Clients that can stream also implement GenerateStream.
+type GenerationClient interface {
+	GenerateContent(ctx context.Context, prompt string) (string, error)
+}
+
+// Embedder matches the embedding clients of every provider. Clients that can
+// embed several texts per call also implement EmbedBatch.
+type Embedder interface {
+	EmbedContent(ctx context.Context, text string) ([]float32, error)
+}
+
+// Settings configure one client
+type Settings struct {
+	// Name is the registered provider, e.g. "gemini", "openai", "ollama" or "fake"
+	Name    string
+	BaseURL string
+	APIKey  string
+	// Model falls back to the provider's default where it has one
+	Model           string
+	Temperature     *float32
+	TopP            *float32
+	MaxOutputTokens int32
+	// Dimensions asks embedding models that support it for shorter embeddings
+	Dimensions int
+	// Fixture is a file the fake provider answers every prompt with
+	Fixture string
+}
+
+// Provider creates clients for one model API. Either constructor may be nil
+// if the API offers no such model.
+type Provider struct {
+	NewGenerator func(ctx context.Context, s Settings) (GenerationClient, error)
+	NewEmbedder  func(ctx context.Context, s Settings) (Embedder, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{}
+)
+
+// Register makes p available under name, replacing any provider registered before
+func Register(name string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[name] = p
+}
+
+// Names lists the registered providers in alphabetical order
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func lookup(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	p, ok := providers[name]
+	if !ok {
+		return Provider{}, fmt.Errorf("unknown provider %q", name)
+	}
+	return p, nil
+}
+
+// NewGenerator creates the generation client described by s
+func NewGenerator(ctx context.Context, s Settings) (GenerationClient, error) {
+	p, err := lookup(s.Name)
+	if err != nil {
+		return nil, err
+	}
+	if p.NewGenerator == nil {
+		return nil, fmt.Errorf("provider %q does not support generation", s.Name)
+	}
+
+	client, err := p.NewGenerator(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s generation client: %w", s.Name, err)
+	}
+	return client, nil
+}
+
+// NewEmbedder creates the embedding client described by s
+func NewEmbedder(ctx context.Context, s Settings) (Embedder, error) {
+	p, err := lookup(s.Name)
+	if err != nil {
+		return nil, err
+	}
+	if p.NewEmbedder == nil {
+		return nil, fmt.Errorf("provider %q does not support embeddings", s.Name)
+	}
+
+	client, err := p.NewEmbedder(ctx, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s embedding client: %w", s.Name, err)
+	}
+	return client, nil
+}
+
+// Default base URLs for the providers that talk plain HTTP
+const (
+	DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+	DefaultOllamaBaseURL = "http://localhost:11434"
+)
+
+func init() {
+	Register("gemini", Provider{
+		NewGenerator: func(ctx context.Context, s Settings) (GenerationClient, error) {
+			return generation.NewGeminiClient(ctx, s.APIKey, s.generationOptions())
+		},
+		NewEmbedder: func(ctx context.Context, s Settings) (Embedder, error) {
+			return embedding.NewGeminiClient(ctx, s.APIKey, s.Model)
+		},
+	})
+	Register("openai", Provider{
+		NewGenerator: func(ctx context.Context, s Settings) (GenerationClient, error) {
+			return generation.NewOpenAIClient(s.baseURL(DefaultOpenAIBaseURL), s.APIKey, s.generationOptions())
+		},
+		NewEmbedder: func(ctx context.Context, s Settings) (Embedder, error) {
+			return embedding.NewOpenAIClient(s.baseURL(DefaultOpenAIBaseURL), s.APIKey, s.Model, s.Dimensions)
+		},
+	})
+	Register("ollama", Provider{
+		NewGenerator: func(ctx context.Context, s Settings) (GenerationClient, error) {
+			return generation.NewOllamaClient(s.baseURL(DefaultOllamaBaseURL), s.generationOptions())
+		},
+		NewEmbedder: func(ctx context.Context, s Settings) (Embedder, error) {
+			return embedding.NewOllamaClient(s.baseURL(DefaultOllamaBaseURL), s.Model)
+		},
+	})
+	// Offline and deterministic, for tests and local development
+	Register("fake", Provider{
+		NewGenerator: func(ctx context.Context, s Settings) (GenerationClient, error) {
+			if s.Fixture == "" {
+				return generation.NewScriptedClient(""), nil
+			}
+			fixture, err := os.ReadFile(s.Fixture)
+			if err != nil {
+				return nil, err
+			}
+			return generation.NewScriptedClient(string(fixture)), nil
+		},
+		NewEmbedder: func(ctx context.Context, s Settings) (Embedder, error) {
+			return embedding.NewHashingEmbedder(s.Dimensions), nil
+		},
+	})
+}
+
+func (s Settings) baseURL(fallback string) string {
+	if s.BaseURL == "" {
+		return fallback
+	}
+	return s.BaseURL
+}
+
+func (s Settings) generationOptions() generation.Options {
+	return generation.Options{
+		Model:           s.Model,
+		Temperature:     s.Temperature,
+		TopP:            s.TopP,
+		MaxOutputTokens: s.MaxOutputTokens,
+	}
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"backend/internal/embedding"
+	"backend/internal/generation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNames(t *testing.T) {
+	assert.Subset(t, Names(), []string{"fake", "gemini", "ollama", "openai"})
+}
+
+func TestNewGenerator_OpenAICompatible(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices": [{"message": {"content": "ok"}}]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewGenerator(context.Background(), Settings{Name: "openai", BaseURL: server.URL, Model: "local-model"})
+	assert.NoError(t, err)
+	assert.IsType(t, &generation.OpenAIClient{}, client)
+
+	text, err := client.GenerateContent(context.Background(), "prompt")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", text)
+}
+
+func TestNewEmbedder_Ollama(t *testing.T) {
+	client, err := NewEmbedder(context.Background(), Settings{Name: "ollama", Model: "nomic-embed-text"})
+	assert.NoError(t, err)
+	assert.IsType(t, &embedding.OllamaClient{}, client)
+}
+
+func TestNewGenerator_Errors(t *testing.T) {
+	_, err := NewGenerator(context.Background(), Settings{Name: "nope"})
+	assert.EqualError(t, err, `unknown provider "nope"`)
+
+	_, err = NewGenerator(context.Background(), Settings{Name: "ollama"})
+	assert.EqualError(t, err, "ollama generation client: model is required")
+
+	Register("embed-only", Provider{
+		NewEmbedder: func(ctx context.Context, s Settings) (Embedder, error) { return nil, nil },
+	})
+	_, err = NewGenerator(context.Background(), Settings{Name: "embed-only"})
+	assert.EqualError(t, err, `provider "embed-only" does not support generation`)
+}
+
+func TestFake(t *testing.T) {
+	embedder, err := NewEmbedder(context.Background(), Settings{Name: "fake", Dimensions: 32})
+	assert.NoError(t, err)
+	vector, err := embedder.EmbedContent(context.Background(), "force")
+	assert.NoError(t, err)
+	assert.Len(t, vector, 32)
+
+	fixture := filepath.Join(t.TempDir(), "questions.json")
+	assert.NoError(t, os.WriteFile(fixture, []byte(`{"questions": []}`), 0o644))
+	generator, err := NewGenerator(context.Background(), Settings{Name: "fake", Fixture: fixture})
+	assert.NoError(t, err)
+	text, err := generator.GenerateContent(context.Background(), "prompt")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"questions": []}`, text)
+
+	_, err = NewGenerator(context.Background(), Settings{Name: "fake", Fixture: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
@@ -1,93 +1,551 @@
 package rag
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"maps"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"backend/internal/domain"
+	"backend/internal/tokenizer"
+
+	"github.com/google/uuid"
 )
 
 type GenerationClient interface {
 	GenerateContent(ctx context.Context, prompt string) (string, error)
 }
 
+// StreamingClient is implemented by generation clients that can hand over the
+// response in pieces as the model writes it
+type StreamingClient interface {
+	GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error
+}
+
 type RetrieverInterface interface {
-	Retrieve(ctx context.Context, query string, limit int, filter map[string]interface{}) ([]*domain.DocumentChunk, error)
+	Retrieve(ctx context.Context, query string, q domain.SearchQuery) ([]*domain.DocumentChunk, error)
 }
 
 type GeneratorService struct {
 	client    GenerationClient
 	retriever RetrieverInterface
+	questions domain.QuestionRepository
+
+	// ContextTokens is the token budget for the context in a prompt; the best
+	// ranked chunks that fit in it are used. Zero uses every chunk retrieved.
+	ContextTokens int
+	// Tokenizer estimates how many tokens each chunk costs
+	Tokenizer tokenizer.Tokenizer
+	// TokenCounter, when set, counts the assembled context exactly so the
+	// estimates can be corrected. Every prompt then costs one extra request.
+	TokenCounter tokenizer.Counter
 }
 
-func NewGeneratorService(client GenerationClient, retriever RetrieverInterface) *GeneratorService {
+func NewGeneratorService(client GenerationClient, retriever RetrieverInterface, questions domain.QuestionRepository) *GeneratorService {
 	return &GeneratorService{
 		client:    client,
 		retriever: retriever,
+		questions: questions,
+
+		ContextTokens: DefaultContextTokens,
+		Tokenizer:     tokenizer.Approximate{},
 	}
 }
 
-func (s *GeneratorService) GenerateQuestions(ctx context.Context, topic string, chapter, count int, language string) ([]string, error) {
+// DefaultContextTokens leaves most of a small model's context window for the
+// instructions and the questions it writes
+const DefaultContextTokens = 6000
+
+// defaultContextCandidates is how many chunks are retrieved, to be fitted into
+// the token budget, when the query does not set a limit
+const defaultContextCandidates = 50
+
+// GenerateQuestions writes the questions asked for by req.Mix on req.Topic in
+// req.Language, grounded in the chunks selected by req.Query, and saves them to
+// the question bank. The language is also applied as a retrieval filter unless
+// the query already restricts languages.
+//
+// Every question the model returns is validated against the rules for its
+// type; malformed questions, and questions beyond what the mix asked for, are
+// dropped. It is an error if none are left.
+func (s *GeneratorService) GenerateQuestions(ctx context.Context, req domain.GenerationRequest) ([]*domain.Question, error) {
+	prompt, sources, err := s.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Generate
+	resp, err := s.client.GenerateContent(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("generation failed: %w", err)
+	}
+
+	// 5. Parse and validate
+	questions, err := parseQuestions(resp, req.Mix, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	// 6. Save to the question bank
+	now := time.Now()
+	for _, question := range questions {
+		stamp(question, req, now)
+	}
+	if err := s.questions.SaveQuestions(ctx, questions); err != nil {
+		return nil, fmt.Errorf("saving questions failed: %w", err)
+	}
+	return questions, nil
+}
+
+// GenerateQuestionsStream is GenerateQuestions for clients that want each
+// question as soon as it is ready. The model's response is streamed when the
+// client supports it, and every question that passes validation is saved to
+// the question bank and then passed to onQuestion with the progress so far.
+//
+// An error from onQuestion, or cancellation of ctx, stops generation; the
+// questions already passed on stay saved. The final progress is returned.
+func (s *GeneratorService) GenerateQuestionsStream(ctx context.Context, req domain.GenerationRequest, onQuestion func(*domain.Question, domain.GenerationProgress) error) (domain.GenerationProgress, error) {
+	progress := domain.GenerationProgress{Requested: req.Mix.Total()}
+	prompt, sources, err := s.prepare(ctx, req)
+	if err != nil {
+		return progress, err
+	}
+
+	c := newCollector(req.Mix, sources)
+	var scanner objectScanner
+	onChunk := func(text string) error {
+		for _, raw := range scanner.feed(text) {
+			question, ok := c.accept(raw)
+			progress.Rejected = c.rejected
+			if !ok {
+				continue
+			}
+
+			stamp(question, req, time.Now())
+			if err := s.questions.SaveQuestions(ctx, []*domain.Question{question}); err != nil {
+				return fmt.Errorf("saving questions failed: %w", err)
+			}
+			progress.Generated++
+			if err := onQuestion(question, progress); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if streaming, ok := s.client.(StreamingClient); ok {
+		err = streaming.GenerateStream(ctx, prompt, onChunk)
+	} else {
+		var resp string
+		if resp, err = s.client.GenerateContent(ctx, prompt); err == nil {
+			err = onChunk(resp)
+		}
+	}
+	if err != nil {
+		return progress, fmt.Errorf("generation failed: %w", err)
+	}
+
+	if progress.Generated == 0 {
+		return progress, fmt.Errorf("generation failed: none of the %d generated questions were valid", c.seen)
+	}
+	return progress, nil
+}
+
+// prepare retrieves the context for req and builds the generation prompt. The
+// returned sources map each chunk label used in the prompt to its chunk.
+func (s *GeneratorService) prepare(ctx context.Context, req domain.GenerationRequest) (string, map[string]*domain.DocumentChunk, error) {
+	topic, language, mix, q := req.Topic, req.Language, req.Mix, req.Query
+	if err := mix.Validate(); err != nil {
+		return "", nil, err
+	}
+
 	// 1. Retrieve Context
-	filter := map[string]interface{}{
-		"chapter":  chapter,
-		"language": language,
+	if len(q.Languages) == 0 {
+		q.Languages = []string{language}
+	}
+	if q.Limit == 0 {
+		// Retrieve more chunks than are likely to fit, so the budget is filled
+		q.Limit = defaultContextCandidates
 	}
-	// Retrieve ample context chunks, e.g., 20
-	chunks, err := s.retriever.Retrieve(ctx, topic, 20, filter)
+
+	chunks, err := s.retriever.Retrieve(ctx, topic, q)
 	if err != nil {
-		return nil, fmt.Errorf("retrieval failed: %w", err)
+		return "", nil, fmt.Errorf("retrieval failed: %w", err)
 	}
 
+	// Generating from nothing, or from weakly related chunks the retriever
+	// dropped, would only produce made-up questions
 	if len(chunks) == 0 {
-		return nil, fmt.Errorf("no context found for topic %s in chapter %d", topic, chapter)
+		return "", nil, fmt.Errorf("%w: no context relevant to topic %s found in chapters %v", domain.ErrInsufficientContext, topic, q.Chapters)
 	}
 
-	// 2. Build Context String
-	var sb strings.Builder
-	for _, c := range chunks {
-		sb.WriteString(c.Content)
-		sb.WriteString("\n---\n")
-	}
+	// 2. Build Context String from the chunks that fit in the token budget
+	chunks = s.fitContext(ctx, chunks)
+	contextText, sources := buildContext(chunks)
 
 	// 3. Construct Prompt
 	prompt := fmt.Sprintf(`
-You are a physics examiner. Generate %d exam-style questions on the topic "%s".
+You are a physics examiner. Generate exam-style questions on the topic "%s".
 Use ONLY the following context to generate the questions.
 Language: %s.
 
-Context:
+Generate exactly:
+%s
+Context (each chunk starts with its ID in square brackets):
 %s
 
-Output STRICT JSON and nothing else:
+Output STRICT JSON and nothing else, in this shape:
 {
-  "questions": ["Question 1", "Question 2"]
+  "questions": [
+    {
+      "type": "mcq" | "short_answer" | "numerical" | "true_false",
+      "stem": "The question text",
+      "options": ["Option A", "Option B", "Option C", "Option D"],
+      "answer": "The correct answer",
+      "explanation": "Why the answer is correct",
+      "difficulty": "easy" | "medium" | "hard",
+      "marks": 1,
+      "sources": ["C1"]
+    }
+  ]
 }
-`, count, topic, language, sb.String())
 
-	// 4. Generate
-	resp, err := s.client.GenerateContent(ctx, prompt)
+Rules:
+- "options" is required for mcq (3 to 6 distinct options) and must be omitted for every other type.
+- For mcq, "answer" must repeat the text of the correct option exactly.
+- For true_false, "answer" must be "true" or "false".
+- For numerical, "answer" must be a plain number using the digits 0-9, without units.
+- "marks" must be a positive integer.
+- "sources" lists the IDs of the context chunks the question and its answer are based on. Cite at least one, and only IDs that appear above.
+`, topic, language, describeMix(mix), contextText)
+	return prompt, sources, nil
+}
+
+// fitContext returns the chunks, best ranked first, that fit in ContextTokens
+// by the tokenizer's estimate. The best chunk is kept even if it alone is over
+// budget. With a TokenCounter the chosen context is counted exactly, and if
+// the estimate was off the chunks are chosen again with every estimate scaled
+// by the same factor, which corrects for a tokenizer splitting the text more
+// or less finely than the model does.
+func (s *GeneratorService) fitContext(ctx context.Context, chunks []*domain.DocumentChunk) []*domain.DocumentChunk {
+	if s.ContextTokens <= 0 {
+		return chunks
+	}
+
+	costs := make([]int, len(chunks))
+	for i, c := range chunks {
+		costs[i] = s.Tokenizer.Count(contextBlock(fmt.Sprintf("C%d", i+1), c))
+	}
+
+	fit := func(scale float64) ([]*domain.DocumentChunk, int) {
+		var picked []*domain.DocumentChunk
+		used := 0
+		for i, c := range chunks {
+			cost := int(math.Ceil(float64(costs[i]) * scale))
+			if len(picked) > 0 && used+cost > s.ContextTokens {
+				// A smaller chunk further down may still fit
+				continue
+			}
+			picked = append(picked, c)
+			used += cost
+		}
+		return picked, used
+	}
+
+	picked, estimate := fit(1)
+	if s.TokenCounter == nil || estimate == 0 {
+		return picked
+	}
+
+	contextText, _ := buildContext(picked)
+	exact, err := s.TokenCounter.CountTokens(ctx, contextText)
 	if err != nil {
-		return nil, fmt.Errorf("generation failed: %w", err)
+		log.Printf("rag: counting context tokens failed, keeping the estimate: %v", err)
+		return picked
+	}
+	if exact == estimate {
+		return picked
+	}
+	picked, _ = fit(float64(exact) / float64(estimate))
+	return picked
+}
+
+// buildContext labels each chunk so the model can cite it. The returned
+// sources map each label to its chunk.
+func buildContext(chunks []*domain.DocumentChunk) (string, map[string]*domain.DocumentChunk) {
+	var sb strings.Builder
+	sources := make(map[string]*domain.DocumentChunk, len(chunks))
+	for i, c := range chunks {
+		label := fmt.Sprintf("C%d", i+1)
+		sources[label] = c
+		sb.WriteString(contextBlock(label, c))
+	}
+	return sb.String(), sources
+}
+
+func contextBlock(label string, c *domain.DocumentChunk) string {
+	return fmt.Sprintf("[%s]\n%s\n---\n", label, c.Content)
+}
+
+// stamp fills in where a generated question came from and who asked for it
+func stamp(question *domain.Question, req domain.GenerationRequest, now time.Time) {
+	question.ID = uuid.New()
+	question.Subject, question.Chapter = commonSource(question.Citations)
+	question.Topic = req.Topic
+	question.Language = req.Language
+	question.UserID = req.UserID
+	question.CreatedAt = now
+	question.UpdatedAt = now
+}
+
+func (s *GeneratorService) ListQuestions(ctx context.Context, filter domain.QuestionFilter) ([]*domain.Question, error) {
+	return s.questions.ListQuestions(ctx, filter)
+}
+
+func (s *GeneratorService) GetQuestion(ctx context.Context, id uuid.UUID) (*domain.Question, error) {
+	return s.questions.GetQuestion(ctx, id)
+}
+
+// UpdateQuestion replaces the content of a stored question (type, stem,
+// options, answer, explanation, difficulty and marks) with edit's, after
+// validating the result. Where the question came from is kept.
+func (s *GeneratorService) UpdateQuestion(ctx context.Context, id uuid.UUID, edit domain.Question) (*domain.Question, error) {
+	question, err := s.questions.GetQuestion(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	// 5. Parse JSON
-	cleaned := cleanJSON(resp)
+	question.Type = edit.Type
+	question.Stem = edit.Stem
+	question.Options = edit.Options
+	question.Answer = edit.Answer
+	question.Explanation = edit.Explanation
+	question.Difficulty = edit.Difficulty
+	question.Marks = edit.Marks
+	if err := question.Validate(); err != nil {
+		return nil, err
+	}
+
+	question.UpdatedAt = time.Now()
+	if err := s.questions.UpdateQuestion(ctx, question); err != nil {
+		return nil, err
+	}
+	return question, nil
+}
+
+func (s *GeneratorService) DeleteQuestion(ctx context.Context, id uuid.UUID) error {
+	return s.questions.DeleteQuestion(ctx, id)
+}
+
+// commonSource returns the subject and chapter shared by all citations,
+// leaving either empty when the citations disagree on it
+func commonSource(citations []domain.Citation) (string, int) {
+	subject, chapter := citations[0].Subject, citations[0].Chapter
+	for _, c := range citations[1:] {
+		if c.Subject != subject {
+			subject = ""
+		}
+		if c.Chapter != chapter {
+			chapter = 0
+		}
+	}
+	return subject, chapter
+}
 
+// describeMix lists the requested number of questions per type, one per line
+func describeMix(mix domain.QuestionMix) string {
+	var sb strings.Builder
+	for _, t := range domain.QuestionTypes {
+		if n := mix[t]; n > 0 {
+			fmt.Fprintf(&sb, "- %d %s question(s)\n", n, t)
+		}
+	}
+	return sb.String()
+}
+
+// generatedQuestion is a question as the model writes it. The answer is kept
+// raw because models write numerical and true/false answers as JSON numbers
+// and booleans as often as strings.
+type generatedQuestion struct {
+	Type        domain.QuestionType `json:"type"`
+	Stem        string              `json:"stem"`
+	Options     []string            `json:"options"`
+	Answer      json.RawMessage     `json:"answer"`
+	Explanation string              `json:"explanation"`
+	Difficulty  domain.Difficulty   `json:"difficulty"`
+	Marks       int                 `json:"marks"`
+	Sources     []string            `json:"sources"`
+}
+
+// parseQuestions decodes the model's response, keeping the questions that
+// decode strictly, pass validation, cite only chunks in sources and fit in
+// mix. The kept questions have their source chunk IDs and citations set.
+func parseQuestions(resp string, mix domain.QuestionMix, sources map[string]*domain.DocumentChunk) ([]*domain.Question, error) {
 	var result struct {
-		Questions []string `json:"questions"`
+		Questions []json.RawMessage `json:"questions"`
 	}
-	if err := json.Unmarshal([]byte(cleaned), &result); err != nil {
+	if err := json.Unmarshal([]byte(cleanJSON(resp)), &result); err != nil {
 		return nil, fmt.Errorf("parsing response failed: %w. Response: %s", err, resp)
 	}
 
-	if len(result.Questions) > count {
-		result.Questions = result.Questions[:count]
+	c := newCollector(mix, sources)
+	questions := make([]*domain.Question, 0, len(result.Questions))
+	for _, raw := range result.Questions {
+		if q, ok := c.accept(raw); ok {
+			questions = append(questions, q)
+		}
+	}
+
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("generation failed: none of the %d generated questions were valid", len(result.Questions))
 	}
+	return questions, nil
+}
+
+// collector checks generated questions one at a time against the mix,
+// counting down what is left of it
+type collector struct {
+	remaining domain.QuestionMix
+	sources   map[string]*domain.DocumentChunk
+	seen      int
+	rejected  int
+}
+
+func newCollector(mix domain.QuestionMix, sources map[string]*domain.DocumentChunk) *collector {
+	return &collector{remaining: maps.Clone(mix), sources: sources}
+}
+
+// accept decodes and validates one generated question, reporting whether it
+// is kept. Rejected questions are logged.
+func (c *collector) accept(raw json.RawMessage) (*domain.Question, bool) {
+	i := c.seen
+	c.seen++
 
-	return result.Questions, nil
+	q, err := decodeQuestion(raw, c.sources)
+	if err == nil {
+		err = q.Validate()
+	}
+	if err == nil && c.remaining[q.Type] == 0 {
+		err = fmt.Errorf("%w: no more %s questions were requested", domain.ErrInvalidQuestion, q.Type)
+	}
+	if err != nil {
+		log.Printf("rag: rejected generated question %d: %v", i, err)
+		c.rejected++
+		return nil, false
+	}
+
+	c.remaining[q.Type]--
+	return &q, true
+}
+
+func decodeQuestion(raw json.RawMessage, sources map[string]*domain.DocumentChunk) (domain.Question, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	var g generatedQuestion
+	if err := dec.Decode(&g); err != nil {
+		return domain.Question{}, fmt.Errorf("%w: %v", domain.ErrInvalidQuestion, err)
+	}
+
+	answer, err := answerText(g.Answer)
+	if err != nil {
+		return domain.Question{}, err
+	}
+	if g.Type == domain.QuestionTypeTrueFalse {
+		answer = strings.ToLower(answer)
+	}
+
+	ids, citations, err := cite(g.Sources, sources)
+	if err != nil {
+		return domain.Question{}, err
+	}
+
+	return domain.Question{
+		Type:           g.Type,
+		Stem:           strings.TrimSpace(g.Stem),
+		Options:        g.Options,
+		Answer:         answer,
+		Explanation:    strings.TrimSpace(g.Explanation),
+		Difficulty:     g.Difficulty,
+		Marks:          g.Marks,
+		SourceChunkIDs: ids,
+		Citations:      citations,
+	}, nil
+}
+
+// snippetLength is how many characters of a cited chunk are quoted in its citation
+const snippetLength = 200
+
+// cite resolves the chunk labels a question cites. Citing nothing, or a label
+// that was not in the prompt, makes the question invalid.
+func cite(labels []string, sources map[string]*domain.DocumentChunk) ([]uuid.UUID, []domain.Citation, error) {
+	if len(labels) == 0 {
+		return nil, nil, fmt.Errorf("%w: cites no sources", domain.ErrInvalidQuestion)
+	}
+
+	var ids []uuid.UUID
+	var citations []domain.Citation
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		label = strings.Trim(strings.TrimSpace(label), "[]")
+		chunk, ok := sources[label]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: cites unknown source %q", domain.ErrInvalidQuestion, label)
+		}
+		if seen[label] {
+			continue
+		}
+		seen[label] = true
+
+		ids = append(ids, chunk.ID)
+		citations = append(citations, domain.Citation{
+			ChunkID:    chunk.ID,
+			DocumentID: chunk.DocumentID,
+			Subject:    chunk.Subject,
+			Chapter:    chunk.Chapter,
+			Section:    chunk.Section,
+			Page:       chunk.Page,
+			PageEnd:    chunk.PageEnd,
+			Snippet:    snippet(chunk.Content),
+		})
+	}
+	return ids, citations, nil
+}
+
+// snippet collapses whitespace and cuts text to snippetLength characters
+func snippet(text string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= snippetLength {
+		return string(runes)
+	}
+	return string(runes[:snippetLength]) + "…"
+}
+
+// answerText renders a JSON string, number or boolean answer as text
+func answerText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strings.TrimSpace(s), nil
+	}
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return strconv.FormatBool(b), nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String(), nil
+	}
+	return "", fmt.Errorf("%w: answer must be a string, number or boolean", domain.ErrInvalidQuestion)
 }
 
 func cleanJSON(s string) string {
@@ -2,10 +2,15 @@ package rag
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"backend/internal/domain"
+	"backend/internal/generation"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -20,43 +25,392 @@ func (m *MockGeneratorClient) GenerateContent(ctx context.Context, prompt string
 	return args.String(0), args.Error(1)
 }
 
+// MockStreamingClient streams the chunks given to Return for GenerateStream
+type MockStreamingClient struct {
+	MockGeneratorClient
+}
+
+func (m *MockStreamingClient) GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error {
+	args := m.Called(ctx, prompt)
+	for _, chunk := range args.Get(0).([]string) {
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
 type MockRetriever struct {
 	mock.Mock
 }
 
-func (m *MockRetriever) Retrieve(ctx context.Context, query string, limit int, filter map[string]interface{}) ([]*domain.DocumentChunk, error) {
-	args := m.Called(ctx, query, limit, filter)
+func (m *MockRetriever) Retrieve(ctx context.Context, query string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	args := m.Called(ctx, query, q)
 	return args.Get(0).([]*domain.DocumentChunk), args.Error(1)
 }
 
+type MockQuestionRepo struct {
+	mock.Mock
+}
+
+func (m *MockQuestionRepo) SaveQuestions(ctx context.Context, questions []*domain.Question) error {
+	args := m.Called(ctx, questions)
+	return args.Error(0)
+}
+
+func (m *MockQuestionRepo) GetQuestion(ctx context.Context, id uuid.UUID) (*domain.Question, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Question), args.Error(1)
+}
+
+func (m *MockQuestionRepo) ListQuestions(ctx context.Context, filter domain.QuestionFilter) ([]*domain.Question, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*domain.Question), args.Error(1)
+}
+
+func (m *MockQuestionRepo) UpdateQuestion(ctx context.Context, question *domain.Question) error {
+	args := m.Called(ctx, question)
+	return args.Error(0)
+}
+
+func (m *MockQuestionRepo) DeleteQuestion(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func TestGenerateQuestions(t *testing.T) {
 	mockGen := new(MockGeneratorClient)
 	mockRetriever := new(MockRetriever)
+	mockQuestions := new(MockQuestionRepo)
 
-	service := NewGeneratorService(mockGen, mockRetriever)
+	service := NewGeneratorService(mockGen, mockRetriever, mockQuestions)
 
 	ctx := context.Background()
 	topic := "Newton"
-	count := 2
 	language := "en"
 	chapter := 1
+	mix := domain.QuestionMix{domain.QuestionTypeMCQ: 1, domain.QuestionTypeTrueFalse: 1}
 
 	// Expectations
-	chunks := []*domain.DocumentChunk{{Content: "Context 1"}}
-	mockRetriever.On("Retrieve", ctx, topic, 20, mock.MatchedBy(func(f map[string]interface{}) bool {
-		return f["language"] == "en" && f["chapter"] == 1
+	chunkID := uuid.New()
+	docID := uuid.New()
+	chunks := []*domain.DocumentChunk{
+		{ID: chunkID, DocumentID: docID, Subject: "Physics", Chapter: 1, Page: 4, PageEnd: 5, Content: "Context 1"},
+		{ID: uuid.New(), DocumentID: docID, Subject: "Physics", Chapter: 2, Page: 9, PageEnd: 9, Content: "Context 2"},
+	}
+	mockRetriever.On("Retrieve", ctx, topic, mock.MatchedBy(func(q domain.SearchQuery) bool {
+		return q.Limit == 50 && q.Languages[0] == "en" && q.Chapters[0] == 1
 	})).Return(chunks, nil)
 
 	mockGen.On("GenerateContent", ctx, mock.MatchedBy(func(prompt string) bool {
-		// Verify prompt contains instructions
-		return true // simplify for now, check string content if needed
-	})).Return(`{"questions": ["Q1", "Q2"]}`, nil)
+		return strings.Contains(prompt, "- 1 mcq question(s)") && strings.Contains(prompt, "- 1 true_false question(s)") &&
+			strings.Contains(prompt, "[C1]\nContext 1") && strings.Contains(prompt, "[C2]\nContext 2")
+	})).Return(`{"questions": [
+		{"type": "mcq", "stem": "Which law?", "options": ["First", "Second", "Third"], "answer": "First", "explanation": "Inertia", "difficulty": "easy", "marks": 1, "sources": ["C1"]},
+		{"type": "true_false", "stem": "Mass is a vector.", "answer": false, "difficulty": "easy", "marks": 1, "sources": ["C1", "C2"]}
+	]}`, nil)
+
+	mockQuestions.On("SaveQuestions", ctx, mock.MatchedBy(func(qs []*domain.Question) bool {
+		return len(qs) == 2 && qs[0].ID != uuid.Nil && qs[0].UserID == "user-1" && qs[0].Topic == topic
+	})).Return(nil)
 
-	questions, err := service.GenerateQuestions(ctx, topic, chapter, count, language)
+	questions, err := service.GenerateQuestions(ctx, domain.GenerationRequest{
+		Topic:    topic,
+		Language: language,
+		Mix:      mix,
+		Query:    domain.SearchQuery{Chapters: []int{chapter}},
+		UserID:   "user-1",
+	})
 	assert.NoError(t, err)
 	assert.Len(t, questions, 2)
-	assert.Equal(t, "Q1", questions[0])
+	assert.Equal(t, domain.QuestionTypeMCQ, questions[0].Type)
+	assert.Equal(t, "First", questions[0].Answer)
+	assert.Equal(t, "false", questions[1].Answer)
+	assert.Equal(t, []uuid.UUID{chunkID}, questions[0].SourceChunkIDs)
+	assert.Equal(t, domain.Citation{ChunkID: chunkID, DocumentID: docID, Subject: "Physics", Chapter: 1, Page: 4, PageEnd: 5, Snippet: "Context 1"}, questions[0].Citations[0])
+	assert.Equal(t, 1, questions[0].Chapter)
+	// The second question cites two chapters of one subject
+	assert.Len(t, questions[1].Citations, 2)
+	assert.Equal(t, "Physics", questions[1].Subject)
+	assert.Equal(t, 0, questions[1].Chapter)
 
 	mockRetriever.AssertExpectations(t)
 	mockGen.AssertExpectations(t)
+	mockQuestions.AssertExpectations(t)
+}
+
+func TestGenerateQuestions_InvalidMix(t *testing.T) {
+	mockRetriever := new(MockRetriever)
+	service := NewGeneratorService(new(MockGeneratorClient), mockRetriever, new(MockQuestionRepo))
+
+	_, err := service.GenerateQuestions(context.Background(), domain.GenerationRequest{Topic: "Newton", Language: "en"})
+	assert.ErrorIs(t, err, domain.ErrInvalidQuery)
+	mockRetriever.AssertNotCalled(t, "Retrieve", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGenerateQuestions_InsufficientContext(t *testing.T) {
+	mockClient := new(MockGeneratorClient)
+	mockRetriever := new(MockRetriever)
+	service := NewGeneratorService(mockClient, mockRetriever, new(MockQuestionRepo))
+
+	// Nothing cleared the similarity threshold
+	mockRetriever.On("Retrieve", mock.Anything, "Quantum gravity", mock.Anything).Return([]*domain.DocumentChunk{}, nil)
+
+	_, err := service.GenerateQuestions(context.Background(), domain.GenerationRequest{
+		Topic:    "Quantum gravity",
+		Language: "en",
+		Mix:      domain.QuestionMix{domain.QuestionTypeShortAnswer: 1},
+		Query:    domain.SearchQuery{MinScore: 0.8},
+	})
+	assert.ErrorIs(t, err, domain.ErrInsufficientContext)
+	mockClient.AssertNotCalled(t, "GenerateContent", mock.Anything, mock.Anything)
+}
+
+type MockTokenCounter struct {
+	mock.Mock
+}
+
+func (m *MockTokenCounter) CountTokens(ctx context.Context, text string) (int, error) {
+	args := m.Called(ctx, text)
+	return args.Int(0), args.Error(1)
+}
+
+func TestFitContext_FillsBudgetInRankOrder(t *testing.T) {
+	service := NewGeneratorService(new(MockGeneratorClient), new(MockRetriever), new(MockQuestionRepo))
+	chunks := []*domain.DocumentChunk{
+		{Content: "Force is mass times acceleration."},
+		{Content: strings.Repeat("A long passage about friction. ", 20)},
+		{Content: "Weight is a force."},
+	}
+	cost := func(i int) int {
+		return service.Tokenizer.Count(contextBlock(fmt.Sprintf("C%d", i+1), chunks[i]))
+	}
+
+	// The second chunk does not fit, but the third still does
+	service.ContextTokens = cost(0) + cost(2)
+	assert.Equal(t, []*domain.DocumentChunk{chunks[0], chunks[2]}, service.fitContext(context.Background(), chunks))
+
+	// The best chunk is kept even when it alone is over budget
+	service.ContextTokens = 1
+	assert.Equal(t, chunks[:1], service.fitContext(context.Background(), chunks))
+
+	service.ContextTokens = 0
+	assert.Equal(t, chunks, service.fitContext(context.Background(), chunks))
+}
+
+func TestFitContext_CorrectsEstimateWithExactCount(t *testing.T) {
+	counter := new(MockTokenCounter)
+	service := NewGeneratorService(new(MockGeneratorClient), new(MockRetriever), new(MockQuestionRepo))
+	service.TokenCounter = counter
+
+	chunks := []*domain.DocumentChunk{{Content: "aaaa"}, {Content: "bbbb"}, {Content: "cccc"}}
+	estimate := service.Tokenizer.Count(contextBlock("C1", chunks[0]))
+	service.ContextTokens = 3 * estimate
+
+	// The model reads the context as twice the tokens estimated
+	counter.On("CountTokens", mock.Anything, mock.Anything).Return(6*estimate, nil).Once()
+	assert.Equal(t, chunks[:1], service.fitContext(context.Background(), chunks))
+
+	// Without an exact count the estimate stands
+	counter.On("CountTokens", mock.Anything, mock.Anything).Return(0, errors.New("quota exceeded")).Once()
+	assert.Equal(t, chunks, service.fitContext(context.Background(), chunks))
+
+	counter.AssertExpectations(t)
+}
+
+func TestGenerateQuestionsStream(t *testing.T) {
+	mockGen := new(MockStreamingClient)
+	mockRetriever := new(MockRetriever)
+	mockQuestions := new(MockQuestionRepo)
+	service := NewGeneratorService(mockGen, mockRetriever, mockQuestions)
+
+	ctx := context.Background()
+	chunks := []*domain.DocumentChunk{{ID: uuid.New(), Subject: "Physics", Chapter: 1, Content: "Context 1"}}
+	mockRetriever.On("Retrieve", ctx, "Newton", mock.Anything).Return(chunks, nil)
+	mockGen.On("GenerateStream", ctx, mock.Anything).Return([]string{
+		`{"questions": [{"type": "short_answer", "stem": "What is inertia?", "answer": "Resistance", "diffi`,
+		`culty": "easy", "marks": 1, "sources": ["C1"]}, {"type": "short_answer", "stem": "", "sources": ["C1"]},`,
+		` {"type": "short_answer", "stem": "State the first law.", "answer": "A body stays at rest", "difficulty": "easy", "marks": 2, "sources": ["C1"]}]}`,
+	}, nil)
+	mockQuestions.On("SaveQuestions", ctx, mock.MatchedBy(func(qs []*domain.Question) bool {
+		return len(qs) == 1 && qs[0].ID != uuid.Nil && qs[0].Chapter == 1
+	})).Return(nil).Twice()
+
+	var stems []string
+	var seen []domain.GenerationProgress
+	progress, err := service.GenerateQuestionsStream(ctx, domain.GenerationRequest{
+		Topic:    "Newton",
+		Language: "en",
+		Mix:      domain.QuestionMix{domain.QuestionTypeShortAnswer: 3},
+	}, func(q *domain.Question, p domain.GenerationProgress) error {
+		stems = append(stems, q.Stem)
+		seen = append(seen, p)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"What is inertia?", "State the first law."}, stems)
+	assert.Equal(t, []domain.GenerationProgress{{Requested: 3, Generated: 1}, {Requested: 3, Generated: 2, Rejected: 1}}, seen)
+	assert.Equal(t, domain.GenerationProgress{Requested: 3, Generated: 2, Rejected: 1}, progress)
+	mockGen.AssertNotCalled(t, "GenerateContent", mock.Anything, mock.Anything)
+	mockQuestions.AssertExpectations(t)
+}
+
+func TestGenerateQuestionsStream_StopsWhenCallbackFails(t *testing.T) {
+	mockGen := new(MockStreamingClient)
+	mockRetriever := new(MockRetriever)
+	mockQuestions := new(MockQuestionRepo)
+	service := NewGeneratorService(mockGen, mockRetriever, mockQuestions)
+
+	ctx := context.Background()
+	question := `{"type": "short_answer", "stem": "Q", "answer": "A", "difficulty": "easy", "marks": 1, "sources": ["C1"]}`
+	mockRetriever.On("Retrieve", ctx, "Newton", mock.Anything).Return([]*domain.DocumentChunk{{ID: uuid.New(), Content: "Context"}}, nil)
+	mockGen.On("GenerateStream", ctx, mock.Anything).Return([]string{`{"questions": [` + question + `,`, question + `]}`}, nil)
+	mockQuestions.On("SaveQuestions", ctx, mock.Anything).Return(nil).Once()
+
+	progress, err := service.GenerateQuestionsStream(ctx, domain.GenerationRequest{
+		Topic:    "Newton",
+		Language: "en",
+		Mix:      domain.QuestionMix{domain.QuestionTypeShortAnswer: 2},
+	}, func(*domain.Question, domain.GenerationProgress) error {
+		return context.Canceled
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, progress.Generated)
+	mockQuestions.AssertExpectations(t)
+}
+
+func TestGenerateQuestionsStream_FallsBackToGenerateContent(t *testing.T) {
+	mockGen := new(MockGeneratorClient)
+	mockRetriever := new(MockRetriever)
+	mockQuestions := new(MockQuestionRepo)
+	service := NewGeneratorService(mockGen, mockRetriever, mockQuestions)
+
+	ctx := context.Background()
+	mockRetriever.On("Retrieve", ctx, "Newton", mock.Anything).Return([]*domain.DocumentChunk{{ID: uuid.New(), Content: "Context"}}, nil)
+	mockGen.On("GenerateContent", ctx, mock.Anything).Return(`{"questions": [{"type": "short_answer", "stem": "Bad"}]}`, nil)
+
+	_, err := service.GenerateQuestionsStream(ctx, domain.GenerationRequest{
+		Topic:    "Newton",
+		Language: "en",
+		Mix:      domain.QuestionMix{domain.QuestionTypeShortAnswer: 1},
+	}, func(*domain.Question, domain.GenerationProgress) error { return nil })
+	assert.ErrorContains(t, err, "none of the 1 generated questions were valid")
+	mockQuestions.AssertNotCalled(t, "SaveQuestions", mock.Anything, mock.Anything)
+}
+
+// testSources is a single labelled context chunk for parseQuestions
+var testSources = map[string]*domain.DocumentChunk{"C1": {ID: uuid.New(), Content: "Context"}}
+
+func TestParseQuestions_RejectsMalformedItems(t *testing.T) {
+	resp := "```json\n" + `{"questions": [
+		{"type": "numerical", "stem": "F = 2 kg × 3 m/s²?", "answer": 6, "difficulty": "medium", "marks": 2, "sources": ["C1"]},
+		{"type": "numerical", "stem": "Units included", "answer": "6 N", "difficulty": "medium", "marks": 2, "sources": ["C1"]},
+		{"type": "mcq", "stem": "Answer not an option", "options": ["A", "B", "C"], "answer": "D", "difficulty": "easy", "marks": 1, "sources": ["C1"]},
+		{"type": "short_answer", "stem": "Unknown field", "answer": "x", "difficulty": "easy", "marks": 1, "hint": "y", "sources": ["C1"]},
+		{"type": "essay", "stem": "Unknown type", "answer": "x", "difficulty": "easy", "marks": 5, "sources": ["C1"]},
+		{"type": "numerical", "stem": "Over quota", "answer": "1.5", "difficulty": "easy", "marks": 1, "sources": ["C1"]},
+		{"type": "true_false", "stem": "Not requested", "answer": "True", "difficulty": "easy", "marks": 1, "sources": ["C1"]}
+	]}` + "\n```"
+
+	questions, err := parseQuestions(resp, domain.QuestionMix{domain.QuestionTypeNumerical: 1, domain.QuestionTypeMCQ: 1}, testSources)
+	assert.NoError(t, err)
+	assert.Len(t, questions, 1)
+	assert.Equal(t, "6", questions[0].Answer)
+}
+
+func TestParseQuestions_NoneValid(t *testing.T) {
+	_, err := parseQuestions(`{"questions": [{"type": "mcq", "stem": "No options", "answer": "A", "difficulty": "easy", "marks": 1, "sources": ["C1"]}]}`, domain.QuestionMix{domain.QuestionTypeMCQ: 1}, testSources)
+	assert.ErrorContains(t, err, "none of the 1 generated questions were valid")
+
+	_, err = parseQuestions(`not json`, domain.QuestionMix{domain.QuestionTypeMCQ: 1}, testSources)
+	assert.ErrorContains(t, err, "parsing response failed")
+}
+
+func TestParseQuestions_RejectsUnknownSources(t *testing.T) {
+	resp := `{"questions": [
+		{"type": "short_answer", "stem": "Unknown source", "answer": "x", "difficulty": "easy", "marks": 1, "sources": ["C1", "C7"]},
+		{"type": "short_answer", "stem": "No sources", "answer": "x", "difficulty": "easy", "marks": 1},
+		{"type": "short_answer", "stem": "Bracketed source", "answer": "x", "difficulty": "easy", "marks": 1, "sources": ["[C1]", "C1"]}
+	]}`
+
+	questions, err := parseQuestions(resp, domain.QuestionMix{domain.QuestionTypeShortAnswer: 3}, testSources)
+	assert.NoError(t, err)
+	assert.Len(t, questions, 1)
+	assert.Equal(t, "Bracketed source", questions[0].Stem)
+	assert.Equal(t, []uuid.UUID{testSources["C1"].ID}, questions[0].SourceChunkIDs)
+}
+
+func TestSnippet(t *testing.T) {
+	assert.Equal(t, "Newton's first law", snippet("  Newton's\nfirst   law "))
+
+	long := snippet(strings.Repeat("বল ", 150))
+	assert.Equal(t, snippetLength+1, len([]rune(long)))
+	assert.True(t, strings.HasSuffix(long, "…"))
+}
+
+func TestUpdateQuestion(t *testing.T) {
+	mockQuestions := new(MockQuestionRepo)
+	service := NewGeneratorService(new(MockGeneratorClient), new(MockRetriever), mockQuestions)
+
+	ctx := context.Background()
+	stored := &domain.Question{ID: uuid.New(), Type: domain.QuestionTypeShortAnswer, Stem: "Old", Answer: "a", Difficulty: domain.DifficultyEasy, Marks: 1, Topic: "Newton", UserID: "user-1"}
+	mockQuestions.On("GetQuestion", ctx, stored.ID).Return(stored, nil)
+	mockQuestions.On("UpdateQuestion", ctx, mock.MatchedBy(func(q *domain.Question) bool {
+		return q.Stem == "New" && q.Marks == 2 && q.Topic == "Newton" && q.UserID == "user-1"
+	})).Return(nil)
+
+	updated, err := service.UpdateQuestion(ctx, stored.ID, domain.Question{
+		Type: domain.QuestionTypeShortAnswer, Stem: "New", Answer: "b", Difficulty: domain.DifficultyMedium, Marks: 2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "New", updated.Stem)
+	mockQuestions.AssertExpectations(t)
+}
+
+func TestUpdateQuestion_Invalid(t *testing.T) {
+	mockQuestions := new(MockQuestionRepo)
+	service := NewGeneratorService(new(MockGeneratorClient), new(MockRetriever), mockQuestions)
+
+	ctx := context.Background()
+	stored := &domain.Question{ID: uuid.New(), Type: domain.QuestionTypeShortAnswer, Stem: "Old", Answer: "a", Difficulty: domain.DifficultyEasy, Marks: 1}
+	mockQuestions.On("GetQuestion", ctx, stored.ID).Return(stored, nil)
+
+	_, err := service.UpdateQuestion(ctx, stored.ID, domain.Question{
+		Type: domain.QuestionTypeMCQ, Stem: "No options", Answer: "b", Difficulty: domain.DifficultyMedium, Marks: 2,
+	})
+	assert.ErrorIs(t, err, domain.ErrInvalidQuestion)
+	mockQuestions.AssertNotCalled(t, "UpdateQuestion", mock.Anything, mock.Anything)
+}
+
+func TestGenerateQuestions_ScriptedClient(t *testing.T) {
+	mockRetriever := new(MockRetriever)
+	mockQuestions := new(MockQuestionRepo)
+	service := NewGeneratorService(generation.NewScriptedClient(""), mockRetriever, mockQuestions)
+
+	ctx := context.Background()
+	chunks := []*domain.DocumentChunk{
+		{ID: uuid.New(), Subject: "Physics", Chapter: 1, Content: "Force equals mass times acceleration. It is measured in newtons."},
+		{ID: uuid.New(), Subject: "Physics", Chapter: 1, Content: "বল একটি ভেক্টর রাশি। এর মান ও দিক আছে।"},
+	}
+	mockRetriever.On("Retrieve", ctx, "Force", mock.Anything).Return(chunks, nil)
+	mockQuestions.On("SaveQuestions", ctx, mock.Anything).Return(nil)
+
+	req := domain.GenerationRequest{
+		Topic:    "Force",
+		Language: "en",
+		Mix: domain.QuestionMix{
+			domain.QuestionTypeMCQ: 2, domain.QuestionTypeShortAnswer: 1,
+			domain.QuestionTypeNumerical: 1, domain.QuestionTypeTrueFalse: 1,
+		},
+	}
+	questions, err := service.GenerateQuestions(ctx, req)
+	assert.NoError(t, err)
+	assert.Len(t, questions, 5)
+
+	progress, err := service.GenerateQuestionsStream(ctx, req, func(*domain.Question, domain.GenerationProgress) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, domain.GenerationProgress{Requested: 5, Generated: 5}, progress)
 }
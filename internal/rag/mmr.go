@@ -0,0 +1,88 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+
+	"backend/internal/domain"
+)
+
+// MMROptions configure maximal marginal relevance re-ranking, which trades
+// some relevance for context that does not repeat itself
+type MMROptions struct {
+	// Lambda weighs relevance against diversity: 1 ranks by relevance alone,
+	// 0 by difference from the chunks already picked alone
+	Lambda float64
+	// FetchFactor is how many candidates are fetched per chunk returned
+	FetchFactor int
+}
+
+// DefaultMMROptions leans towards relevance, which is enough to push
+// overlapping windows of the same passage apart
+func DefaultMMROptions() MMROptions {
+	return MMROptions{Lambda: 0.7, FetchFactor: 4}
+}
+
+// Validate checks the options are usable
+func (o MMROptions) Validate() error {
+	if o.Lambda < 0 || o.Lambda > 1 {
+		return fmt.Errorf("mmr lambda must be between 0 and 1, got %g", o.Lambda)
+	}
+	if o.FetchFactor < 1 {
+		return fmt.Errorf("mmr fetch factor must be at least 1, got %d", o.FetchFactor)
+	}
+	return nil
+}
+
+// selectMMR picks n of candidates one at a time, each time taking the one that
+// maximises lambda*relevance - (1-lambda)*(its highest similarity to a chunk
+// already picked). relevance holds each candidate's relevance to the query on
+// the scale of cosine similarity. Candidates without an embedding are never
+// considered redundant.
+func selectMMR(candidates []*domain.DocumentChunk, relevance []float64, n int, lambda float64) []*domain.DocumentChunk {
+	n = min(n, len(candidates))
+	picked := make([]*domain.DocumentChunk, 0, n)
+	used := make([]bool, len(candidates))
+	// redundancy[i] is candidate i's highest similarity to a picked chunk
+	redundancy := make([]float64, len(candidates))
+
+	for len(picked) < n {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range candidates {
+			if used[i] {
+				continue
+			}
+			// Strictly greater, so ties go to the better ranked candidate
+			if score := lambda*relevance[i] - (1-lambda)*redundancy[i]; score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		used[best] = true
+		chosen := candidates[best]
+		picked = append(picked, chosen)
+		for i, c := range candidates {
+			if !used[i] {
+				redundancy[i] = max(redundancy[i], cosineSimilarity(c.Embedding, chosen.Embedding))
+			}
+		}
+	}
+	return picked
+}
+
+// cosineSimilarity is 0 when either vector is missing, empty or zero, or they differ in length
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,32 @@
+package rag
+
+import (
+	"testing"
+
+	"backend/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectMMR(t *testing.T) {
+	page := &domain.DocumentChunk{Content: "page 4", Embedding: []float32{1, 0, 0}}
+	overlap := &domain.DocumentChunk{Content: "page 4, shifted", Embedding: []float32{0.99, 0.1, 0}}
+	other := &domain.DocumentChunk{Content: "page 9", Embedding: []float32{0.5, 0, 0.8}}
+	candidates := []*domain.DocumentChunk{page, overlap, other}
+	relevance := []float64{0.9, 0.88, 0.7}
+
+	// Relevance alone keeps the overlapping window second
+	assert.Equal(t, []*domain.DocumentChunk{page, overlap}, selectMMR(candidates, relevance, 2, 1))
+
+	// With diversity in the mix the other page wins the second place
+	assert.Equal(t, []*domain.DocumentChunk{page, other}, selectMMR(candidates, relevance, 2, 0.7))
+
+	// Asking for more than there are returns every candidate
+	assert.Len(t, selectMMR(candidates, relevance, 10, 0.7), 3)
+}
+
+func TestMMROptionsValidate(t *testing.T) {
+	assert.NoError(t, DefaultMMROptions().Validate())
+	assert.ErrorContains(t, MMROptions{Lambda: 1.5, FetchFactor: 4}.Validate(), "lambda must be between 0 and 1")
+	assert.ErrorContains(t, MMROptions{Lambda: 0.5}.Validate(), "fetch factor must be at least 1")
+}
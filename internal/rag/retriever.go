@@ -1,17 +1,30 @@
 package rag
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
 
 	"backend/internal/domain"
 	"backend/internal/ingestion"
+
+	"github.com/google/uuid"
 )
 
+// rrfK damps how much the top ranks dominate reciprocal rank fusion. 60 is
+// the value from the paper that introduced it and works well untuned.
+const rrfK = 60
+
 // Retriever retrieves relevant content
 type Retriever struct {
 	embedder ingestion.Embedder
 	repo     domain.VectorRepository
+	// MinScore is the cosine similarity below which vector and hybrid matches
+	// are dropped, for queries that do not set their own. Zero keeps every match.
+	MinScore float64
+	// MMR, when set, re-ranks vector and hybrid results for diversity
+	MMR *MMROptions
 }
 
 func NewRetriever(embedder ingestion.Embedder, repo domain.VectorRepository) *Retriever {
@@ -21,16 +34,158 @@ func NewRetriever(embedder ingestion.Embedder, repo domain.VectorRepository) *Re
 	}
 }
 
-func (r *Retriever) Retrieve(ctx context.Context, query string, limit int, filter map[string]interface{}) ([]*domain.DocumentChunk, error) {
+// Retrieve returns the chunks most relevant to query, searching as q.Mode
+// says, each with its score. It may return fewer chunks than q.Limit, or
+// none, if few are similar enough to the query.
+func (r *Retriever) Retrieve(ctx context.Context, query string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	// Reject bad queries before paying for an embedding call
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	if q.MinScore == 0 {
+		q.MinScore = r.MinScore
+	}
+
+	// Keyword results have no relevance on the scale MMR needs
+	if r.MMR != nil && q.Mode != domain.SearchModeKeyword {
+		return r.diversify(ctx, query, q)
+	}
+	return r.search(ctx, query, q)
+}
+
+// search runs the search q.Mode asks for
+func (r *Retriever) search(ctx context.Context, query string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	switch q.Mode {
+	case domain.SearchModeKeyword:
+		chunks, err := r.repo.SearchKeyword(ctx, query, q)
+		if err != nil {
+			return nil, fmt.Errorf("search failed: %w", err)
+		}
+		return chunks, nil
+	case domain.SearchModeHybrid:
+		return r.hybrid(ctx, query, q)
+	}
+
 	embedding, err := r.embedder.EmbedContent(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("embedding query failed: %w", err)
 	}
 
-	chunks, err := r.repo.SearchSimilar(ctx, embedding, limit, filter)
+	chunks, err := r.repo.SearchSimilar(ctx, embedding, q)
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
 	return chunks, nil
 }
+
+// diversify over-fetches candidates with their embeddings and re-ranks them
+// by maximal marginal relevance, so overlapping chunks of the same passage
+// do not crowd out the rest of the context
+func (r *Retriever) diversify(ctx context.Context, query string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	candidates := q
+	candidates.Offset = 0
+	candidates.Limit = min((q.Offset+q.Limit)*r.MMR.FetchFactor, domain.MaxSearchLimit)
+	candidates.IncludeEmbeddings = true
+
+	chunks, err := r.search(ctx, query, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	relevance := make([]float64, len(chunks))
+	var top float64
+	for i, c := range chunks {
+		relevance[i] = c.Score
+		top = max(top, c.Score)
+	}
+	// Fused scores are far smaller than similarities; rescale them so
+	// Lambda means the same in both modes
+	if q.Mode == domain.SearchModeHybrid && top > 0 {
+		for i := range relevance {
+			relevance[i] /= top
+		}
+	}
+
+	picked := selectMMR(chunks, relevance, q.Offset+q.Limit, r.MMR.Lambda)
+	if !q.IncludeEmbeddings {
+		for _, c := range picked {
+			c.Embedding = nil
+		}
+	}
+	if q.Offset >= len(picked) {
+		return []*domain.DocumentChunk{}, nil
+	}
+	return picked[q.Offset:], nil
+}
+
+// hybrid runs a vector and a keyword search and fuses their rankings with
+// reciprocal rank fusion, so chunks ranked well by either search come first
+// and chunks found by both come before either. MinScore holds keyword matches
+// to the same similarity floor as vector ones, so a chunk that merely shares
+// a common word with the query does not get past it.
+func (r *Retriever) hybrid(ctx context.Context, query string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	// Fetch more candidates than the page needs so a chunk ranked low by one
+	// search can still be lifted by the other
+	candidates := q
+	candidates.Offset = 0
+	candidates.Limit = min(2*(q.Offset+q.Limit), domain.MaxSearchLimit)
+
+	embedding, err := r.embedder.EmbedContent(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embedding query failed: %w", err)
+	}
+	byVector, err := r.repo.SearchSimilar(ctx, embedding, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	keywordQuery := candidates
+	if q.MinScore > 0 {
+		keywordQuery.IncludeEmbeddings = true
+	}
+	byKeyword, err := r.repo.SearchKeyword(ctx, query, keywordQuery)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", err)
+	}
+	if q.MinScore > 0 {
+		byKeyword = slices.DeleteFunc(byKeyword, func(c *domain.DocumentChunk) bool {
+			return cosineSimilarity(embedding, c.Embedding) < q.MinScore
+		})
+		if !q.IncludeEmbeddings {
+			for _, c := range byKeyword {
+				c.Embedding = nil
+			}
+		}
+	}
+
+	fused := fuseRankings(byVector, byKeyword)
+	if q.Offset >= len(fused) {
+		return []*domain.DocumentChunk{}, nil
+	}
+	return fused[q.Offset:min(q.Offset+q.Limit, len(fused))], nil
+}
+
+// fuseRankings merges rankings by reciprocal rank fusion: a chunk scores
+// 1/(rrfK+rank) for every ranking it appears in, and that sum replaces its
+// Score. Ties keep the order in which chunks first appear, so earlier
+// rankings win them.
+func fuseRankings(rankings ...[]*domain.DocumentChunk) []*domain.DocumentChunk {
+	scores := make(map[uuid.UUID]float64)
+	var fused []*domain.DocumentChunk
+	for _, ranking := range rankings {
+		for rank, chunk := range ranking {
+			if _, ok := scores[chunk.ID]; !ok {
+				fused = append(fused, chunk)
+			}
+			scores[chunk.ID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	slices.SortStableFunc(fused, func(a, b *domain.DocumentChunk) int {
+		return cmp.Compare(scores[b.ID], scores[a.ID])
+	})
+	for _, chunk := range fused {
+		chunk.Score = scores[chunk.ID]
+	}
+	return fused
+}
@@ -5,7 +5,11 @@ import (
 	"testing"
 
 	"backend/internal/domain"
+	"backend/internal/embedding"
+	"backend/internal/generation"
+	"backend/internal/repository"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -32,14 +36,45 @@ func (m *MockRepo) SaveChunk(ctx context.Context, chunk *domain.DocumentChunk) e
 	return args.Error(0)
 }
 
-func (m *MockRepo) SearchSimilar(ctx context.Context, embedding []float32, limit int, filter map[string]interface{}) ([]*domain.DocumentChunk, error) {
-	args := m.Called(ctx, embedding, limit, filter)
+func (m *MockRepo) SearchSimilar(ctx context.Context, embedding []float32, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	args := m.Called(ctx, embedding, q)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*domain.DocumentChunk), args.Error(1)
 }
 
+func (m *MockRepo) SearchKeyword(ctx context.Context, text string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	args := m.Called(ctx, text, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.DocumentChunk), args.Error(1)
+}
+
+func (m *MockRepo) SaveChunks(ctx context.Context, chunks []*domain.DocumentChunk) error {
+	args := m.Called(ctx, chunks)
+	return args.Error(0)
+}
+
+func (m *MockRepo) DeleteChunksByDocument(ctx context.Context, documentID uuid.UUID) error {
+	args := m.Called(ctx, documentID)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ChunkHashes(ctx context.Context, documentID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, documentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepo) ReplaceChunks(ctx context.Context, documentID uuid.UUID, add []*domain.DocumentChunk, keep []*domain.DocumentChunk) error {
+	args := m.Called(ctx, documentID, add, keep)
+	return args.Error(0)
+}
+
 func TestRetrieve(t *testing.T) {
 	mockRepo := new(MockRepo)
 	mockEmbedder := new(MockEmbedder)
@@ -55,11 +90,11 @@ func TestRetrieve(t *testing.T) {
 		{Content: "Law 2"},
 	}
 	// Filter check
-	mockRepo.On("SearchSimilar", ctx, embedding, 2, mock.MatchedBy(func(f map[string]interface{}) bool {
-		return f["language"] == "en"
+	mockRepo.On("SearchSimilar", ctx, embedding, mock.MatchedBy(func(q domain.SearchQuery) bool {
+		return q.Limit == 2 && len(q.Languages) == 1 && q.Languages[0] == "en"
 	})).Return(chunks, nil)
 
-	results, err := retriever.Retrieve(ctx, query, 2, map[string]interface{}{"language": "en"})
+	results, err := retriever.Retrieve(ctx, query, domain.SearchQuery{Languages: []string{"en"}, Limit: 2})
 	assert.NoError(t, err)
 	assert.Len(t, results, 2)
 	assert.Equal(t, "Law 1", results[0].Content)
@@ -67,3 +102,197 @@ func TestRetrieve(t *testing.T) {
 	mockEmbedder.AssertExpectations(t)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestRetrieve_MinScore(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockEmbedder := new(MockEmbedder)
+	retriever := NewRetriever(mockEmbedder, mockRepo)
+	retriever.MinScore = 0.6
+
+	ctx := context.Background()
+	embedding := []float32{0.1, 0.2}
+	mockEmbedder.On("EmbedContent", ctx, "Newton laws").Return(embedding, nil)
+	chunks := []*domain.DocumentChunk{{Content: "Law 1", Score: 0.9}}
+
+	// The retriever's cutoff applies to queries without their own
+	mockRepo.On("SearchSimilar", ctx, embedding, domain.SearchQuery{Limit: 5, MinScore: 0.6}).Return(chunks, nil).Once()
+	results, err := retriever.Retrieve(ctx, "Newton laws", domain.SearchQuery{Limit: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.9, results[0].Score)
+
+	mockRepo.On("SearchSimilar", ctx, embedding, domain.SearchQuery{Limit: 5, MinScore: 0.3}).Return(chunks, nil).Once()
+	_, err = retriever.Retrieve(ctx, "Newton laws", domain.SearchQuery{Limit: 5, MinScore: 0.3})
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRetrieve_MMR(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockEmbedder := new(MockEmbedder)
+	retriever := NewRetriever(mockEmbedder, mockRepo)
+	mmr := DefaultMMROptions()
+	retriever.MMR = &mmr
+
+	ctx := context.Background()
+	embedding := []float32{0.1, 0.2}
+	mockEmbedder.On("EmbedContent", ctx, "refraction").Return(embedding, nil)
+
+	page := &domain.DocumentChunk{Content: "page 4", Embedding: []float32{1, 0}, Score: 0.9}
+	overlap := &domain.DocumentChunk{Content: "page 4, shifted", Embedding: []float32{0.99, 0.1}, Score: 0.88}
+	other := &domain.DocumentChunk{Content: "page 9", Embedding: []float32{0.3, 1}, Score: 0.7}
+
+	// Four candidates per chunk asked for, with their embeddings
+	mockRepo.On("SearchSimilar", ctx, embedding, domain.SearchQuery{Limit: 8, IncludeEmbeddings: true}).
+		Return([]*domain.DocumentChunk{page, overlap, other}, nil)
+
+	results, err := retriever.Retrieve(ctx, "refraction", domain.SearchQuery{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.DocumentChunk{page, other}, results)
+	assert.Nil(t, results[0].Embedding)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRetrieve_InvalidQuery(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockEmbedder := new(MockEmbedder)
+	retriever := NewRetriever(mockEmbedder, mockRepo)
+
+	// An invalid query must fail before the embedder is called
+	_, err := retriever.Retrieve(context.Background(), "Newton laws", domain.SearchQuery{Limit: 0})
+	assert.ErrorIs(t, err, domain.ErrInvalidQuery)
+
+	mockEmbedder.AssertNotCalled(t, "EmbedContent", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "SearchSimilar", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRetrieve_Keyword(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockEmbedder := new(MockEmbedder)
+	retriever := NewRetriever(mockEmbedder, mockRepo)
+
+	ctx := context.Background()
+	q := domain.SearchQuery{Limit: 3, Mode: domain.SearchModeKeyword}
+	chunks := []*domain.DocumentChunk{{ID: uuid.New(), Content: "Bernoulli's principle"}}
+	mockRepo.On("SearchKeyword", ctx, "Bernoulli", q).Return(chunks, nil)
+
+	results, err := retriever.Retrieve(ctx, "Bernoulli", q)
+	assert.NoError(t, err)
+	assert.Equal(t, chunks, results)
+
+	// Keyword search needs no embedding
+	mockEmbedder.AssertNotCalled(t, "EmbedContent", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRetrieve_HybridFusesRankings(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockEmbedder := new(MockEmbedder)
+	retriever := NewRetriever(mockEmbedder, mockRepo)
+
+	ctx := context.Background()
+	query := "Snell's law"
+	embedding := []float32{0.1, 0.2}
+	mockEmbedder.On("EmbedContent", ctx, query).Return(embedding, nil)
+
+	a := &domain.DocumentChunk{ID: uuid.New(), Content: "a"}
+	b := &domain.DocumentChunk{ID: uuid.New(), Content: "b"}
+	c := &domain.DocumentChunk{ID: uuid.New(), Content: "c"}
+	d := &domain.DocumentChunk{ID: uuid.New(), Content: "d"}
+
+	// Both searches are asked for candidates covering twice the page
+	candidates := domain.SearchQuery{Limit: 4, Mode: domain.SearchModeHybrid}
+	mockRepo.On("SearchSimilar", ctx, embedding, candidates).Return([]*domain.DocumentChunk{a, b, c}, nil)
+	mockRepo.On("SearchKeyword", ctx, query, candidates).Return([]*domain.DocumentChunk{c, d}, nil)
+
+	results, err := retriever.Retrieve(ctx, query, domain.SearchQuery{Limit: 2, Mode: domain.SearchModeHybrid})
+	assert.NoError(t, err)
+	// c is found by both searches; a and d tie on rank 1 and the vector search wins ties
+	assert.Equal(t, []*domain.DocumentChunk{c, a}, results)
+	assert.InDelta(t, 1.0/63+1.0/61, c.Score, 1e-12)
+
+	results, err = retriever.Retrieve(ctx, query, domain.SearchQuery{Limit: 1, Offset: 1, Mode: domain.SearchModeHybrid})
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.DocumentChunk{a}, results)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRetrieve_HybridAppliesMinScore(t *testing.T) {
+	mockRepo := new(MockRepo)
+	mockEmbedder := new(MockEmbedder)
+	retriever := NewRetriever(mockEmbedder, mockRepo)
+	retriever.MinScore = 0.5
+
+	ctx := context.Background()
+	query := "the force"
+	embedding := []float32{1, 0}
+	mockEmbedder.On("EmbedContent", ctx, query).Return(embedding, nil)
+
+	related := &domain.DocumentChunk{ID: uuid.New(), Content: "force", Embedding: []float32{0.9, 0.1}}
+	// Shares only "the" with the query
+	unrelated := &domain.DocumentChunk{ID: uuid.New(), Content: "the weather", Embedding: []float32{0, 1}}
+
+	candidates := domain.SearchQuery{Limit: 4, Mode: domain.SearchModeHybrid, MinScore: 0.5}
+	withEmbeddings := candidates
+	withEmbeddings.IncludeEmbeddings = true
+	mockRepo.On("SearchSimilar", ctx, embedding, candidates).Return([]*domain.DocumentChunk{}, nil)
+	mockRepo.On("SearchKeyword", ctx, query, withEmbeddings).Return([]*domain.DocumentChunk{unrelated, related}, nil)
+
+	results, err := retriever.Retrieve(ctx, query, domain.SearchQuery{Limit: 2, Mode: domain.SearchModeHybrid})
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.DocumentChunk{related}, results)
+	assert.Nil(t, results[0].Embedding)
+
+	// With nothing similar enough the context is insufficient
+	mockRepo.On("SearchKeyword", ctx, "the weather", withEmbeddings).Return([]*domain.DocumentChunk{unrelated}, nil)
+	mockEmbedder.On("EmbedContent", ctx, "the weather").Return(embedding, nil)
+	mockRepo.On("SearchSimilar", ctx, embedding, candidates).Return([]*domain.DocumentChunk{}, nil)
+	results, err = retriever.Retrieve(ctx, "the weather", domain.SearchQuery{Limit: 2, Mode: domain.SearchModeHybrid})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRetrieve_Offline(t *testing.T) {
+	ctx := context.Background()
+	embedder := embedding.NewHashingEmbedder(0)
+	repo := repository.NewMemoryVectorRepo()
+
+	docID := uuid.New()
+	contents := []string{
+		"Photosynthesis turns light into chemical energy.",
+		"Newton's second law: force equals mass times acceleration.",
+		"Sound travels faster in water than in air.",
+	}
+	for i, content := range contents {
+		vector, err := embedder.EmbedContent(ctx, content)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.SaveChunk(ctx, &domain.DocumentChunk{
+			ID: uuid.New(), DocumentID: docID, ChunkIndex: i, Subject: "Science", Chapter: 1,
+			Content: content, Embedding: vector, Language: "en", Page: 1, PageEnd: 1,
+		}))
+	}
+
+	retriever := NewRetriever(embedder, repo)
+	results, err := retriever.Retrieve(ctx, "force mass acceleration", domain.SearchQuery{Languages: []string{"en"}, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, contents[1], results[0].Content)
+
+	// The same pipeline generates questions grounded in what it retrieved
+	mockQuestions := new(MockQuestionRepo)
+	mockQuestions.On("SaveQuestions", ctx, mock.Anything).Return(nil)
+	service := NewGeneratorService(generation.NewScriptedClient(""), retriever, mockQuestions)
+
+	questions, err := service.GenerateQuestions(ctx, domain.GenerationRequest{
+		Topic:    "force mass acceleration",
+		Language: "en",
+		Mix:      domain.QuestionMix{domain.QuestionTypeShortAnswer: 1},
+		Query:    domain.SearchQuery{Limit: 1},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, questions, 1)
+	assert.Equal(t, results[0].ID, questions[0].SourceChunkIDs[0])
+}
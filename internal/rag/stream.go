@@ -0,0 +1,60 @@
+package rag
+
+import "encoding/json"
+
+// questionDepth is the nesting depth of a question object in the response:
+// inside the top-level object and its "questions" array
+const questionDepth = 3
+
+// objectScanner picks question objects out of a JSON response as it arrives
+// in pieces, so each question can be handled before the model has finished
+// writing the rest. Anything outside the top-level object, such as a Markdown
+// code fence, is ignored.
+type objectScanner struct {
+	depth    int
+	inString bool
+	escaped  bool
+	// current holds the question object being read, nil between questions
+	current []byte
+}
+
+// feed consumes the next piece of the response and returns the question
+// objects it completed
+func (s *objectScanner) feed(text string) []json.RawMessage {
+	var objects []json.RawMessage
+	for i := 0; i < len(text); i++ {
+		b := text[i]
+		if s.current != nil {
+			s.current = append(s.current, b)
+		}
+
+		if s.inString {
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case b == '\\':
+				s.escaped = true
+			case b == '"':
+				s.inString = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			s.inString = true
+		case '{', '[':
+			s.depth++
+			if b == '{' && s.depth == questionDepth {
+				s.current = []byte{b}
+			}
+		case '}', ']':
+			if b == '}' && s.depth == questionDepth && s.current != nil {
+				objects = append(objects, s.current)
+				s.current = nil
+			}
+			s.depth--
+		}
+	}
+	return objects
+}
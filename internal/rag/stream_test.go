@@ -0,0 +1,37 @@
+package rag
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectScanner_SplitAnywhere(t *testing.T) {
+	resp := "```json\n" + `{"questions": [
+		{"type": "mcq", "stem": "Which {brace} \"quoted\" law?", "options": ["[A]", "B}"], "sources": ["C1"]},
+		{"type": "short_answer", "stem": "বল কী?", "answer": "\\", "sources": ["C2"]}
+	]}` + "\n```"
+
+	// Every split of the response must produce the same two objects
+	for i := 0; i <= len(resp); i++ {
+		var s objectScanner
+		objects := append(s.feed(resp[:i]), s.feed(resp[i:])...)
+		if !assert.Len(t, objects, 2, "split at %d", i) {
+			return
+		}
+
+		var first, second map[string]any
+		assert.NoError(t, json.Unmarshal(objects[0], &first))
+		assert.NoError(t, json.Unmarshal(objects[1], &second))
+		assert.Equal(t, `Which {brace} "quoted" law?`, first["stem"])
+		assert.Equal(t, "বল কী?", second["stem"])
+	}
+}
+
+func TestObjectScanner_IncompleteObject(t *testing.T) {
+	var s objectScanner
+	objects := s.feed(`{"questions": [{"type": "mcq"}, {"type": "short_an`)
+	assert.Len(t, objects, 1)
+	assert.JSONEq(t, `{"type": "mcq"}`, string(objects[0]))
+}
@@ -0,0 +1,399 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"backend/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// snapshotVersion is bumped whenever the snapshot format changes incompatibly
+const snapshotVersion = 1
+
+// MemoryVectorRepo is a VectorRepository held in memory. Searches compare the
+// query with every stored chunk, which is fast enough for tests, the CLI and
+// small deployments. It is safe for concurrent use.
+//
+// A repository opened with OpenMemoryVectorRepo is backed by a snapshot file.
+// Writing it costs time in the size of the whole store, so it is rewritten at
+// most once every FlushDelay, and on Close; changes made since the last write
+// are lost if the process dies.
+type MemoryVectorRepo struct {
+	mu     sync.RWMutex
+	chunks map[uuid.UUID]*domain.DocumentChunk
+	path   string
+
+	// FlushDelay is how long after a change the snapshot is rewritten
+	FlushDelay time.Duration
+	flush      *time.Timer
+	// flushErr is the error of the last background write, reported by the next change
+	flushErr error
+}
+
+func NewMemoryVectorRepo() *MemoryVectorRepo {
+	return &MemoryVectorRepo{chunks: make(map[uuid.UUID]*domain.DocumentChunk)}
+}
+
+// OpenMemoryVectorRepo loads the snapshot at path, starting empty if it does
+// not exist yet, and keeps it up to date from then on
+func OpenMemoryVectorRepo(path string) (*MemoryVectorRepo, error) {
+	r := NewMemoryVectorRepo()
+	r.path = path
+	r.FlushDelay = time.Second
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot failed: %w", err)
+	}
+
+	var snapshot vectorSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("decode snapshot failed: %w", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	for _, chunk := range snapshot.Chunks {
+		r.chunks[chunk.ID] = chunk
+	}
+	return r, nil
+}
+
+type vectorSnapshot struct {
+	Version int                     `json:"version"`
+	Chunks  []*domain.DocumentChunk `json:"chunks"`
+}
+
+// persist schedules a rewrite of the snapshot, if there is one, and reports
+// the failure of the previous one. Callers hold mu.
+func (r *MemoryVectorRepo) persist() error {
+	if r.path == "" {
+		return nil
+	}
+	if r.flush == nil {
+		r.flush = time.AfterFunc(r.FlushDelay, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.flush = nil
+			r.flushErr = r.writeSnapshot()
+		})
+	}
+	err := r.flushErr
+	r.flushErr = nil
+	return err
+}
+
+// Close writes any changes not yet in the snapshot
+func (r *MemoryVectorRepo) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.flush != nil && r.flush.Stop() {
+		r.flush = nil
+		return r.writeSnapshot()
+	}
+	err := r.flushErr
+	r.flushErr = nil
+	return err
+}
+
+// writeSnapshot rewrites the snapshot. The new file is renamed into place so
+// a crash never leaves a half-written snapshot. Callers hold mu.
+func (r *MemoryVectorRepo) writeSnapshot() error {
+	snapshot := vectorSnapshot{Version: snapshotVersion, Chunks: make([]*domain.DocumentChunk, 0, len(r.chunks))}
+	for _, chunk := range r.chunks {
+		snapshot.Chunks = append(snapshot.Chunks, chunk)
+	}
+	slices.SortFunc(snapshot.Chunks, compareChunks)
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encode snapshot failed: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write snapshot failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write snapshot failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("write snapshot failed: %w", err)
+	}
+	return nil
+}
+
+func (r *MemoryVectorRepo) SaveChunk(ctx context.Context, chunk *domain.DocumentChunk) error {
+	return r.SaveChunks(ctx, []*domain.DocumentChunk{chunk})
+}
+
+// SaveChunks stores all chunks or, if any of them is already stored, none
+func (r *MemoryVectorRepo) SaveChunks(ctx context.Context, chunks []*domain.DocumentChunk) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.insert(chunks); err != nil {
+		return err
+	}
+	return r.persist()
+}
+
+// insert stores copies of chunks, failing before storing any if an ID is
+// taken. Callers hold mu.
+func (r *MemoryVectorRepo) insert(chunks []*domain.DocumentChunk) error {
+	seen := make(map[uuid.UUID]bool, len(chunks))
+	for i, chunk := range chunks {
+		if _, ok := r.chunks[chunk.ID]; ok || seen[chunk.ID] {
+			return fmt.Errorf("saving chunk %d failed: chunk %s already exists", i, chunk.ID)
+		}
+		seen[chunk.ID] = true
+	}
+
+	for _, chunk := range chunks {
+		c := *chunk
+		c.Embedding = slices.Clone(chunk.Embedding)
+		r.chunks[c.ID] = &c
+	}
+	return nil
+}
+
+// ReplaceChunks inserts add, updates the stored chunks in keep to their new
+// position and placement, and deletes the document's chunks whose content
+// hash is not in keep or that an added chunk supersedes, all at once. Chunks
+// stored without a hash are left alone.
+func (r *MemoryVectorRepo) ReplaceChunks(ctx context.Context, documentID uuid.UUID, add []*domain.DocumentChunk, keep []*domain.DocumentChunk) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.insert(add); err != nil {
+		return err
+	}
+	added := make(map[string]uuid.UUID, len(add))
+	for _, chunk := range add {
+		added[chunk.ContentHash] = chunk.ID
+	}
+	kept := make(map[string]*domain.DocumentChunk, len(keep))
+	for _, chunk := range keep {
+		kept[chunk.ContentHash] = chunk
+	}
+	for id, chunk := range r.chunks {
+		if chunk.DocumentID != documentID || chunk.ContentHash == "" {
+			continue
+		}
+		k, ok := kept[chunk.ContentHash]
+		if newID, rebuilt := added[chunk.ContentHash]; !ok || rebuilt && newID != id {
+			delete(r.chunks, id)
+			continue
+		}
+		chunk.ChunkIndex, chunk.Subject, chunk.Language = k.ChunkIndex, k.Subject, k.Language
+		chunk.Chapter, chunk.Section = k.Chapter, k.Section
+		chunk.Page, chunk.PageEnd = k.Page, k.PageEnd
+	}
+	return r.persist()
+}
+
+func (r *MemoryVectorRepo) ChunkHashes(ctx context.Context, documentID uuid.UUID) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hashes := []string{}
+	for _, chunk := range r.chunks {
+		if chunk.DocumentID == documentID && chunk.ContentHash != "" {
+			hashes = append(hashes, chunk.ContentHash)
+		}
+	}
+	return hashes, nil
+}
+
+func (r *MemoryVectorRepo) DeleteChunksByDocument(ctx context.Context, documentID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, chunk := range r.chunks {
+		if chunk.DocumentID == documentID {
+			delete(r.chunks, id)
+		}
+	}
+	return r.persist()
+}
+
+// SearchSimilar returns the chunks matching q, most similar to embedding
+// first. Like the Postgres repository it leaves the embeddings out of the
+// results unless q.IncludeEmbeddings is set.
+func (r *MemoryVectorRepo) SearchSimilar(ctx context.Context, embedding []float32, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matches []scoredChunk
+	for _, chunk := range r.chunks {
+		if !matchesQuery(chunk, q) {
+			continue
+		}
+		if len(chunk.Embedding) != len(embedding) {
+			return nil, fmt.Errorf("search failed: chunk %s has %d dimensions, query has %d", chunk.ID, len(chunk.Embedding), len(embedding))
+		}
+
+		score := cosineSimilarity(chunk.Embedding, embedding)
+		if q.MinScore > 0 && score < q.MinScore {
+			continue
+		}
+		matches = append(matches, scoredChunk{chunk: chunk, score: score})
+	}
+	return rankChunks(matches, q), nil
+}
+
+// SearchKeyword returns the chunks matching q that contain every word of
+// text, scored by how often they occur. Words are lowercased but not stemmed,
+// and web search operators are not understood.
+func (r *MemoryVectorRepo) SearchKeyword(ctx context.Context, text string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	terms := words(text)
+	if len(terms) == 0 {
+		return []*domain.DocumentChunk{}, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matches []scoredChunk
+	for _, chunk := range r.chunks {
+		if !matchesQuery(chunk, q) {
+			continue
+		}
+
+		counts := make(map[string]int)
+		for _, w := range words(chunk.Content) {
+			counts[w]++
+		}
+		score := 0
+		for _, term := range terms {
+			if counts[term] == 0 {
+				score = 0
+				break
+			}
+			score += counts[term]
+		}
+		if score > 0 {
+			matches = append(matches, scoredChunk{chunk: chunk, score: float64(score)})
+		}
+	}
+	return rankChunks(matches, q), nil
+}
+
+type scoredChunk struct {
+	chunk *domain.DocumentChunk
+	score float64
+}
+
+// rankChunks returns the page of matches q asks for, best score first, with
+// their scores and, if q asks for them, their embeddings
+func rankChunks(matches []scoredChunk, q domain.SearchQuery) []*domain.DocumentChunk {
+	// Ties are broken by position so results are stable
+	slices.SortFunc(matches, func(a, b scoredChunk) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return compareChunks(a.chunk, b.chunk)
+	})
+
+	if q.Offset >= len(matches) {
+		return []*domain.DocumentChunk{}
+	}
+	matches = matches[q.Offset:min(q.Offset+q.Limit, len(matches))]
+
+	chunks := make([]*domain.DocumentChunk, len(matches))
+	for i, m := range matches {
+		c := *m.chunk
+		c.Embedding = nil
+		if q.IncludeEmbeddings {
+			c.Embedding = slices.Clone(m.chunk.Embedding)
+		}
+		c.Score = m.score
+		chunks[i] = &c
+	}
+	return chunks
+}
+
+// words splits text into lowercased words, like the 'simple' text search configuration
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
+
+// matchesQuery applies the same filters as buildWhereClause, except MinScore
+func matchesQuery(chunk *domain.DocumentChunk, q domain.SearchQuery) bool {
+	if q.Subject != "" && chunk.Subject != q.Subject {
+		return false
+	}
+	if len(q.Chapters) > 0 && !slices.Contains(q.Chapters, chunk.Chapter) {
+		return false
+	}
+	if len(q.Languages) > 0 && !slices.Contains(q.Languages, chunk.Language) {
+		return false
+	}
+	if len(q.DocumentIDs) > 0 && !slices.Contains(q.DocumentIDs, chunk.DocumentID) {
+		return false
+	}
+	// A chunk matches a page range when any of its pages fall inside it
+	if q.PageFrom > 0 && chunk.PageEnd < q.PageFrom {
+		return false
+	}
+	if q.PageTo > 0 && chunk.Page > q.PageTo {
+		return false
+	}
+	return true
+}
+
+// cosineSimilarity is 1 - pgvector's cosine distance; it is 0 for a zero vector
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func compareChunks(a, b *domain.DocumentChunk) int {
+	if c := cmp.Compare(a.DocumentID.String(), b.DocumentID.String()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.ChunkIndex, b.ChunkIndex); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID.String(), b.ID.String())
+}
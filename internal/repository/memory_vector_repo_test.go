@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func memoryChunk(docID uuid.UUID, index int, hash string, embedding ...float32) *domain.DocumentChunk {
+	return &domain.DocumentChunk{
+		ID:          uuid.New(),
+		DocumentID:  docID,
+		ChunkIndex:  index,
+		ContentHash: hash,
+		Subject:     "Physics",
+		Chapter:     1,
+		Content:     "chunk " + hash,
+		Embedding:   embedding,
+		Language:    "en",
+		Page:        index + 1,
+		PageEnd:     index + 1,
+	}
+}
+
+func TestMemoryVectorRepo_SearchSimilar(t *testing.T) {
+	repo := NewMemoryVectorRepo()
+	ctx := context.Background()
+
+	docA, docB := uuid.New(), uuid.New()
+	near := memoryChunk(docA, 0, "near", 1, 0.1)
+	far := memoryChunk(docA, 1, "far", 0, 1)
+	other := memoryChunk(docB, 0, "other", 1, 0)
+	other.Language = "bn"
+	other.Chapter = 2
+	assert.NoError(t, repo.SaveChunks(ctx, []*domain.DocumentChunk{near, far, other}))
+
+	results, err := repo.SearchSimilar(ctx, []float32{1, 0}, domain.SearchQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{other.ID, near.ID, far.ID}, chunkIDs(results))
+	assert.Nil(t, results[0].Embedding)
+	assert.InDelta(t, 1, results[0].Score, 1e-9)
+	assert.InDelta(t, 0, results[2].Score, 1e-9)
+
+	results, err = repo.SearchSimilar(ctx, []float32{1, 0}, domain.SearchQuery{Languages: []string{"en"}, PageFrom: 2, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{far.ID}, chunkIDs(results))
+
+	results, err = repo.SearchSimilar(ctx, []float32{1, 0}, domain.SearchQuery{Chapters: []int{1, 2}, MinScore: 0.9, Limit: 1, Offset: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{near.ID}, chunkIDs(results))
+
+	results, err = repo.SearchSimilar(ctx, []float32{1, 0}, domain.SearchQuery{Limit: 1, IncludeEmbeddings: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1, 0}, results[0].Embedding)
+
+	_, err = repo.SearchSimilar(ctx, []float32{1, 0}, domain.SearchQuery{})
+	assert.ErrorIs(t, err, domain.ErrInvalidQuery)
+
+	_, err = repo.SearchSimilar(ctx, []float32{1, 0, 0}, domain.SearchQuery{Limit: 1})
+	assert.ErrorContains(t, err, "dimensions")
+}
+
+func TestMemoryVectorRepo_SearchKeyword(t *testing.T) {
+	repo := NewMemoryVectorRepo()
+	ctx := context.Background()
+
+	docID := uuid.New()
+	snell := memoryChunk(docID, 0, "a", 1)
+	snell.Content = "Snell's law relates the angles of refraction. Snell measured them."
+	bernoulli := memoryChunk(docID, 1, "b", 1)
+	bernoulli.Content = "Bernoulli's principle: faster flow, lower pressure."
+	bengali := memoryChunk(docID, 2, "c", 1)
+	bengali.Content = "আলোর প্রতিসরণ সম্পর্কে স্নেলের সূত্র।"
+	bengali.Language = "bn"
+	assert.NoError(t, repo.SaveChunks(ctx, []*domain.DocumentChunk{snell, bernoulli, bengali}))
+
+	results, err := repo.SearchKeyword(ctx, "SNELL law", domain.SearchQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{snell.ID}, chunkIDs(results))
+	assert.Nil(t, results[0].Embedding)
+
+	results, err = repo.SearchKeyword(ctx, "প্রতিসরণ", domain.SearchQuery{Languages: []string{"bn"}, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{bengali.ID}, chunkIDs(results))
+
+	// Every word must appear
+	results, err = repo.SearchKeyword(ctx, "Bernoulli refraction", domain.SearchQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestMemoryVectorRepo_SaveChunksIsAtomic(t *testing.T) {
+	repo := NewMemoryVectorRepo()
+	ctx := context.Background()
+
+	existing := memoryChunk(uuid.New(), 0, "a", 1)
+	assert.NoError(t, repo.SaveChunk(ctx, existing))
+
+	err := repo.SaveChunks(ctx, []*domain.DocumentChunk{memoryChunk(existing.DocumentID, 1, "b", 1), existing})
+	assert.ErrorContains(t, err, "already exists")
+
+	hashes, err := repo.ChunkHashes(ctx, existing.DocumentID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, hashes)
+}
+
+func TestMemoryVectorRepo_ReplaceAndDelete(t *testing.T) {
+	repo := NewMemoryVectorRepo()
+	ctx := context.Background()
+
+	docID := uuid.New()
+	legacy := memoryChunk(docID, 0, "", 1)
+	assert.NoError(t, repo.SaveChunks(ctx, []*domain.DocumentChunk{legacy, memoryChunk(docID, 1, "old", 1), memoryChunk(docID, 2, "same", 1)}))
+
+	// A new page before it moves the unchanged chunk on
+	added := memoryChunk(docID, 1, "new", 1)
+	moved := memoryChunk(docID, 6, "same")
+	moved.Chapter, moved.Section = 2, "2.1 Speed"
+	assert.NoError(t, repo.ReplaceChunks(ctx, docID, []*domain.DocumentChunk{added}, []*domain.DocumentChunk{added, moved}))
+	hashes, err := repo.ChunkHashes(ctx, docID)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"same", "new"}, hashes)
+
+	same, err := repo.SearchKeyword(ctx, "same", domain.SearchQuery{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, same, 1) {
+		assert.Equal(t, 6, same[0].ChunkIndex)
+		assert.Equal(t, 7, same[0].Page)
+		assert.Equal(t, 2, same[0].Chapter)
+		assert.Equal(t, "2.1 Speed", same[0].Section)
+	}
+
+	results, err := repo.SearchSimilar(ctx, []float32{1}, domain.SearchQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, results, 3) // the chunk without a hash is kept
+
+	assert.NoError(t, repo.DeleteChunksByDocument(ctx, docID))
+	results, err = repo.SearchSimilar(ctx, []float32{1}, domain.SearchQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestMemoryVectorRepo_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	ctx := context.Background()
+
+	repo, err := OpenMemoryVectorRepo(path)
+	assert.NoError(t, err)
+	chunk := memoryChunk(uuid.New(), 0, "a", 0.6, 0.8)
+	assert.NoError(t, repo.SaveChunk(ctx, chunk))
+
+	// Changes to the caller's chunk after saving are not stored
+	chunk.Embedding[0] = 100
+	assert.NoError(t, repo.Close())
+
+	reopened, err := OpenMemoryVectorRepo(path)
+	assert.NoError(t, err)
+	results, err := reopened.SearchSimilar(ctx, []float32{0.6, 0.8}, domain.SearchQuery{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{chunk.ID}, chunkIDs(results))
+	assert.Equal(t, "chunk a", results[0].Content)
+
+	assert.NoError(t, reopened.DeleteChunksByDocument(ctx, chunk.DocumentID))
+	assert.NoError(t, reopened.Close())
+	reopened, err = OpenMemoryVectorRepo(path)
+	assert.NoError(t, err)
+	hashes, err := reopened.ChunkHashes(ctx, chunk.DocumentID)
+	assert.NoError(t, err)
+	assert.Empty(t, hashes)
+}
+
+func TestMemoryVectorRepo_BatchesSnapshotWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	ctx := context.Background()
+
+	repo, err := OpenMemoryVectorRepo(path)
+	assert.NoError(t, err)
+	repo.FlushDelay = 10 * time.Millisecond
+	docID := uuid.New()
+	for i := range 50 {
+		assert.NoError(t, repo.SaveChunk(ctx, memoryChunk(docID, i, strconv.Itoa(i), 1)))
+	}
+	// Nothing is written until the delay is up
+	assert.NoFileExists(t, path)
+
+	assert.Eventually(t, func() bool {
+		reopened, err := OpenMemoryVectorRepo(path)
+		if err != nil {
+			return false
+		}
+		hashes, _ := reopened.ChunkHashes(ctx, docID)
+		return len(hashes) == 50
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestMemoryVectorRepo_Concurrent(t *testing.T) {
+	repo := NewMemoryVectorRepo()
+	ctx := context.Background()
+	docID := uuid.New()
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, repo.SaveChunk(ctx, memoryChunk(docID, i, "h", 1, float32(i))))
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := repo.SearchSimilar(ctx, []float32{1, 1}, domain.SearchQuery{Limit: 5})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	hashes, err := repo.ChunkHashes(ctx, docID)
+	assert.NoError(t, err)
+	assert.Len(t, hashes, 20)
+}
+
+func chunkIDs(chunks []*domain.DocumentChunk) []uuid.UUID {
+	ids := make([]uuid.UUID, len(chunks))
+	for i, c := range chunks {
+		ids[i] = c.ID
+	}
+	return ids
+}
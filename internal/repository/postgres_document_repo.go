@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"backend/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const documentColumns = `id, filename, content_hash, subject, chapter, language, page_count, chunk_count, status, user_id, chunk_strategy, created_at, updated_at`
+
+type PostgresDocumentRepo struct {
+	db *sqlx.DB
+}
+
+func NewPostgresDocumentRepo(db *sqlx.DB) *PostgresDocumentRepo {
+	return &PostgresDocumentRepo{db: db}
+}
+
+// CreateDocument inserts the document row and its original file in one transaction
+func (r *PostgresDocumentRepo) CreateDocument(ctx context.Context, doc *domain.Document, content []byte) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO documents (` + documentColumns + `)
+			  VALUES (:id, :filename, :content_hash, :subject, :chapter, :language, :page_count, :chunk_count, :status, :user_id, :chunk_strategy, :created_at, :updated_at)`
+	if _, err := tx.NamedExecContext(ctx, query, doc); err != nil {
+		return fmt.Errorf("insert document failed: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO document_files (document_id, content) VALUES ($1, $2)`, doc.ID, content); err != nil {
+		return fmt.Errorf("insert document file failed: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresDocumentRepo) GetDocument(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
+	var doc domain.Document
+	err := r.db.GetContext(ctx, &doc, `SELECT `+documentColumns+` FROM documents WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get document failed: %w", err)
+	}
+	return &doc, nil
+}
+
+func (r *PostgresDocumentRepo) GetDocumentContent(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	var content []byte
+	err := r.db.GetContext(ctx, &content, `SELECT content FROM document_files WHERE document_id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get document content failed: %w", err)
+	}
+	return content, nil
+}
+
+func (r *PostgresDocumentRepo) ListDocuments(ctx context.Context, filter domain.DocumentFilter) ([]*domain.Document, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+
+	if filter.Subject != "" {
+		add("subject =", filter.Subject)
+	}
+	if filter.Chapter > 0 {
+		add("chapter =", filter.Chapter)
+	}
+	if filter.Language != "" {
+		add("language =", filter.Language)
+	}
+	if filter.UserID != "" {
+		add("user_id =", filter.UserID)
+	}
+	if filter.Filename != "" {
+		add("filename =", filter.Filename)
+	}
+	if filter.ContentHash != "" {
+		add("content_hash =", filter.ContentHash)
+	}
+
+	query := `SELECT ` + documentColumns + ` FROM documents`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at DESC`
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += ` OFFSET $` + strconv.Itoa(len(args))
+	}
+
+	docs := []*domain.Document{}
+	if err := r.db.SelectContext(ctx, &docs, query, args...); err != nil {
+		return nil, fmt.Errorf("list documents failed: %w", err)
+	}
+	return docs, nil
+}
+
+func (r *PostgresDocumentRepo) UpdateDocument(ctx context.Context, doc *domain.Document) error {
+	return updateDocument(ctx, r.db, doc)
+}
+
+// ReplaceDocumentFile updates the document row and its stored file in one transaction
+func (r *PostgresDocumentRepo) ReplaceDocumentFile(ctx context.Context, doc *domain.Document, content []byte) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateDocument(ctx, tx, doc); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE document_files SET content = $2 WHERE document_id = $1`, doc.ID, content); err != nil {
+		return fmt.Errorf("update document file failed: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func updateDocument(ctx context.Context, db sqlx.ExtContext, doc *domain.Document) error {
+	query := `UPDATE documents
+			  SET filename = :filename, content_hash = :content_hash, subject = :subject, chapter = :chapter, language = :language,
+			      page_count = :page_count, chunk_count = :chunk_count, status = :status, chunk_strategy = :chunk_strategy, updated_at = :updated_at
+			  WHERE id = :id`
+
+	res, err := sqlx.NamedExecContext(ctx, db, query, doc)
+	if err != nil {
+		return fmt.Errorf("update document failed: %w", err)
+	}
+	return expectAffected(res)
+}
+
+// DeleteDocument removes the chunks, the stored file and the document row in one transaction
+func (r *PostgresDocumentRepo) DeleteDocument(ctx context.Context, id uuid.UUID) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM embeddings WHERE document_id = $1`, id); err != nil {
+		return fmt.Errorf("delete chunks failed: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM document_files WHERE document_id = $1`, id); err != nil {
+		return fmt.Errorf("delete document file failed: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM documents WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete document failed: %w", err)
+	}
+	if err := expectAffected(res); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// expectAffected maps an UPDATE/DELETE that touched no rows to domain.ErrNotFound
+func expectAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"backend/internal/domain"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+var documentRowColumns = []string{"id", "filename", "content_hash", "subject", "chapter", "language", "page_count", "chunk_count", "status", "user_id", "chunk_strategy", "created_at", "updated_at"}
+
+func newTestDocument() *domain.Document {
+	now := time.Now()
+	return &domain.Document{
+		ID:            uuid.New(),
+		Filename:      "chapter1.pdf",
+		ContentHash:   "abc123",
+		Subject:       "Physics",
+		Chapter:       1,
+		Language:      "en",
+		Status:        domain.DocumentStatusProcessing,
+		UserID:        "user-1",
+		ChunkStrategy: domain.ChunkStrategyStructured,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+func TestCreateDocument(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresDocumentRepo(sqlx.NewDb(db, "postgres"))
+	doc := newTestDocument()
+	content := []byte("%PDF-1.4")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO documents (id, filename, content_hash, subject, chapter, language, page_count, chunk_count, status, user_id, chunk_strategy, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`)).
+		WithArgs(doc.ID, doc.Filename, doc.ContentHash, doc.Subject, doc.Chapter, doc.Language, 0, 0, doc.Status, doc.UserID, doc.ChunkStrategy, doc.CreatedAt, doc.UpdatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO document_files (document_id, content) VALUES ($1, $2)`)).
+		WithArgs(doc.ID, content).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.CreateDocument(context.Background(), doc, content)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDocument(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresDocumentRepo(sqlx.NewDb(db, "postgres"))
+	doc := newTestDocument()
+
+	rows := sqlmock.NewRows(documentRowColumns).
+		AddRow(doc.ID, doc.Filename, doc.ContentHash, doc.Subject, doc.Chapter, doc.Language, 12, 40, "ready", doc.UserID, "structured", doc.CreatedAt, doc.UpdatedAt)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, filename, content_hash, subject, chapter, language, page_count, chunk_count, status, user_id, chunk_strategy, created_at, updated_at FROM documents WHERE id = $1`)).
+		WithArgs(doc.ID).
+		WillReturnRows(rows)
+
+	got, err := repo.GetDocument(context.Background(), doc.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.ID, got.ID)
+	assert.Equal(t, 40, got.ChunkCount)
+	assert.Equal(t, domain.DocumentStatusReady, got.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDocument_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresDocumentRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM documents WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows(documentRowColumns))
+
+	_, err = repo.GetDocument(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListDocuments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresDocumentRepo(sqlx.NewDb(db, "postgres"))
+	doc := newTestDocument()
+
+	rows := sqlmock.NewRows(documentRowColumns).
+		AddRow(doc.ID, doc.Filename, doc.ContentHash, doc.Subject, doc.Chapter, doc.Language, 12, 40, "ready", doc.UserID, "structured", doc.CreatedAt, doc.UpdatedAt)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, filename, content_hash, subject, chapter, language, page_count, chunk_count, status, user_id, chunk_strategy, created_at, updated_at FROM documents WHERE subject = $1 AND chapter = $2 ORDER BY created_at DESC LIMIT $3 OFFSET $4`)).
+		WithArgs("Physics", 1, 10, 20).
+		WillReturnRows(rows)
+
+	docs, err := repo.ListDocuments(context.Background(), domain.DocumentFilter{Subject: "Physics", Chapter: 1, Limit: 10, Offset: 20})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListDocuments_ByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresDocumentRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM documents WHERE language = $1 AND filename = $2 AND content_hash = $3 ORDER BY created_at DESC LIMIT $4`)).
+		WithArgs("en", "chapter1.pdf", "abc123", 1).
+		WillReturnRows(sqlmock.NewRows(documentRowColumns))
+
+	docs, err := repo.ListDocuments(context.Background(), domain.DocumentFilter{Language: "en", Filename: "chapter1.pdf", ContentHash: "abc123", Limit: 1})
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceDocumentFile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresDocumentRepo(sqlx.NewDb(db, "postgres"))
+	doc := newTestDocument()
+	content := []byte("%PDF-1.5")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE documents`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE document_files SET content = $2 WHERE document_id = $1`)).
+		WithArgs(doc.ID, content).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.ReplaceDocumentFile(context.Background(), doc, content)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteDocument(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresDocumentRepo(sqlx.NewDb(db, "postgres"))
+	id := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM embeddings WHERE document_id = $1`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 30))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM document_files WHERE document_id = $1`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM documents WHERE id = $1`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.DeleteDocument(context.Background(), id)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteDocument_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresDocumentRepo(sqlx.NewDb(db, "postgres"))
+	id := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM embeddings WHERE document_id = $1`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM document_files WHERE document_id = $1`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM documents WHERE id = $1`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.DeleteDocument(context.Background(), id)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const jobColumns = `id, document_id, status, chunks_processed, chunks_total, error, attempts, rebuild, locked_until, created_at, updated_at`
+
+// PostgresJobRepo is a job queue backed by the ingestion_jobs table. Workers
+// claim jobs with FOR UPDATE SKIP LOCKED, so several server instances can
+// share the same queue.
+type PostgresJobRepo struct {
+	db *sqlx.DB
+}
+
+func NewPostgresJobRepo(db *sqlx.DB) *PostgresJobRepo {
+	return &PostgresJobRepo{db: db}
+}
+
+func (r *PostgresJobRepo) CreateJob(ctx context.Context, job *domain.IngestionJob) error {
+	query := `INSERT INTO ingestion_jobs (` + jobColumns + `)
+			  VALUES (:id, :document_id, :status, :chunks_processed, :chunks_total, :error, :attempts, :rebuild, :locked_until, :created_at, :updated_at)`
+
+	if _, err := r.db.NamedExecContext(ctx, query, job); err != nil {
+		return fmt.Errorf("insert job failed: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresJobRepo) GetJob(ctx context.Context, id uuid.UUID) (*domain.IngestionJob, error) {
+	var job domain.IngestionJob
+	err := r.db.GetContext(ctx, &job, `SELECT `+jobColumns+` FROM ingestion_jobs WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get job failed: %w", err)
+	}
+	return &job, nil
+}
+
+func (r *PostgresJobRepo) ActiveJob(ctx context.Context, documentID uuid.UUID) (*domain.IngestionJob, error) {
+	var job domain.IngestionJob
+	err := r.db.GetContext(ctx, &job, `SELECT `+jobColumns+` FROM ingestion_jobs
+		WHERE document_id = $1 AND status IN ('queued', 'running') ORDER BY created_at DESC LIMIT 1`, documentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get active job failed: %w", err)
+	}
+	return &job, nil
+}
+
+func (r *PostgresJobRepo) ClaimJob(ctx context.Context, lease time.Duration) (*domain.IngestionJob, error) {
+	query := `UPDATE ingestion_jobs
+			  SET status = 'running', attempts = attempts + 1, locked_until = now() + make_interval(secs => $1), updated_at = now()
+			  WHERE id = (
+			      SELECT id FROM ingestion_jobs
+			      WHERE status = 'queued' OR (status = 'running' AND locked_until < now())
+			      ORDER BY created_at
+			      LIMIT 1
+			      FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING ` + jobColumns
+
+	var job domain.IngestionJob
+	err := r.db.GetContext(ctx, &job, query, lease.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claim job failed: %w", err)
+	}
+	return &job, nil
+}
+
+func (r *PostgresJobRepo) UpdateJobProgress(ctx context.Context, id uuid.UUID, processed, total int, lease time.Duration) error {
+	query := `UPDATE ingestion_jobs
+			  SET chunks_processed = $2, chunks_total = $3, locked_until = now() + make_interval(secs => $4), updated_at = now()
+			  WHERE id = $1`
+
+	res, err := r.db.ExecContext(ctx, query, id, processed, total, lease.Seconds())
+	if err != nil {
+		return fmt.Errorf("update job progress failed: %w", err)
+	}
+	return expectAffected(res)
+}
+
+func (r *PostgresJobRepo) RenewJobLease(ctx context.Context, id uuid.UUID, lease time.Duration) error {
+	query := `UPDATE ingestion_jobs
+			  SET locked_until = now() + make_interval(secs => $2)
+			  WHERE id = $1 AND status = 'running'`
+
+	res, err := r.db.ExecContext(ctx, query, id, lease.Seconds())
+	if err != nil {
+		return fmt.Errorf("renew job lease failed: %w", err)
+	}
+	return expectAffected(res)
+}
+
+func (r *PostgresJobRepo) FinishJob(ctx context.Context, job *domain.IngestionJob) error {
+	query := `UPDATE ingestion_jobs
+			  SET status = :status, chunks_processed = :chunks_processed, chunks_total = :chunks_total, error = :error,
+			      locked_until = NULL, updated_at = now()
+			  WHERE id = :id`
+
+	res, err := r.db.NamedExecContext(ctx, query, job)
+	if err != nil {
+		return fmt.Errorf("finish job failed: %w", err)
+	}
+	return expectAffected(res)
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"backend/internal/domain"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+var jobRowColumns = []string{"id", "document_id", "status", "chunks_processed", "chunks_total", "error", "attempts", "rebuild", "locked_until", "created_at", "updated_at"}
+
+func TestCreateJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresJobRepo(sqlx.NewDb(db, "postgres"))
+	now := time.Now()
+	job := &domain.IngestionJob{ID: uuid.New(), DocumentID: uuid.New(), Status: domain.JobStatusQueued, CreatedAt: now, UpdatedAt: now}
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO ingestion_jobs (id, document_id, status, chunks_processed, chunks_total, error, attempts, rebuild, locked_until, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`)).
+		WithArgs(job.ID, job.DocumentID, job.Status, 0, 0, "", 0, false, nil, now, now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = repo.CreateJob(context.Background(), job)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActiveJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresJobRepo(sqlx.NewDb(db, "postgres"))
+	docID := uuid.New()
+
+	rows := sqlmock.NewRows(jobRowColumns).
+		AddRow(uuid.New(), docID, "queued", 0, 0, "", 0, false, nil, time.Now(), time.Now())
+	mock.ExpectQuery(`SELECT .* FROM ingestion_jobs\s+WHERE document_id = \$1 AND status IN \('queued', 'running'\)`).
+		WithArgs(docID).
+		WillReturnRows(rows)
+
+	job, err := repo.ActiveJob(context.Background(), docID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.JobStatusQueued, job.Status)
+
+	mock.ExpectQuery(`SELECT .* FROM ingestion_jobs`).
+		WithArgs(docID).
+		WillReturnRows(sqlmock.NewRows(jobRowColumns))
+
+	_, err = repo.ActiveJob(context.Background(), docID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresJobRepo(sqlx.NewDb(db, "postgres"))
+	id := uuid.New()
+	lockedUntil := time.Now().Add(time.Minute)
+
+	rows := sqlmock.NewRows(jobRowColumns).
+		AddRow(id, uuid.New(), "running", 12, 40, "", 2, true, lockedUntil, time.Now(), time.Now())
+	mock.ExpectQuery(`UPDATE ingestion_jobs SET status = 'running'.*FOR UPDATE SKIP LOCKED`).
+		WithArgs(float64(60)).
+		WillReturnRows(rows)
+
+	job, err := repo.ClaimJob(context.Background(), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, id, job.ID)
+	assert.Equal(t, domain.JobStatusRunning, job.Status)
+	assert.Equal(t, 12, job.ChunksProcessed)
+	assert.True(t, job.Rebuild)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimJob_Empty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresJobRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(`UPDATE ingestion_jobs`).WillReturnRows(sqlmock.NewRows(jobRowColumns))
+
+	_, err = repo.ClaimJob(context.Background(), time.Minute)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateJobProgress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresJobRepo(sqlx.NewDb(db, "postgres"))
+	id := uuid.New()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE ingestion_jobs SET chunks_processed = $2, chunks_total = $3`)).
+		WithArgs(id, 5, 40, float64(30)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.UpdateJobProgress(context.Background(), id, 5, 40, 30*time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRenewJobLease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresJobRepo(sqlx.NewDb(db, "postgres"))
+	id := uuid.New()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE ingestion_jobs SET locked_until = now() + make_interval(secs => $2) WHERE id = $1 AND status = 'running'`)).
+		WithArgs(id, float64(30)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.RenewJobLease(context.Background(), id, 30*time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFinishJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresJobRepo(sqlx.NewDb(db, "postgres"))
+	job := &domain.IngestionJob{ID: uuid.New(), Status: domain.JobStatusFailed, ChunksProcessed: 249, ChunksTotal: 300, Error: "quota exceeded"}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE ingestion_jobs SET status = $1, chunks_processed = $2, chunks_total = $3, error = $4, locked_until = NULL, updated_at = now() WHERE id = $5`)).
+		WithArgs(job.Status, 249, 300, "quota exceeded", job.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.FinishJob(context.Background(), job)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
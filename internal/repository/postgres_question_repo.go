@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"backend/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const questionColumns = `id, type, stem, options, answer, explanation, difficulty, marks, source_chunk_ids, subject, chapter, topic, language, user_id, created_at, updated_at`
+
+// questionDocument is the text indexed for full-text search. The function is
+// defined by the schema migrations, and the query must call it exactly like
+// this for the search index to be used.
+const questionDocument = `question_document(stem, options, explanation)`
+
+// likeEscaper makes LIKE's wildcards, and its escape character, match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+type PostgresQuestionRepo struct {
+	db *sqlx.DB
+}
+
+func NewPostgresQuestionRepo(db *sqlx.DB) *PostgresQuestionRepo {
+	return &PostgresQuestionRepo{db: db}
+}
+
+// questionRow maps the array columns, which lib/pq cannot scan into plain slices
+type questionRow struct {
+	domain.Question
+	Options        pq.StringArray `db:"options"`
+	SourceChunkIDs pq.StringArray `db:"source_chunk_ids"`
+}
+
+func newQuestionRow(q *domain.Question) questionRow {
+	ids := make(pq.StringArray, len(q.SourceChunkIDs))
+	for i, id := range q.SourceChunkIDs {
+		ids[i] = id.String()
+	}
+	options := pq.StringArray(q.Options)
+	if options == nil {
+		options = pq.StringArray{}
+	}
+	return questionRow{Question: *q, Options: options, SourceChunkIDs: ids}
+}
+
+func (r questionRow) toDomain() (*domain.Question, error) {
+	q := r.Question
+	q.Options = []string(r.Options)
+	q.SourceChunkIDs = make([]uuid.UUID, 0, len(r.SourceChunkIDs))
+	for _, s := range r.SourceChunkIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("question %s has invalid source chunk id %q: %w", q.ID, s, err)
+		}
+		q.SourceChunkIDs = append(q.SourceChunkIDs, id)
+	}
+	return &q, nil
+}
+
+func (r *PostgresQuestionRepo) SaveQuestions(ctx context.Context, questions []*domain.Question) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO questions (` + questionColumns + `)
+			  VALUES (:id, :type, :stem, :options, :answer, :explanation, :difficulty, :marks, :source_chunk_ids, :subject, :chapter, :topic, :language, :user_id, :created_at, :updated_at)`
+	for i, q := range questions {
+		if _, err := tx.NamedExecContext(ctx, query, newQuestionRow(q)); err != nil {
+			return fmt.Errorf("insert question %d failed: %w", i, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgresQuestionRepo) GetQuestion(ctx context.Context, id uuid.UUID) (*domain.Question, error) {
+	var row questionRow
+	err := r.db.GetContext(ctx, &row, `SELECT `+questionColumns+` FROM questions WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get question failed: %w", err)
+	}
+	return row.toDomain()
+}
+
+func (r *PostgresQuestionRepo) ListQuestions(ctx context.Context, filter domain.QuestionFilter) ([]*domain.Question, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+
+	if filter.Subject != "" {
+		add("subject =", filter.Subject)
+	}
+	if filter.Chapter > 0 {
+		add("chapter =", filter.Chapter)
+	}
+	if filter.Topic != "" {
+		add("topic ILIKE", "%"+likeEscaper.Replace(filter.Topic)+"%")
+		conditions[len(conditions)-1] += ` ESCAPE '\'`
+	}
+	if filter.Type != "" {
+		add("type =", filter.Type)
+	}
+	if filter.Difficulty != "" {
+		add("difficulty =", filter.Difficulty)
+	}
+	if filter.Language != "" {
+		add("language =", filter.Language)
+	}
+	if filter.UserID != "" {
+		add("user_id =", filter.UserID)
+	}
+
+	order := ` ORDER BY created_at DESC`
+	if filter.Search != "" {
+		args = append(args, filter.Search)
+		tsquery := `plainto_tsquery('simple', $` + strconv.Itoa(len(args)) + `)`
+		conditions = append(conditions, questionDocument+` @@ `+tsquery)
+		order = ` ORDER BY ts_rank(` + questionDocument + `, ` + tsquery + `) DESC, created_at DESC`
+	}
+
+	query := `SELECT ` + questionColumns + ` FROM questions`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += order
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += ` OFFSET $` + strconv.Itoa(len(args))
+	}
+
+	var rows []questionRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("list questions failed: %w", err)
+	}
+
+	questions := make([]*domain.Question, 0, len(rows))
+	for _, row := range rows {
+		q, err := row.toDomain()
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
+
+// UpdateQuestion saves edits to a question's content. Where it came from
+// (sources, subject, chapter, topic, language, author) is left unchanged.
+func (r *PostgresQuestionRepo) UpdateQuestion(ctx context.Context, question *domain.Question) error {
+	query := `UPDATE questions
+			  SET type = :type, stem = :stem, options = :options, answer = :answer, explanation = :explanation,
+			      difficulty = :difficulty, marks = :marks, updated_at = :updated_at
+			  WHERE id = :id`
+
+	res, err := r.db.NamedExecContext(ctx, query, newQuestionRow(question))
+	if err != nil {
+		return fmt.Errorf("update question failed: %w", err)
+	}
+	return expectAffected(res)
+}
+
+func (r *PostgresQuestionRepo) DeleteQuestion(ctx context.Context, id uuid.UUID) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM questions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete question failed: %w", err)
+	}
+	return expectAffected(res)
+}
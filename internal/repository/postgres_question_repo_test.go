@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"backend/internal/domain"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var questionRowColumns = []string{"id", "type", "stem", "options", "answer", "explanation", "difficulty", "marks", "source_chunk_ids", "subject", "chapter", "topic", "language", "user_id", "created_at", "updated_at"}
+
+func newTestQuestion() *domain.Question {
+	now := time.Now()
+	return &domain.Question{
+		ID:             uuid.New(),
+		Type:           domain.QuestionTypeMCQ,
+		Stem:           "Which law describes inertia?",
+		Options:        []string{"First", "Second", "Third"},
+		Answer:         "First",
+		Explanation:    "Newton's first law",
+		Difficulty:     domain.DifficultyEasy,
+		Marks:          1,
+		SourceChunkIDs: []uuid.UUID{uuid.New()},
+		Subject:        "Physics",
+		Chapter:        3,
+		Topic:          "Newton's laws",
+		Language:       "en",
+		UserID:         "user-1",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+func TestSaveQuestions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresQuestionRepo(sqlx.NewDb(db, "postgres"))
+	q := newTestQuestion()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO questions (id, type, stem, options, answer, explanation, difficulty, marks, source_chunk_ids, subject, chapter, topic, language, user_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`)).
+		WithArgs(q.ID, q.Type, q.Stem, pq.StringArray{"First", "Second", "Third"}, q.Answer, q.Explanation, q.Difficulty, q.Marks,
+			pq.StringArray{q.SourceChunkIDs[0].String()}, q.Subject, q.Chapter, q.Topic, q.Language, q.UserID, q.CreatedAt, q.UpdatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.SaveQuestions(context.Background(), []*domain.Question{q})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetQuestion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresQuestionRepo(sqlx.NewDb(db, "postgres"))
+	q := newTestQuestion()
+
+	rows := sqlmock.NewRows(questionRowColumns).
+		AddRow(q.ID, q.Type, q.Stem, "{First,Second,Third}", q.Answer, q.Explanation, q.Difficulty, q.Marks, "{"+q.SourceChunkIDs[0].String()+"}",
+			q.Subject, q.Chapter, q.Topic, q.Language, q.UserID, q.CreatedAt, q.UpdatedAt)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM questions WHERE id = $1`)).WithArgs(q.ID).WillReturnRows(rows)
+
+	got, err := repo.GetQuestion(context.Background(), q.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, q.Options, got.Options)
+	assert.Equal(t, q.SourceChunkIDs, got.SourceChunkIDs)
+	assert.Equal(t, q.Topic, got.Topic)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetQuestion_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresQuestionRepo(sqlx.NewDb(db, "postgres"))
+	id := uuid.New()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM questions WHERE id = $1`)).WithArgs(id).WillReturnRows(sqlmock.NewRows(questionRowColumns))
+
+	_, err = repo.GetQuestion(context.Background(), id)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func TestListQuestions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresQuestionRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM questions WHERE subject = $1 AND chapter = $2 AND topic ILIKE $3 ESCAPE '\' AND type = $4 AND difficulty = $5 ORDER BY created_at DESC LIMIT $6 OFFSET $7`)).
+		WithArgs("Physics", 3, "%newton%", domain.QuestionTypeMCQ, domain.DifficultyHard, 10, 20).
+		WillReturnRows(sqlmock.NewRows(questionRowColumns))
+
+	questions, err := repo.ListQuestions(context.Background(), domain.QuestionFilter{
+		Subject:    "Physics",
+		Chapter:    3,
+		Topic:      "newton",
+		Type:       domain.QuestionTypeMCQ,
+		Difficulty: domain.DifficultyHard,
+		Limit:      10,
+		Offset:     20,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, questions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListQuestions_TopicIsLiteral(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresQuestionRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM questions WHERE topic ILIKE $1 ESCAPE '\' ORDER BY created_at DESC`)).
+		WithArgs(`%100\% a\_b c\\d%`, 10).
+		WillReturnRows(sqlmock.NewRows(questionRowColumns))
+
+	_, err = repo.ListQuestions(context.Background(), domain.QuestionFilter{Topic: `100% a_b c\d`, Limit: 10})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListQuestions_Search(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresQuestionRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM questions WHERE language = $1 AND `+questionDocument+` @@ plainto_tsquery('simple', $2) ORDER BY ts_rank(`+questionDocument+`, plainto_tsquery('simple', $2)) DESC, created_at DESC LIMIT $3`)).
+		WithArgs("bn", "জড়তা", 5).
+		WillReturnRows(sqlmock.NewRows(questionRowColumns))
+
+	_, err = repo.ListQuestions(context.Background(), domain.QuestionFilter{Language: "bn", Search: "জড়তা", Limit: 5})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateQuestion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresQuestionRepo(sqlx.NewDb(db, "postgres"))
+	q := newTestQuestion()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE questions`)).
+		WithArgs(q.Type, q.Stem, pq.StringArray(q.Options), q.Answer, q.Explanation, q.Difficulty, q.Marks, q.UpdatedAt, q.ID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.UpdateQuestion(context.Background(), q)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteQuestion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresQuestionRepo(sqlx.NewDb(db, "postgres"))
+	id := uuid.New()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM questions WHERE id = $1`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.DeleteQuestion(context.Background(), id)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// vectorIndexName is the approximate nearest neighbour index on embeddings.embedding
+const vectorIndexName = "embeddings_embedding_idx"
+
+// VectorIndex describes the approximate nearest neighbour index SearchSimilar
+// uses. Without one every search compares the query with every row.
+type VectorIndex struct {
+	// Method is "hnsw", "ivfflat" or "none"
+	Method string
+	// M and EfConstruction shape the HNSW graph: more links and candidates
+	// give better recall for a bigger index that is slower to build
+	M              int
+	EfConstruction int
+	// Lists is how many clusters IVFFlat divides the rows into. Zero lets
+	// RebuildIndex pick one from the row count.
+	Lists int
+}
+
+// DefaultVectorIndex is an HNSW index with pgvector's default parameters
+func DefaultVectorIndex() VectorIndex {
+	return VectorIndex{Method: "hnsw", M: 16, EfConstruction: 64}
+}
+
+// Validate checks the parameters against pgvector's limits
+func (ix VectorIndex) Validate() error {
+	switch ix.Method {
+	case "none":
+	case "hnsw":
+		if ix.M < 2 || ix.M > 100 {
+			return fmt.Errorf("hnsw m must be between 2 and 100, got %d", ix.M)
+		}
+		if ix.EfConstruction < 2*ix.M || ix.EfConstruction > 1000 {
+			return fmt.Errorf("hnsw ef_construction must be between 2*m (%d) and 1000, got %d", 2*ix.M, ix.EfConstruction)
+		}
+	case "ivfflat":
+		if ix.Lists < 0 || ix.Lists > 32768 {
+			return fmt.Errorf("ivfflat lists must be 0 (auto) or between 1 and 32768, got %d", ix.Lists)
+		}
+	default:
+		return fmt.Errorf("unknown vector index method %q", ix.Method)
+	}
+	return nil
+}
+
+// CreateSQL is the statement creating the index, or "" for method "none".
+// Migrations use it so the index they create follows configuration.
+func (ix VectorIndex) CreateSQL() string {
+	return ix.createSQL(vectorIndexName, false)
+}
+
+func (ix VectorIndex) createSQL(name string, concurrently bool) string {
+	create := "CREATE INDEX "
+	if concurrently {
+		create += "CONCURRENTLY "
+	}
+	create += name + " ON embeddings USING "
+
+	switch ix.Method {
+	case "hnsw":
+		return create + fmt.Sprintf("hnsw (embedding vector_cosine_ops) WITH (m = %d, ef_construction = %d)", ix.M, ix.EfConstruction)
+	case "ivfflat":
+		if ix.Lists == 0 {
+			// pgvector's default
+			return create + "ivfflat (embedding vector_cosine_ops)"
+		}
+		return create + fmt.Sprintf("ivfflat (embedding vector_cosine_ops) WITH (lists = %d)", ix.Lists)
+	default:
+		return ""
+	}
+}
+
+// recommendedLists follows pgvector's advice for IVFFlat: rows / 1000 up to a
+// million rows and sqrt(rows) beyond
+func recommendedLists(rows int64) int {
+	if rows > 1_000_000 {
+		return int(math.Sqrt(float64(rows)))
+	}
+	return max(int(rows/1000), 1)
+}
+
+// RebuildIndex replaces the vector index with one built as ix describes.
+// The new index is built concurrently under a temporary name and swapped in
+// afterwards, so searches and ingestion carry on during the build.
+func (r *PostgresVectorRepo) RebuildIndex(ctx context.Context, ix VectorIndex) error {
+	if err := ix.Validate(); err != nil {
+		return err
+	}
+
+	if ix.Method == "ivfflat" && ix.Lists == 0 {
+		var rows int64
+		if err := r.db.GetContext(ctx, &rows, `SELECT count(*) FROM embeddings`); err != nil {
+			return fmt.Errorf("count embeddings failed: %w", err)
+		}
+		ix.Lists = recommendedLists(rows)
+	}
+
+	tmp := vectorIndexName + "_new"
+	// A failed concurrent build leaves an invalid index behind
+	if _, err := r.db.ExecContext(ctx, `DROP INDEX IF EXISTS `+tmp); err != nil {
+		return fmt.Errorf("drop leftover index failed: %w", err)
+	}
+	if ix.Method != "none" {
+		if _, err := r.db.ExecContext(ctx, ix.createSQL(tmp, true)); err != nil {
+			r.db.ExecContext(context.WithoutCancel(ctx), `DROP INDEX IF EXISTS `+tmp)
+			return fmt.Errorf("build index failed: %w", err)
+		}
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DROP INDEX IF EXISTS `+vectorIndexName); err != nil {
+		return fmt.Errorf("drop old index failed: %w", err)
+	}
+	if ix.Method != "none" {
+		if _, err := tx.ExecContext(ctx, `ALTER INDEX `+tmp+` RENAME TO `+vectorIndexName); err != nil {
+			return fmt.Errorf("rename new index failed: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// IndexHealth describes the vector index and anything that makes searches
+// slower or less accurate than they should be
+type IndexHealth struct {
+	Name string
+	// Method is "hnsw", "ivfflat", or "none" if there is no index
+	Method string
+	// Options are the build parameters, e.g. "m=16,ef_construction=64"
+	Options string
+	// Valid is false after an interrupted concurrent build; Postgres keeps
+	// such an index up to date but never uses it
+	Valid     bool
+	SizeBytes int64
+	// Scans counts the searches that used the index since statistics were reset
+	Scans int64
+	Rows  int64
+	// Warnings are problems worth fixing, most with "cli index rebuild"
+	Warnings []string
+}
+
+// IndexHealth reports on the vector index
+func (r *PostgresVectorRepo) IndexHealth(ctx context.Context) (*IndexHealth, error) {
+	health := &IndexHealth{Name: vectorIndexName, Method: "none"}
+	if err := r.db.GetContext(ctx, &health.Rows, `SELECT count(*) FROM embeddings`); err != nil {
+		return nil, fmt.Errorf("count embeddings failed: %w", err)
+	}
+
+	var row struct {
+		Method    string `db:"method"`
+		Options   string `db:"options"`
+		Valid     bool   `db:"valid"`
+		SizeBytes int64  `db:"size_bytes"`
+		Scans     int64  `db:"scans"`
+	}
+	query := `SELECT am.amname AS method,
+			  COALESCE(array_to_string(c.reloptions, ','), '') AS options,
+			  i.indisvalid AS valid,
+			  pg_relation_size(c.oid) AS size_bytes,
+			  COALESCE(s.idx_scan, 0) AS scans
+			  FROM pg_index i
+			  JOIN pg_class c ON c.oid = i.indexrelid
+			  JOIN pg_am am ON am.oid = c.relam
+			  LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = i.indexrelid
+			  WHERE i.indrelid = 'embeddings'::regclass AND c.relname = $1`
+	err := r.db.GetContext(ctx, &row, query, vectorIndexName)
+	if errors.Is(err, sql.ErrNoRows) {
+		health.Warnings = append(health.Warnings, "there is no vector index, so every search scans all rows")
+		return health, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inspect index failed: %w", err)
+	}
+
+	health.Method = row.Method
+	health.Options = row.Options
+	health.Valid = row.Valid
+	health.SizeBytes = row.SizeBytes
+	health.Scans = row.Scans
+
+	if !health.Valid {
+		health.Warnings = append(health.Warnings, "the index is invalid, probably because a build was interrupted")
+	}
+	if health.Method == "ivfflat" {
+		// IVFFlat clusters are fixed when the index is built, so an index
+		// built over far fewer or far more rows searches badly
+		lists := 100
+		for _, opt := range strings.Split(health.Options, ",") {
+			if v, ok := strings.CutPrefix(opt, "lists="); ok {
+				lists, _ = strconv.Atoi(v)
+			}
+		}
+		if want := recommendedLists(health.Rows); lists > 4*want || lists*4 < want {
+			health.Warnings = append(health.Warnings,
+				fmt.Sprintf("the index has %d lists but %d rows call for about %d", lists, health.Rows, want))
+		}
+	}
+	return health, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVectorIndexValidate(t *testing.T) {
+	assert.NoError(t, DefaultVectorIndex().Validate())
+	assert.NoError(t, VectorIndex{Method: "ivfflat"}.Validate())
+	assert.NoError(t, VectorIndex{Method: "none"}.Validate())
+
+	assert.ErrorContains(t, VectorIndex{Method: "hnsw", M: 1, EfConstruction: 64}.Validate(), "m must be between 2 and 100")
+	assert.ErrorContains(t, VectorIndex{Method: "hnsw", M: 32, EfConstruction: 40}.Validate(), "ef_construction must be between 2*m (64) and 1000")
+	assert.ErrorContains(t, VectorIndex{Method: "ivfflat", Lists: 40000}.Validate(), "lists must be 0 (auto) or between 1 and 32768")
+	assert.ErrorContains(t, VectorIndex{Method: "diskann"}.Validate(), `unknown vector index method "diskann"`)
+}
+
+func TestVectorIndexCreateSQL(t *testing.T) {
+	assert.Equal(t,
+		"CREATE INDEX embeddings_embedding_idx ON embeddings USING hnsw (embedding vector_cosine_ops) WITH (m = 16, ef_construction = 64)",
+		DefaultVectorIndex().CreateSQL())
+	assert.Equal(t,
+		"CREATE INDEX embeddings_embedding_idx ON embeddings USING ivfflat (embedding vector_cosine_ops)",
+		VectorIndex{Method: "ivfflat"}.CreateSQL())
+	assert.Empty(t, VectorIndex{Method: "none"}.CreateSQL())
+}
+
+func TestRecommendedLists(t *testing.T) {
+	assert.Equal(t, 1, recommendedLists(0))
+	assert.Equal(t, 250, recommendedLists(250_000))
+	assert.Equal(t, 2000, recommendedLists(4_000_000))
+}
+
+func TestRebuildIndex(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPostgresVectorRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM embeddings`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(50_000))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP INDEX IF EXISTS embeddings_embedding_idx_new`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX CONCURRENTLY embeddings_embedding_idx_new ON embeddings USING ivfflat (embedding vector_cosine_ops) WITH (lists = 50)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP INDEX IF EXISTS embeddings_embedding_idx`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER INDEX embeddings_embedding_idx_new RENAME TO embeddings_embedding_idx`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = repo.RebuildIndex(context.Background(), VectorIndex{Method: "ivfflat"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRebuildIndex_DropsFailedBuild(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPostgresVectorRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectExec(regexp.QuoteMeta(`DROP INDEX IF EXISTS embeddings_embedding_idx_new`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX CONCURRENTLY embeddings_embedding_idx_new ON embeddings USING hnsw`)).
+		WillReturnError(assert.AnError)
+	mock.ExpectExec(regexp.QuoteMeta(`DROP INDEX IF EXISTS embeddings_embedding_idx_new`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RebuildIndex(context.Background(), DefaultVectorIndex())
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIndexHealth(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPostgresVectorRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM embeddings`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(500_000))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM pg_index i`)).
+		WithArgs("embeddings_embedding_idx").
+		WillReturnRows(sqlmock.NewRows([]string{"method", "options", "valid", "size_bytes", "scans"}).
+			AddRow("ivfflat", "lists=10", false, 1<<20, 7))
+
+	health, err := repo.IndexHealth(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ivfflat", health.Method)
+	assert.Equal(t, int64(500_000), health.Rows)
+	assert.Equal(t, int64(7), health.Scans)
+	assert.Equal(t, []string{
+		"the index is invalid, probably because a build was interrupted",
+		"the index has 10 lists but 500000 rows call for about 500",
+	}, health.Warnings)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIndexHealth_NoIndex(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPostgresVectorRepo(sqlx.NewDb(db, "postgres"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM embeddings`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM pg_index i`)).WillReturnError(sql.ErrNoRows)
+
+	health, err := repo.IndexHealth(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "none", health.Method)
+	assert.Len(t, health.Warnings, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,16 +2,35 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"backend/internal/domain"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/pgvector/pgvector-go"
 )
 
+// chunkColumns are the columns searches return besides the score; embeddings are left out
+const chunkColumns = `id, document_id, chunk_index, content_hash, subject, chapter, section, content, language, page, page_end, created_at`
+
 type PostgresVectorRepo struct {
 	db *sqlx.DB
+	// Search tunes the vector index for every query that does not set its own
+	Search SearchParams
+}
+
+// SearchParams trade search speed for recall on an approximate vector index.
+// Zero keeps pgvector's default.
+type SearchParams struct {
+	// EfSearch is how many candidates an HNSW search keeps (default 40)
+	EfSearch int
+	// Probes is how many IVFFlat lists a search scans (default 1)
+	Probes int
 }
 
 func NewPostgresVectorRepo(db *sqlx.DB) *PostgresVectorRepo {
@@ -19,8 +38,115 @@ func NewPostgresVectorRepo(db *sqlx.DB) *PostgresVectorRepo {
 }
 
 func (r *PostgresVectorRepo) SaveChunk(ctx context.Context, chunk *domain.DocumentChunk) error {
-	query := `INSERT INTO embeddings (id, subject, chapter, content, embedding, language, page, created_at) 
-			  VALUES (:id, :subject, :chapter, :content, :embedding, :language, :page, :created_at)`
+	return insertChunk(ctx, r.db, chunk)
+}
+
+// SaveChunks inserts all chunks in a single transaction
+func (r *PostgresVectorRepo) SaveChunks(ctx context.Context, chunks []*domain.DocumentChunk) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, chunk := range chunks {
+		if err := insertChunk(ctx, tx, chunk); err != nil {
+			return fmt.Errorf("saving chunk %d failed: %w", i, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceChunks inserts add, updates the stored chunks in keep to their new
+// position and placement, and deletes the document's chunks whose content
+// hash is not in keep or that an added chunk supersedes, in one transaction,
+// so readers never see a half-synced document. Chunks stored before hashing
+// was introduced are left alone.
+func (r *PostgresVectorRepo) ReplaceChunks(ctx context.Context, documentID uuid.UUID, add []*domain.DocumentChunk, keep []*domain.DocumentChunk) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	added := make(map[string]bool, len(add))
+	addedHashes, addedIDs := pq.StringArray{}, pq.StringArray{}
+	for i, chunk := range add {
+		if err := insertChunk(ctx, tx, chunk); err != nil {
+			return fmt.Errorf("saving chunk %d failed: %w", i, err)
+		}
+		added[chunk.ContentHash] = true
+		addedHashes = append(addedHashes, chunk.ContentHash)
+		addedIDs = append(addedIDs, chunk.ID.String())
+	}
+
+	hashes := pq.StringArray{}
+	var moved, subjects, sections, languages pq.StringArray
+	var indexes, chapters, pages, pageEnds pq.Int64Array
+	for _, chunk := range keep {
+		hashes = append(hashes, chunk.ContentHash)
+		if added[chunk.ContentHash] {
+			continue
+		}
+		moved = append(moved, chunk.ContentHash)
+		indexes = append(indexes, int64(chunk.ChunkIndex))
+		subjects = append(subjects, chunk.Subject)
+		chapters = append(chapters, int64(chunk.Chapter))
+		sections = append(sections, chunk.Section)
+		languages = append(languages, chunk.Language)
+		pages = append(pages, int64(chunk.Page))
+		pageEnds = append(pageEnds, int64(chunk.PageEnd))
+	}
+
+	if len(moved) > 0 {
+		// Unchanged text may have moved to another page or chapter
+		_, err = tx.ExecContext(ctx, `UPDATE embeddings e
+			SET chunk_index = k.chunk_index, subject = k.subject, chapter = k.chapter, section = k.section,
+				language = k.language, page = k.page, page_end = k.page_end
+			FROM unnest($2::text[], $3::int[], $4::text[], $5::int[], $6::text[], $7::text[], $8::int[], $9::int[])
+				AS k(content_hash, chunk_index, subject, chapter, section, language, page, page_end)
+			WHERE e.document_id = $1 AND e.content_hash = k.content_hash`,
+			documentID, moved, indexes, subjects, chapters, sections, languages, pages, pageEnds)
+		if err != nil {
+			return fmt.Errorf("update kept chunks failed: %w", err)
+		}
+	}
+
+	// A rebuild adds new copies of chunks that are already stored
+	_, err = tx.ExecContext(ctx, `DELETE FROM embeddings WHERE document_id = $1
+		AND (content_hash <> ALL($2) OR (content_hash = ANY($3) AND id <> ALL($4::uuid[])))`,
+		documentID, hashes, addedHashes, addedIDs)
+	if err != nil {
+		return fmt.Errorf("delete stale chunks failed: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Dimensions returns the size of the vectors the embeddings column holds, or 0
+// if it takes any size
+func (r *PostgresVectorRepo) Dimensions(ctx context.Context) (int, error) {
+	var dims int
+	err := r.db.GetContext(ctx, &dims, `SELECT atttypmod FROM pg_attribute WHERE attrelid = 'embeddings'::regclass AND attname = 'embedding'`)
+	if err != nil {
+		return 0, fmt.Errorf("read embedding dimensions failed: %w", err)
+	}
+	return max(dims, 0), nil
+}
+
+func (r *PostgresVectorRepo) ChunkHashes(ctx context.Context, documentID uuid.UUID) ([]string, error) {
+	hashes := []string{}
+	err := r.db.SelectContext(ctx, &hashes, `SELECT content_hash FROM embeddings WHERE document_id = $1 AND content_hash IS NOT NULL`, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("list chunk hashes failed: %w", err)
+	}
+	return hashes, nil
+}
+
+func insertChunk(ctx context.Context, db sqlx.ExtContext, chunk *domain.DocumentChunk) error {
+	query := `INSERT INTO embeddings (id, document_id, chunk_index, content_hash, subject, chapter, section, content, embedding, language, page, page_end, created_at) 
+			  VALUES (:id, :document_id, :chunk_index, :content_hash, :subject, :chapter, :section, :content, :embedding, :language, :page, :page_end, :created_at)`
 
 	// map domain struct to db struct if needed, or use struct tags.
 	// We need to handle the []float32 -> pgvector.Vector conversion explicitly if sqlx doesn't handle it automatically with the driver.
@@ -36,26 +162,216 @@ func (r *PostgresVectorRepo) SaveChunk(ctx context.Context, chunk *domain.Docume
 		Embedding:     pgvector.NewVector(chunk.Embedding),
 	}
 
-	_, err := r.db.NamedExecContext(ctx, query, dbChunk)
+	_, err := sqlx.NamedExecContext(ctx, db, query, dbChunk)
 	return err
 }
 
-func (r *PostgresVectorRepo) SearchSimilar(ctx context.Context, embedding []float32, limit int, filter map[string]interface{}) ([]*domain.DocumentChunk, error) {
+func (r *PostgresVectorRepo) SearchSimilar(ctx context.Context, embedding []float32, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Basic similarity search using cosine distance (<=> operator)
 	// Note: sqlx named args for SELECT is tricky with order by operators sometimes, but we can use $1
+	where, args := buildWhereClause(q, 2)
 
-	query := `SELECT id, subject, chapter, content, language, page, created_at 
-			  FROM embeddings 
+	query := `SELECT ` + searchColumns(q) + `, 1 - (embedding <=> $1) AS score
+			  FROM embeddings` + where + `
 			  ORDER BY embedding <=> $1 
-			  LIMIT $2`
+			  LIMIT $` + strconv.Itoa(len(args)+2)
 
 	vector := pgvector.NewVector(embedding)
 
+	queryArgs := append([]interface{}{vector}, args...)
+	queryArgs = append(queryArgs, q.Limit)
+	if q.Offset > 0 {
+		query += ` OFFSET $` + strconv.Itoa(len(queryArgs)+1)
+		queryArgs = append(queryArgs, q.Offset)
+	}
+
+	params := r.Search
+	if q.EfSearch > 0 {
+		params.EfSearch = q.EfSearch
+	}
+	if q.Probes > 0 {
+		params.Probes = q.Probes
+	}
+
 	var chunks []*domain.DocumentChunk
-	err := r.db.SelectContext(ctx, &chunks, query, vector, limit)
+	var err error
+	if params == (SearchParams{}) {
+		chunks, err = selectChunks(ctx, r.db, query, queryArgs)
+	} else {
+		chunks, err = r.searchWith(ctx, params, query, queryArgs)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %w", err)
 	}
 
 	return chunks, nil
 }
+
+// searchWith runs query with the index settings in params. The settings are
+// local to a transaction so they never leak to other users of the connection.
+func (r *PostgresVectorRepo) searchWith(ctx context.Context, params SearchParams, query string, args []interface{}) ([]*domain.DocumentChunk, error) {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if params.EfSearch > 0 {
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('hnsw.ef_search', $1, true)`, strconv.Itoa(params.EfSearch)); err != nil {
+			return nil, err
+		}
+	}
+	if params.Probes > 0 {
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('ivfflat.probes', $1, true)`, strconv.Itoa(params.Probes)); err != nil {
+			return nil, err
+		}
+	}
+
+	chunks, err := selectChunks(ctx, tx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return chunks, tx.Commit()
+}
+
+// SearchKeyword ranks the chunks matching q by full-text match on text. The
+// text is read like a web search: words are all required, "quoted phrases"
+// must appear in order and -word excludes a word. Words are compared with the
+// 'simple' configuration, lowercased but not stemmed, so Bengali works as well
+// as English.
+func (r *PostgresVectorRepo) SearchKeyword(ctx context.Context, text string, q domain.SearchQuery) ([]*domain.DocumentChunk, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	// MinScore is a cosine similarity, which keyword matches do not have
+	q.MinScore = 0
+	where, args := buildWhereClause(q, 2)
+	if where == "" {
+		where = " WHERE content_tsv @@ query"
+	} else {
+		where += " AND content_tsv @@ query"
+	}
+
+	query := `SELECT ` + searchColumns(q) + `, ts_rank_cd(content_tsv, query) AS score
+			  FROM embeddings, websearch_to_tsquery('simple', $1) query` + where + `
+			  ORDER BY ts_rank_cd(content_tsv, query) DESC, document_id, chunk_index
+			  LIMIT $` + strconv.Itoa(len(args)+2)
+
+	queryArgs := append([]interface{}{text}, args...)
+	queryArgs = append(queryArgs, q.Limit)
+	if q.Offset > 0 {
+		query += ` OFFSET $` + strconv.Itoa(len(queryArgs)+1)
+		queryArgs = append(queryArgs, q.Offset)
+	}
+
+	chunks, err := selectChunks(ctx, r.db, query, queryArgs)
+	if err != nil {
+		return nil, fmt.Errorf("keyword search failed: %w", err)
+	}
+	return chunks, nil
+}
+
+// searchColumns adds the embedding to chunkColumns when q asks for it
+func searchColumns(q domain.SearchQuery) string {
+	if q.IncludeEmbeddings {
+		return chunkColumns + `, embedding`
+	}
+	return chunkColumns
+}
+
+// selectChunks runs a search query, converting any embeddings it selects
+func selectChunks(ctx context.Context, db sqlx.QueryerContext, query string, args []interface{}) ([]*domain.DocumentChunk, error) {
+	var rows []struct {
+		*domain.DocumentChunk
+		Embedding *pgvector.Vector `db:"embedding"`
+	}
+	if err := sqlx.SelectContext(ctx, db, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	chunks := make([]*domain.DocumentChunk, len(rows))
+	for i, row := range rows {
+		if row.Embedding != nil {
+			row.DocumentChunk.Embedding = row.Embedding.Slice()
+		}
+		chunks[i] = row.DocumentChunk
+	}
+	return chunks, nil
+}
+
+func (r *PostgresVectorRepo) DeleteChunksByDocument(ctx context.Context, documentID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM embeddings WHERE document_id = $1`, documentID)
+	if err != nil {
+		return fmt.Errorf("delete chunks failed: %w", err)
+	}
+	return nil
+}
+
+// buildWhereClause turns a validated search query into a parameterized WHERE
+// clause. Placeholders are numbered from firstArg so the caller can reserve
+// earlier positions (e.g. $1 for the query vector). Conditions are emitted in
+// a fixed order so the generated SQL is stable.
+func buildWhereClause(q domain.SearchQuery, firstArg int) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(firstArg+len(args)-1)))
+	}
+
+	if q.Subject != "" {
+		add("subject = ?", q.Subject)
+	}
+
+	switch len(q.Chapters) {
+	case 0:
+	case 1:
+		add("chapter = ?", q.Chapters[0])
+	default:
+		chapters := make([]int64, len(q.Chapters))
+		for i, c := range q.Chapters {
+			chapters[i] = int64(c)
+		}
+		add("chapter = ANY(?)", pq.Int64Array(chapters))
+	}
+
+	switch len(q.Languages) {
+	case 0:
+	case 1:
+		add("language = ?", q.Languages[0])
+	default:
+		add("language = ANY(?)", pq.StringArray(q.Languages))
+	}
+
+	if len(q.DocumentIDs) > 0 {
+		ids := make([]string, len(q.DocumentIDs))
+		for i, id := range q.DocumentIDs {
+			ids[i] = id.String()
+		}
+		add("document_id = ANY(?::uuid[])", pq.StringArray(ids))
+	}
+
+	// A chunk matches a page range when any of its pages fall inside it
+	if q.PageFrom > 0 {
+		add("page_end >= ?", q.PageFrom)
+	}
+	if q.PageTo > 0 {
+		add("page <= ?", q.PageTo)
+	}
+
+	if q.MinScore > 0 {
+		// Cosine similarity is 1 - cosine distance
+		add("1 - (embedding <=> $1) >= ?", q.MinScore)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
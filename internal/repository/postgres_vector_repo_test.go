@@ -11,10 +11,13 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/pgvector/pgvector-go"
 	"github.com/stretchr/testify/assert"
 )
 
+var insertChunkQuery = regexp.QuoteMeta(`INSERT INTO embeddings (id, document_id, chunk_index, content_hash, subject, chapter, section, content, embedding, language, page, page_end, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`)
+
 func TestSaveChunk(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -24,20 +27,23 @@ func TestSaveChunk(t *testing.T) {
 	repo := NewPostgresVectorRepo(sqlxDB)
 
 	chunk := &domain.DocumentChunk{
-		ID:        uuid.New(),
-		Subject:   "Physics",
-		Chapter:   1,
-		Content:   "Newton's First Law",
-		Embedding: []float32{0.1, 0.2, 0.3},
-		Language:  "en",
-		Page:      10,
-		CreatedAt: time.Now(),
+		ID:          uuid.New(),
+		DocumentID:  uuid.New(),
+		ChunkIndex:  4,
+		ContentHash: "abc123",
+		Subject:     "Physics",
+		Chapter:     1,
+		Section:     "1.1 Newton's Laws",
+		Content:     "Newton's First Law",
+		Embedding:   []float32{0.1, 0.2, 0.3},
+		Language:    "en",
+		Page:        10,
+		PageEnd:     11,
+		CreatedAt:   time.Now(),
 	}
 
-	query := regexp.QuoteMeta(`INSERT INTO embeddings (id, subject, chapter, content, embedding, language, page, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
-
-	mock.ExpectExec(query).
-		WithArgs(chunk.ID, chunk.Subject, chunk.Chapter, chunk.Content, pgvector.NewVector(chunk.Embedding), chunk.Language, chunk.Page, chunk.CreatedAt).
+	mock.ExpectExec(insertChunkQuery).
+		WithArgs(chunk.ID, chunk.DocumentID, chunk.ChunkIndex, chunk.ContentHash, chunk.Subject, chunk.Chapter, chunk.Section, chunk.Content, pgvector.NewVector(chunk.Embedding), chunk.Language, chunk.Page, chunk.PageEnd, chunk.CreatedAt).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = repo.SaveChunk(context.Background(), chunk)
@@ -57,18 +63,301 @@ func TestSearchSimilar(t *testing.T) {
 	limit := 5
 
 	// Expected rows
-	rows := sqlmock.NewRows([]string{"id", "subject", "chapter", "content", "language", "page", "created_at"}).
-		AddRow(uuid.New(), "Physics", 1, "Content 1", "en", 10, time.Now()).
-		AddRow(uuid.New(), "Physics", 1, "Content 2", "en", 11, time.Now())
+	rows := sqlmock.NewRows([]string{"id", "document_id", "chunk_index", "content_hash", "subject", "chapter", "section", "content", "language", "page", "page_end", "created_at", "score"}).
+		AddRow(uuid.New(), uuid.New(), 0, "h", "Physics", 1, "", "Content 1", "en", 10, 10, time.Now(), 0.93).
+		AddRow(uuid.New(), uuid.New(), 0, "h", "Physics", 1, "", "Content 2", "en", 11, 12, time.Now(), 0.81)
 
-	query := regexp.QuoteMeta(`SELECT id, subject, chapter, content, language, page, created_at FROM embeddings ORDER BY embedding <=> $1 LIMIT $2`)
+	query := regexp.QuoteMeta(`SELECT id, document_id, chunk_index, content_hash, subject, chapter, section, content, language, page, page_end, created_at, 1 - (embedding <=> $1) AS score FROM embeddings ORDER BY embedding <=> $1 LIMIT $2`)
 
 	mock.ExpectQuery(query).
 		WithArgs(pgvector.NewVector(embedding), limit).
 		WillReturnRows(rows)
 
-	results, err := repo.SearchSimilar(context.Background(), embedding, limit, nil)
+	results, err := repo.SearchSimilar(context.Background(), embedding, domain.SearchQuery{Limit: limit})
 	assert.NoError(t, err)
 	assert.Len(t, results, 2)
+	assert.Equal(t, 0.93, results[0].Score)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilar_WithFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	embedding := []float32{0.1, 0.2, 0.3}
+
+	rows := sqlmock.NewRows([]string{"id", "document_id", "chunk_index", "content_hash", "subject", "chapter", "section", "content", "language", "page", "page_end", "created_at"}).
+		AddRow(uuid.New(), uuid.New(), 0, "h", "Physics", 3, "", "Content 1", "bn", 42, 43, time.Now())
+
+	query := regexp.QuoteMeta(`SELECT id, document_id, chunk_index, content_hash, subject, chapter, section, content, language, page, page_end, created_at, 1 - (embedding <=> $1) AS score FROM embeddings WHERE subject = $2 AND chapter = $3 AND language = $4 AND page_end >= $5 AND page <= $6 AND 1 - (embedding <=> $1) >= $7 ORDER BY embedding <=> $1 LIMIT $8 OFFSET $9`)
+
+	mock.ExpectQuery(query).
+		WithArgs(pgvector.NewVector(embedding), "Physics", 3, "bn", 40, 50, 0.7, 5, 10).
+		WillReturnRows(rows)
+
+	q := domain.SearchQuery{
+		Subject:   "Physics",
+		Chapters:  []int{3},
+		Languages: []string{"bn"},
+		PageFrom:  40,
+		PageTo:    50,
+		MinScore:  0.7,
+		Limit:     5,
+		Offset:    10,
+	}
+	results, err := repo.SearchSimilar(context.Background(), embedding, q)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 3, results[0].Chapter)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilar_WithLists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	embedding := []float32{0.1, 0.2, 0.3}
+	docID := uuid.New()
+
+	rows := sqlmock.NewRows([]string{"id", "document_id", "chunk_index", "content_hash", "subject", "chapter", "section", "content", "language", "page", "page_end", "created_at"})
+
+	query := regexp.QuoteMeta(`SELECT id, document_id, chunk_index, content_hash, subject, chapter, section, content, language, page, page_end, created_at, 1 - (embedding <=> $1) AS score FROM embeddings WHERE chapter = ANY($2) AND language = ANY($3) AND document_id = ANY($4::uuid[]) ORDER BY embedding <=> $1 LIMIT $5`)
+
+	mock.ExpectQuery(query).
+		WithArgs(pgvector.NewVector(embedding), pq.Int64Array{2, 4}, pq.StringArray{"en", "bn"}, pq.StringArray{docID.String()}, 10).
+		WillReturnRows(rows)
+
+	q := domain.SearchQuery{
+		Chapters:    []int{2, 4},
+		Languages:   []string{"en", "bn"},
+		DocumentIDs: []uuid.UUID{docID},
+		Limit:       10,
+	}
+	results, err := repo.SearchSimilar(context.Background(), embedding, q)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilar_TunesIndexPerQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+	repo.Search = SearchParams{EfSearch: 40, Probes: 5}
+
+	embedding := []float32{0.1, 0.2, 0.3}
+	rows := sqlmock.NewRows([]string{"id", "document_id", "chunk_index", "content_hash", "subject", "chapter", "section", "content", "language", "page", "page_end", "created_at"}).
+		AddRow(uuid.New(), uuid.New(), 0, "h", "Physics", 1, "", "Content 1", "en", 10, 10, time.Now())
+
+	// The query's ef_search wins; probes falls back to the repository's
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT set_config('hnsw.ef_search', $1, true)`)).WithArgs("200").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT set_config('ivfflat.probes', $1, true)`)).WithArgs("5").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM embeddings ORDER BY embedding <=> $1 LIMIT $2`)).
+		WithArgs(pgvector.NewVector(embedding), 5).
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	results, err := repo.SearchSimilar(context.Background(), embedding, domain.SearchQuery{Limit: 5, EfSearch: 200})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilar_IncludeEmbeddings(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	embedding := []float32{0.1, 0.2, 0.3}
+	rows := sqlmock.NewRows([]string{"id", "document_id", "chunk_index", "content_hash", "subject", "chapter", "section", "content", "language", "page", "page_end", "created_at", "embedding", "score"}).
+		AddRow(uuid.New(), uuid.New(), 0, "h", "Physics", 1, "", "Content 1", "en", 10, 10, time.Now(), "[0.5,0.25,1]", 0.9)
+
+	query := regexp.QuoteMeta(`SELECT id, document_id, chunk_index, content_hash, subject, chapter, section, content, language, page, page_end, created_at, embedding, 1 - (embedding <=> $1) AS score FROM embeddings ORDER BY embedding <=> $1 LIMIT $2`)
+	mock.ExpectQuery(query).
+		WithArgs(pgvector.NewVector(embedding), 5).
+		WillReturnRows(rows)
+
+	results, err := repo.SearchSimilar(context.Background(), embedding, domain.SearchQuery{Limit: 5, IncludeEmbeddings: true})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, []float32{0.5, 0.25, 1}, results[0].Embedding)
+	assert.Equal(t, 0.9, results[0].Score)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchKeyword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	rows := sqlmock.NewRows([]string{"id", "document_id", "chunk_index", "content_hash", "subject", "chapter", "section", "content", "language", "page", "page_end", "created_at"}).
+		AddRow(uuid.New(), uuid.New(), 0, "h", "Physics", 3, "", "Bernoulli's principle", "en", 42, 43, time.Now())
+
+	// MinScore is a vector similarity and does not constrain keyword matches
+	query := regexp.QuoteMeta(`SELECT id, document_id, chunk_index, content_hash, subject, chapter, section, content, language, page, page_end, created_at, ts_rank_cd(content_tsv, query) AS score FROM embeddings, websearch_to_tsquery('simple', $1) query WHERE subject = $2 AND content_tsv @@ query ORDER BY ts_rank_cd(content_tsv, query) DESC, document_id, chunk_index LIMIT $3 OFFSET $4`)
+	mock.ExpectQuery(query).
+		WithArgs("Bernoulli", "Physics", 5, 10).
+		WillReturnRows(rows)
+
+	results, err := repo.SearchKeyword(context.Background(), "Bernoulli", domain.SearchQuery{Subject: "Physics", MinScore: 0.7, Limit: 5, Offset: 10})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchSimilar_InvalidQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	_, err = repo.SearchSimilar(context.Background(), []float32{0.1}, domain.SearchQuery{Limit: 5, PageFrom: 10, PageTo: 5})
+	assert.ErrorIs(t, err, domain.ErrInvalidQuery)
+
+	// No query must reach the database for a rejected filter.
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteChunksByDocument(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	docID := uuid.New()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM embeddings WHERE document_id = $1`)).
+		WithArgs(docID).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+
+	err = repo.DeleteChunksByDocument(context.Background(), docID)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveChunks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	chunks := []*domain.DocumentChunk{
+		{ID: uuid.New(), DocumentID: uuid.New(), ContentHash: "a", Embedding: []float32{0.1}},
+		{ID: uuid.New(), DocumentID: uuid.New(), ContentHash: "b", Embedding: []float32{0.2}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertChunkQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertChunkQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.SaveChunks(context.Background(), chunks)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceChunks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	docID := uuid.New()
+	add := []*domain.DocumentChunk{{ID: uuid.New(), DocumentID: docID, ChunkIndex: 0, ContentHash: "c", Embedding: []float32{0.3}}}
+	// "a" is unchanged but now on page 4, in chapter 2
+	moved := &domain.DocumentChunk{DocumentID: docID, ChunkIndex: 1, ContentHash: "a", Subject: "Physics", Chapter: 2, Section: "2.1 Speed", Language: "en", Page: 4, PageEnd: 5}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertChunkQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE embeddings e\s+SET chunk_index = k.chunk_index.*FROM unnest`).
+		WithArgs(docID, pq.StringArray{"a"}, pq.Int64Array{1}, pq.StringArray{"Physics"}, pq.Int64Array{2},
+			pq.StringArray{"2.1 Speed"}, pq.StringArray{"en"}, pq.Int64Array{4}, pq.Int64Array{5}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM embeddings WHERE document_id = \$1\s+AND \(content_hash <> ALL\(\$2\) OR \(content_hash = ANY\(\$3\) AND id <> ALL\(\$4::uuid\[\]\)\)\)`).
+		WithArgs(docID, pq.StringArray{"c", "a"}, pq.StringArray{"c"}, pq.StringArray{add[0].ID.String()}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.ReplaceChunks(context.Background(), docID, add, []*domain.DocumentChunk{add[0], moved})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceChunks_RollsBackOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	docID := uuid.New()
+	add := []*domain.DocumentChunk{{ID: uuid.New(), DocumentID: docID, ContentHash: "c", Embedding: []float32{0.3}}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertChunkQuery).WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	err = repo.ReplaceChunks(context.Background(), docID, add, add)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDimensions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresVectorRepo(sqlx.NewDb(db, "postgres"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT atttypmod FROM pg_attribute WHERE attrelid = 'embeddings'::regclass AND attname = 'embedding'`)).
+		WillReturnRows(sqlmock.NewRows([]string{"atttypmod"}).AddRow(768))
+
+	dims, err := repo.Dimensions(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 768, dims)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChunkHashes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	repo := NewPostgresVectorRepo(sqlxDB)
+
+	docID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT content_hash FROM embeddings WHERE document_id = $1 AND content_hash IS NOT NULL`)).
+		WithArgs(docID).
+		WillReturnRows(sqlmock.NewRows([]string{"content_hash"}).AddRow("a").AddRow("b"))
+
+	hashes, err := repo.ChunkHashes(context.Background(), docID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, hashes)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
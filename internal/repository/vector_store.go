@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"fmt"
+
+	"backend/internal/domain"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// NewVectorRepo returns the vector store named by store: "postgres", which
+// tunes its index with search, or "memory", which is kept in the snapshot
+// file at snapshot when one is given
+func NewVectorRepo(store string, db *sqlx.DB, snapshot string, search SearchParams) (domain.VectorRepository, error) {
+	switch store {
+	case "postgres":
+		repo := NewPostgresVectorRepo(db)
+		repo.Search = search
+		return repo, nil
+	case "memory":
+		if snapshot == "" {
+			return NewMemoryVectorRepo(), nil
+		}
+		return OpenMemoryVectorRepo(snapshot)
+	default:
+		return nil, fmt.Errorf("unknown vector store %q", store)
+	}
+}
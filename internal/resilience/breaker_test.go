@@ -0,0 +1,38 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker_HalfOpenAllowsSingleProbe(t *testing.T) {
+	b := newBreaker(1, time.Second)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	assert.NoError(t, b.allow())
+	b.record(true)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	now = now.Add(time.Second)
+	assert.NoError(t, b.allow())
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	// A failed probe re-opens the circuit for another cooldown
+	b.record(true)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+	now = now.Add(time.Second)
+	assert.NoError(t, b.allow())
+	b.record(false)
+	assert.NoError(t, b.allow())
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b := newBreaker(0, time.Second)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, b.allow())
+		b.record(true)
+	}
+}
@@ -0,0 +1,118 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+)
+
+// Embedder matches the embedding clients used by ingestion and retrieval
+type Embedder interface {
+	EmbedContent(ctx context.Context, text string) ([]float32, error)
+}
+
+// BatchEmbedder matches embedding clients that can embed several texts per call
+type BatchEmbedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// GenerationClient matches the text generation client used by the question generator
+type GenerationClient interface {
+	GenerateContent(ctx context.Context, prompt string) (string, error)
+}
+
+// StreamingGenerationClient matches generation clients that can stream their response
+type StreamingGenerationClient interface {
+	GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error
+}
+
+type embedder struct {
+	next   Embedder
+	policy *Policy
+}
+
+func (e *embedder) EmbedContent(ctx context.Context, text string) ([]float32, error) {
+	var embedding []float32
+	err := e.policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		embedding, err = e.next.EmbedContent(ctx, text)
+		return err
+	})
+	return embedding, err
+}
+
+type batchEmbedder struct {
+	*embedder
+	batch BatchEmbedder
+}
+
+func (e *batchEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	var embeddings [][]float32
+	err := e.policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		embeddings, err = e.batch.EmbedBatch(ctx, texts)
+		return err
+	})
+	return embeddings, err
+}
+
+// WrapEmbedder runs every call to next under policy. The result implements
+// BatchEmbedder if and only if next does.
+func WrapEmbedder(next Embedder, policy *Policy) Embedder {
+	e := &embedder{next: next, policy: policy}
+	if batch, ok := next.(BatchEmbedder); ok {
+		return &batchEmbedder{embedder: e, batch: batch}
+	}
+	return e
+}
+
+type generationClient struct {
+	next   GenerationClient
+	policy *Policy
+}
+
+type streamingGenerationClient struct {
+	*generationClient
+	stream StreamingGenerationClient
+}
+
+// GenerateStream only retries a stream that failed before any of it reached
+// onChunk; retrying later would hand the caller the start of the response twice.
+func (g *streamingGenerationClient) GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error {
+	started := false
+	err := g.policy.Do(ctx, func(ctx context.Context) error {
+		err := g.stream.GenerateStream(ctx, prompt, func(text string) error {
+			started = true
+			return onChunk(text)
+		})
+		if err != nil && started {
+			return &permanentError{err: err}
+		}
+		return err
+	})
+
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return perm.err
+	}
+	return err
+}
+
+// WrapGenerationClient runs every call to next under policy. The result
+// implements StreamingGenerationClient if and only if next does.
+func WrapGenerationClient(next GenerationClient, policy *Policy) GenerationClient {
+	g := &generationClient{next: next, policy: policy}
+	if stream, ok := next.(StreamingGenerationClient); ok {
+		return &streamingGenerationClient{generationClient: g, stream: stream}
+	}
+	return g
+}
+
+func (g *generationClient) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	var text string
+	err := g.policy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		text, err = g.next.GenerateContent(ctx, prompt)
+		return err
+	})
+	return text, err
+}
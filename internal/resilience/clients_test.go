@@ -0,0 +1,169 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+// fakeClient returns the scripted errors in order, then succeeds
+type fakeClient struct {
+	errs  []error
+	calls int
+}
+
+func (f *fakeClient) next() error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func (f *fakeClient) EmbedContent(ctx context.Context, text string) ([]float32, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return []float32{0.1}, nil
+}
+
+func (f *fakeClient) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	if err := f.next(); err != nil {
+		return "", err
+	}
+	return "ok", nil
+}
+
+type fakeBatchClient struct {
+	fakeClient
+}
+
+func (f *fakeBatchClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return make([][]float32, len(texts)), nil
+}
+
+// fakeStreamClient streams "a", "b" and then fails with the scripted error of
+// each call; failAt is how many chunks it sends before failing
+type fakeStreamClient struct {
+	fakeClient
+	failAt int
+}
+
+func (f *fakeStreamClient) GenerateStream(ctx context.Context, prompt string, onChunk func(text string) error) error {
+	err := f.next()
+	for i, text := range []string{"a", "b"} {
+		if err != nil && i == f.failAt {
+			return err
+		}
+		if cbErr := onChunk(text); cbErr != nil {
+			return cbErr
+		}
+	}
+	return err
+}
+
+func tooManyRequests() error {
+	return &googleapi.Error{Code: http.StatusTooManyRequests}
+}
+
+// newTestPolicy returns a policy that records its sleeps instead of sleeping
+func newTestPolicy(cfg Config) (*Policy, *[]time.Duration) {
+	p := NewPolicy(cfg)
+	var slept []time.Duration
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	p.jitter = func() float64 { return 1 }
+	return p, &slept
+}
+
+func TestWrapGenerationClient_RetriesTransientErrors(t *testing.T) {
+	client := &fakeClient{errs: []error{tooManyRequests(), &googleapi.Error{Code: http.StatusServiceUnavailable}}}
+	policy, slept := newTestPolicy(Config{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute})
+
+	text, err := WrapGenerationClient(client, policy).GenerateContent(context.Background(), "prompt")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", text)
+	assert.Equal(t, 3, client.calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *slept)
+}
+
+func TestWrapGenerationClient_DoesNotRetryPermanentErrors(t *testing.T) {
+	client := &fakeClient{errs: []error{&googleapi.Error{Code: http.StatusBadRequest}}}
+	policy, slept := newTestPolicy(Config{MaxAttempts: 3, BaseDelay: time.Second})
+
+	_, err := WrapGenerationClient(client, policy).GenerateContent(context.Background(), "prompt")
+	assert.Error(t, err)
+	assert.Equal(t, 1, client.calls)
+	assert.Empty(t, *slept)
+}
+
+func TestWrapEmbedder_GivesUpAfterMaxAttempts(t *testing.T) {
+	client := &fakeClient{errs: []error{tooManyRequests(), tooManyRequests(), tooManyRequests()}}
+	policy, _ := newTestPolicy(Config{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	_, err := WrapEmbedder(client, policy).EmbedContent(context.Background(), "text")
+	assert.ErrorContains(t, err, "giving up after 2 attempts")
+	var apiErr *googleapi.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, 2, client.calls)
+}
+
+func TestWrapEmbedder_PreservesBatchSupport(t *testing.T) {
+	policy := NewPolicy(Config{MaxAttempts: 1})
+
+	_, ok := WrapEmbedder(&fakeClient{}, policy).(BatchEmbedder)
+	assert.False(t, ok)
+
+	batch := &fakeBatchClient{fakeClient{errs: []error{tooManyRequests()}}}
+	policy, _ = newTestPolicy(Config{MaxAttempts: 2})
+	wrapped, ok := WrapEmbedder(batch, policy).(BatchEmbedder)
+	assert.True(t, ok)
+
+	embeddings, err := wrapped.EmbedBatch(context.Background(), []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Len(t, embeddings, 2)
+	assert.Equal(t, 2, batch.calls)
+}
+
+func TestWrapGenerationClient_RetriesStreamThatFailedBeforeFirstChunk(t *testing.T) {
+	client := &fakeStreamClient{fakeClient: fakeClient{errs: []error{tooManyRequests()}}}
+	policy, _ := newTestPolicy(Config{MaxAttempts: 3, BaseDelay: time.Second})
+
+	wrapped, ok := WrapGenerationClient(client, policy).(StreamingGenerationClient)
+	assert.True(t, ok)
+
+	var got []string
+	err := wrapped.GenerateStream(context.Background(), "prompt", func(text string) error {
+		got = append(got, text)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, got)
+	assert.Equal(t, 2, client.calls)
+}
+
+func TestWrapGenerationClient_DoesNotRetryStreamAfterFirstChunk(t *testing.T) {
+	client := &fakeStreamClient{fakeClient: fakeClient{errs: []error{tooManyRequests()}}, failAt: 1}
+	policy, slept := newTestPolicy(Config{MaxAttempts: 3, BaseDelay: time.Second})
+
+	var got []string
+	err := WrapGenerationClient(client, policy).(StreamingGenerationClient).GenerateStream(context.Background(), "prompt", func(text string) error {
+		got = append(got, text)
+		return nil
+	})
+	var apiErr *googleapi.Error
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, []string{"a"}, got)
+	assert.Equal(t, 1, client.calls)
+	assert.Empty(t, *slept)
+}
@@ -0,0 +1,111 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/llmhttp"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+)
+
+// IsTransient reports whether err is worth retrying: rate limiting (429),
+// server errors (5xx), their gRPC equivalents, and network timeouts. HTTP
+// statuses are recognised from both the Gemini SDK and llmhttp clients.
+// Cancellation of the caller's own context is never transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+
+	var httpErr *googleapi.Error
+	if errors.As(err, &httpErr) {
+		return transientHTTP(httpErr.Code)
+	}
+
+	var statusErr *llmhttp.StatusError
+	if errors.As(err, &statusErr) {
+		return transientHTTP(statusErr.Code)
+	}
+
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		if code := apiErr.HTTPCode(); code > 0 {
+			return transientHTTP(code)
+		}
+		if s := apiErr.GRPCStatus(); s != nil {
+			switch s.Code() {
+			case codes.ResourceExhausted, codes.Unavailable, codes.Internal, codes.DeadlineExceeded, codes.Aborted:
+				return true
+			}
+			return false
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// permanentError marks a failure that must not be retried whatever its cause
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func transientHTTP(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// RetryAfter returns how long the server asked the client to wait before
+// retrying, from a Retry-After header or a gRPC RetryInfo detail. It returns
+// zero if err carries no such hint.
+func RetryAfter(err error) time.Duration {
+	var httpErr *googleapi.Error
+	if errors.As(err, &httpErr) && httpErr.Header != nil {
+		if d := parseRetryAfter(httpErr.Header.Get("Retry-After")); d > 0 {
+			return d
+		}
+	}
+
+	var statusErr *llmhttp.StatusError
+	if errors.As(err, &statusErr) {
+		if d := parseRetryAfter(statusErr.Header.Get("Retry-After")); d > 0 {
+			return d
+		}
+	}
+
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		if info := apiErr.Details().RetryInfo; info != nil && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration()
+		}
+	}
+	return 0
+}
+
+// parseRetryAfter accepts both forms of the header: delay seconds or an HTTP date
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/internal/llmhttp"
+
+	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestIsTransient(t *testing.T) {
+	unavailable, _ := apierror.FromError(status.Error(codes.Unavailable, "overloaded"))
+	invalid, _ := apierror.FromError(status.Error(codes.InvalidArgument, "bad"))
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"429", &googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{"503 wrapped", fmt.Errorf("embedding failed: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), true},
+		{"400", &googleapi.Error{Code: http.StatusBadRequest}, false},
+		{"http api 502", &llmhttp.StatusError{Code: http.StatusBadGateway}, true},
+		{"http api 401", &llmhttp.StatusError{Code: http.StatusUnauthorized}, false},
+		{"grpc unavailable", unavailable, true},
+		{"grpc invalid argument", invalid, false},
+		{"cancelled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"plain", errors.New("text cannot be empty"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsTransient(tt.err))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := &googleapi.Error{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3"}}}
+	assert.Equal(t, 3*time.Second, RetryAfter(header))
+
+	st, err := status.New(codes.ResourceExhausted, "quota").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(12 * time.Second)})
+	assert.NoError(t, err)
+	grpcErr, _ := apierror.FromError(st.Err())
+	assert.Equal(t, 12*time.Second, RetryAfter(grpcErr))
+
+	httpAPI := &llmhttp.StatusError{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"7"}}}
+	assert.Equal(t, 7*time.Second, RetryAfter(httpAPI))
+
+	assert.Zero(t, RetryAfter(&googleapi.Error{Code: http.StatusTooManyRequests}))
+	assert.Zero(t, RetryAfter(errors.New("boom")))
+}
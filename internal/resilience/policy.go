@@ -0,0 +1,140 @@
+// Package resilience wraps calls to rate-limited remote APIs (the Gemini
+// embedding and generation clients) with retries, client-side rate limiting
+// and a circuit breaker.
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Config controls a Policy. A zero RequestsPerMinute disables rate limiting
+// and a zero BreakerThreshold disables the circuit breaker.
+type Config struct {
+	// MaxAttempts is the total number of tries per call, including the first
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles on each retry up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// RequestsPerMinute is the sustained rate allowed through the token bucket
+	RequestsPerMinute int
+	// Burst is how many requests may be made back to back when the bucket is full
+	Burst int
+
+	// BreakerThreshold is how many consecutive transient failures open the circuit
+	BreakerThreshold int
+	// BreakerCooldown is how long the circuit stays open before a probe call is let through
+	BreakerCooldown time.Duration
+}
+
+// DefaultConfig returns conservative settings for the Gemini free tier
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:       5,
+		BaseDelay:         500 * time.Millisecond,
+		MaxDelay:          30 * time.Second,
+		RequestsPerMinute: 0,
+		Burst:             1,
+		BreakerThreshold:  10,
+		BreakerCooldown:   30 * time.Second,
+	}
+}
+
+// Policy runs calls under a Config. A Policy is safe for concurrent use and
+// should be shared by every caller of the same API, so the rate limit and the
+// breaker see all of its traffic.
+type Policy struct {
+	cfg     Config
+	limiter *rate.Limiter
+	breaker *breaker
+
+	// Overridden in tests
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func() float64
+}
+
+func NewPolicy(cfg Config) *Policy {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+
+	p := &Policy{
+		cfg:     cfg,
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		sleep:   sleep,
+		jitter:  rand.Float64,
+	}
+	if cfg.RequestsPerMinute > 0 {
+		p.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(cfg.RequestsPerMinute)), max(cfg.Burst, 1))
+	}
+	return p
+}
+
+// Do calls fn, retrying transient failures (see IsTransient) with exponential
+// backoff and jitter. A server's retry-after hint is honoured when it asks for
+// a longer wait than the backoff. Non-transient errors are returned at once.
+func (p *Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if p.limiter != nil {
+			if err := p.limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		if err := p.breaker.allow(); err != nil {
+			if lastErr != nil {
+				return fmt.Errorf("%w (last error: %w)", err, lastErr)
+			}
+			return err
+		}
+
+		err := fn(ctx)
+		transient := IsTransient(err)
+		p.breaker.record(transient)
+		if !transient {
+			return err
+		}
+		lastErr = err
+
+		if attempt >= p.cfg.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		if err := p.sleep(ctx, p.delay(attempt, err)); err != nil {
+			return err
+		}
+	}
+}
+
+// delay returns how long to wait before retry number attempt. The backoff
+// doubles per attempt and is jittered to between half and all of its value
+// so that concurrent callers don't retry in lockstep.
+func (p *Policy) delay(attempt int, err error) time.Duration {
+	backoff := time.Duration(float64(p.cfg.BaseDelay) * math.Pow(2, float64(attempt-1)))
+	if p.cfg.MaxDelay > 0 && backoff > p.cfg.MaxDelay {
+		backoff = p.cfg.MaxDelay
+	}
+	backoff = backoff/2 + time.Duration(p.jitter()*float64(backoff/2))
+
+	if hint := RetryAfter(err); hint > backoff {
+		return hint
+	}
+	return backoff
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+func TestPolicy_DelayBacksOffExponentiallyUpToMax(t *testing.T) {
+	p, _ := newTestPolicy(Config{BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+	err := tooManyRequests()
+
+	assert.Equal(t, time.Second, p.delay(1, err))
+	assert.Equal(t, 2*time.Second, p.delay(2, err))
+	assert.Equal(t, 4*time.Second, p.delay(3, err))
+	assert.Equal(t, 5*time.Second, p.delay(4, err))
+}
+
+func TestPolicy_DelayIsJittered(t *testing.T) {
+	p, _ := newTestPolicy(Config{BaseDelay: time.Second})
+	p.jitter = func() float64 { return 0 }
+
+	assert.Equal(t, 500*time.Millisecond, p.delay(1, tooManyRequests()))
+}
+
+func TestPolicy_HonoursRetryAfter(t *testing.T) {
+	client := &fakeClient{errs: []error{&googleapi.Error{
+		Code:   http.StatusTooManyRequests,
+		Header: http.Header{"Retry-After": []string{"7"}},
+	}}}
+	policy, slept := newTestPolicy(Config{MaxAttempts: 2, BaseDelay: time.Second})
+
+	_, err := WrapGenerationClient(client, policy).GenerateContent(context.Background(), "prompt")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{7 * time.Second}, *slept)
+}
+
+func TestPolicy_StopsWhenContextCancelled(t *testing.T) {
+	client := &fakeClient{errs: []error{tooManyRequests(), tooManyRequests()}}
+	policy, _ := newTestPolicy(Config{MaxAttempts: 5, BaseDelay: time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	policy.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	_, err := WrapGenerationClient(client, policy).GenerateContent(ctx, "prompt")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, client.calls)
+}
+
+func TestPolicy_RateLimits(t *testing.T) {
+	// 6000 per minute is one token every 10ms, with none to spare
+	policy := NewPolicy(Config{MaxAttempts: 1, RequestsPerMinute: 6000, Burst: 1})
+	client := WrapGenerationClient(&fakeClient{}, policy)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := client.GenerateContent(context.Background(), "prompt")
+		assert.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 25*time.Millisecond)
+}
+
+func TestPolicy_CircuitBreakerOpensAndRecovers(t *testing.T) {
+	client := &fakeClient{errs: []error{tooManyRequests(), tooManyRequests()}}
+	policy, _ := newTestPolicy(Config{MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	now := time.Now()
+	policy.breaker.now = func() time.Time { return now }
+	wrapped := WrapGenerationClient(client, policy)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := wrapped.GenerateContent(ctx, "prompt")
+		assert.Error(t, err)
+	}
+
+	// Open: rejected without reaching the client
+	_, err := wrapped.GenerateContent(ctx, "prompt")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, client.calls)
+
+	// After the cooldown a probe is let through and closes the circuit again
+	now = now.Add(time.Minute)
+	text, err := wrapped.GenerateContent(ctx, "prompt")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", text)
+	assert.Equal(t, breakerClosed, policy.breaker.state)
+}
+
+func TestPolicy_PermanentErrorsDoNotTripBreaker(t *testing.T) {
+	badRequest := &googleapi.Error{Code: http.StatusBadRequest}
+	client := &fakeClient{errs: []error{badRequest, badRequest, badRequest}}
+	policy, _ := newTestPolicy(Config{MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	wrapped := WrapGenerationClient(client, policy)
+
+	for i := 0; i < 3; i++ {
+		_, err := wrapped.GenerateContent(context.Background(), "prompt")
+		assert.False(t, errors.Is(err, ErrCircuitOpen))
+	}
+	assert.Equal(t, 3, client.calls)
+}
@@ -0,0 +1,99 @@
+// Package tokenizer measures text in the tokens language models read, so
+// chunks and prompts can be sized for the model rather than by characters.
+package tokenizer
+
+import (
+	"context"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer splits text into tokens
+type Tokenizer interface {
+	// Count returns how many tokens text is
+	Count(text string) int
+	// Split cuts text into its tokens, in order; joined they give back text
+	Split(text string) []string
+}
+
+// Counter is implemented by model clients whose API counts tokens exactly.
+// Every call is a request to the provider.
+type Counter interface {
+	CountTokens(ctx context.Context, text string) (int, error)
+}
+
+// Approximate estimates tokens offline the way the byte-pair encoders of
+// current models tend to split text: a token per four letters of a Latin word,
+// per three digits and per punctuation mark, and a token per two characters of
+// other scripts. Bengali is split that finely because its letters are rare in
+// the text the encoders were trained on, so a Bengali passage costs about
+// twice the tokens of an English one of the same length, and more on some
+// models. Whitespace is part of the token after it.
+type Approximate struct{}
+
+// Character classes, each with its own number of characters per token
+const (
+	classOther = iota
+	classLatin
+	classDigit
+	classScript
+)
+
+var runesPerToken = [...]int{
+	classOther:  1,
+	classLatin:  4,
+	classDigit:  3,
+	classScript: 2,
+}
+
+func (Approximate) Count(text string) int {
+	n := 0
+	scan(text, func(start, end int) { n++ })
+	return n
+}
+
+func (Approximate) Split(text string) []string {
+	var tokens []string
+	scan(text, func(start, end int) { tokens = append(tokens, text[start:end]) })
+	return tokens
+}
+
+// scan calls emit with the byte range of every token in text
+func scan(text string, emit func(start, end int)) {
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		class := classify(r)
+		for n := 0; i < len(text) && n < runesPerToken[class]; n++ {
+			r, size = utf8.DecodeRuneInString(text[i:])
+			if unicode.IsSpace(r) || classify(r) != class {
+				break
+			}
+			i += size
+		}
+		emit(start, i)
+		start = i
+	}
+	if start < len(text) {
+		// Trailing whitespace
+		emit(start, len(text))
+	}
+}
+
+func classify(r rune) int {
+	switch {
+	case unicode.IsDigit(r):
+		return classDigit
+	case unicode.Is(unicode.Latin, r):
+		return classLatin
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return classScript
+	default:
+		return classOther
+	}
+}
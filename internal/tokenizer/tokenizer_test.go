@@ -0,0 +1,40 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApproximate_Split(t *testing.T) {
+	tok := Approximate{}
+
+	assert.Equal(t, []string{"Forc", "e", " is", " 123", "4", " N", "."}, tok.Split("Force is 1234 N."))
+	assert.Equal(t, []string{"বল", " হল", "ো"}, tok.Split("বল হলো"))
+	assert.Equal(t, []string{"a", " \n"}, tok.Split("a \n"))
+	assert.Empty(t, tok.Split(""))
+}
+
+func TestApproximate_SplitJoinsBack(t *testing.T) {
+	tok := Approximate{}
+	for _, text := range []string{
+		"  leading space",
+		"F = ma, so a = F/m.",
+		"নিউটনের দ্বিতীয় সূত্র: F = ma।\nপরের লাইন",
+	} {
+		tokens := tok.Split(text)
+		assert.Equal(t, text, strings.Join(tokens, ""))
+		assert.Equal(t, len(tokens), tok.Count(text))
+	}
+}
+
+func TestApproximate_BengaliCostsMore(t *testing.T) {
+	tok := Approximate{}
+	english := "Force is the product of mass and acceleration."
+	bengali := "বল হলো ভর এবং ত্বরণের গুণফল, যা নিউটনের সূত্র।"
+
+	// Similar lengths in characters
+	assert.InDelta(t, len([]rune(english)), len([]rune(bengali)), 5)
+	assert.Greater(t, tok.Count(bengali), 3*tok.Count(english)/2)
+}